@@ -0,0 +1,315 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/addetz/testing-strategies-demo/data"
+)
+
+// validateIssue is a problem found by the validate command, located in its file.
+type validateIssue struct {
+	File    string `json:"file"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	Path    string `json:"path,omitempty"`
+	Message string `json:"message"`
+}
+
+type validateResult struct {
+	Valid  bool            `json:"valid"`
+	Issues []validateIssue `json:"issues"`
+	// Warnings are schedule conflicts and talks without IDs, which do not make the data invalid
+	Warnings []validateIssue `json:"warnings"`
+	// DuplicateSpeakers are likely duplicates, which do not make the data invalid
+	DuplicateSpeakers []data.DuplicateSpeakers `json:"duplicate_speakers"`
+}
+
+// validate checks the given events, talks and optional speakers files and writes every problem found to stdout,
+// followed by the schedule conflicts and the speakers which are likely duplicates.
+// It returns the exit code: 0 if the data is valid, 1 if it is not and 2 on usage errors.
+func validate(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	jsonOutput := fs.Bool("json", false, "write the result as JSON")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: server validate [--json] <events.json> <talks.json> [speakers.json]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 2 && fs.NArg() != 3 {
+		fs.Usage()
+		return 2
+	}
+	eventsPath, talksPath, speakersPath := fs.Arg(0), fs.Arg(1), fs.Arg(2)
+
+	result := validateResult{
+		Issues:            []validateIssue{},
+		Warnings:          []validateIssue{},
+		DuplicateSpeakers: []data.DuplicateSpeakers{},
+	}
+	events, err := data.LoadEvents(eventsPath)
+	if err != nil {
+		result.Issues = append(result.Issues, loadIssue(eventsPath, err))
+	}
+	talks, err := data.LoadTalks(talksPath)
+	if err != nil {
+		result.Issues = append(result.Issues, loadIssue(talksPath, err))
+	}
+	var speakers []data.Speaker
+	if speakersPath != "" {
+		speakers, err = data.LoadSpeakers(speakersPath)
+		if err != nil {
+			result.Issues = append(result.Issues, loadIssue(speakersPath, err))
+		}
+	}
+	// integrity checks are only meaningful once both files are readable
+	if len(result.Issues) == 0 {
+		report := data.Validate(events, talks, speakers)
+		for _, issue := range report.Issues {
+			file := eventsPath
+			if strings.HasPrefix(issue.Path, "$.talks") {
+				file = talksPath
+			}
+			result.Issues = append(result.Issues, validateIssue{
+				File:    file,
+				Path:    issue.Path,
+				Message: issue.Message,
+			})
+		}
+		for _, warning := range report.Warnings {
+			result.Warnings = append(result.Warnings, validateIssue{
+				File:    talksPath,
+				Path:    warning.Path,
+				Message: warning.Message,
+			})
+		}
+		if duplicates := data.FindDuplicateSpeakers(data.ImportSpeakers(talks, speakers)); duplicates != nil {
+			result.DuplicateSpeakers = duplicates
+		}
+	}
+	result.Valid = len(result.Issues) == 0
+
+	if *jsonOutput {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(result); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+	} else {
+		for _, issue := range result.Issues {
+			switch {
+			case issue.Line != 0:
+				fmt.Fprintf(stdout, "%s:%d:%d: %s\n", issue.File, issue.Line, issue.Column, issue.Message)
+			case issue.Path != "":
+				fmt.Fprintf(stdout, "%s: %s: %s\n", issue.File, issue.Path, issue.Message)
+			default:
+				fmt.Fprintf(stdout, "%s: %s\n", issue.File, issue.Message)
+			}
+		}
+		for _, w := range result.Warnings {
+			fmt.Fprintf(stdout, "warning: %s: %s: %s\n", w.File, w.Path, w.Message)
+		}
+		for _, d := range result.DuplicateSpeakers {
+			fmt.Fprintf(stdout, "note: speakers %s and %s may be the same person: %s\n", d.IDs[0], d.IDs[1], d.Reason)
+		}
+		if result.Valid {
+			fmt.Fprintf(stdout, "%s and %s are valid: %d events, %d talks\n", eventsPath, talksPath, len(events), len(talks))
+		} else {
+			fmt.Fprintf(stdout, "found %d issues\n", len(result.Issues))
+		}
+	}
+	if !result.Valid {
+		return 1
+	}
+
+	return 0
+}
+
+// loadIssue converts an error reading or parsing a data file to an issue.
+func loadIssue(file string, err error) validateIssue {
+	issue := validateIssue{
+		File:    file,
+		Message: err.Error(),
+	}
+	var parseErr *data.ParseError
+	if errors.As(err, &parseErr) {
+		issue.Line = parseErr.Line
+		issue.Column = parseErr.Column
+		issue.Message = parseErr.Err.Error()
+	}
+
+	return issue
+}
+
+// dump writes the events, talks and speakers the server would serve with the same flags to stdout
+// as a single JSON document, or to events.json, talks.json and speakers.json in an output directory.
+func dump(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("dump", flag.ExitOnError)
+	var cfg dataConfig
+	cfg.register(fs)
+	outDir := fs.String("out", "", "directory to write events.json, talks.json and speakers.json to, instead of writing to stdout")
+	fs.Parse(args)
+
+	es, err := cfg.newEventService()
+	if err != nil {
+		return err
+	}
+	defer es.Close()
+	fetched, err := es.GetEvents()
+	if err != nil {
+		return err
+	}
+	events := fetched.Events
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].ID < events[j].ID
+	})
+	talks := []data.Talk{}
+	for _, e := range events {
+		talks = append(talks, e.Talks...)
+	}
+	speakers, err := es.GetSpeakers()
+	if err != nil {
+		return err
+	}
+
+	if *outDir == "" {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(struct {
+			Events   []data.Event   `json:"events"`
+			Talks    []data.Talk    `json:"talks"`
+			Speakers []data.Speaker `json:"speakers"`
+		}{events, talks, speakers.Speakers})
+	}
+	if err := writeJSON(filepath.Join(*outDir, "events.json"), data.Events{Events: events}); err != nil {
+		return err
+	}
+
+	if err := writeJSON(filepath.Join(*outDir, "talks.json"), data.Talks{Talks: talks}); err != nil {
+		return err
+	}
+
+	return writeJSON(filepath.Join(*outDir, "speakers.json"), speakers)
+}
+
+// writeJSON writes v as indented JSON to the file at path, creating its directory if needed.
+func writeJSON(path string, v any) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, append(b, '\n'), 0o644)
+}
+
+// importTalks imports the talks in a CSV file into the data the server would serve with the same flags,
+// and writes the imported talks and the rows which could not be imported to stdout.
+// It returns the exit code: 0 if every row was imported, 1 if some were not and 2 on usage and other errors.
+func importTalks(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("import-talks", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var cfg dataConfig
+	cfg.register(fs)
+	eventID := fs.String("event", "", "event to import the talks into, instead of the event of each row")
+	columns := fs.String("columns", "", `field=column pairs separated by commas, mapping talk fields to CSV columns, such as "title=Session,speakers=Speaker names"`)
+	speakerDelimiter := fs.String("speaker-delimiter", ";", "separator of the speakers of a talk")
+	delimiter := fs.String("delimiter", ",", "field delimiter of the CSV")
+	dryRun := fs.Bool("dry-run", false, "check the talks without saving them")
+	jsonOutput := fs.Bool("json", false, "write the report as JSON")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: server import-talks [flags] <talks.csv>")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	// without a persistent repository the imported talks would be lost on exit
+	if !*dryRun && cfg.storeDir == "" && cfg.dbPath == "" {
+		fmt.Fprintln(stderr, "import-talks needs -store or -db to save the talks in, or -dry-run to only check them")
+		return 2
+	}
+	comma, size := utf8.DecodeRuneInString(*delimiter)
+	if size == 0 || size != len(*delimiter) {
+		fmt.Fprintf(stderr, "invalid -delimiter %q: must be a single character\n", *delimiter)
+		return 2
+	}
+	opts := data.TalkImportOptions{
+		SpeakerDelimiter: *speakerDelimiter,
+		Comma:            comma,
+		EventID:          *eventID,
+		DryRun:           *dryRun,
+	}
+	if *columns != "" {
+		parsed, err := data.ParseTalkColumns(*columns)
+		if err != nil {
+			fmt.Fprintf(stderr, "invalid -columns: %v\n", err)
+			return 2
+		}
+		opts.Columns = parsed
+	}
+
+	path := fs.Arg(0)
+	f, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	defer f.Close()
+	es, err := cfg.newEventService()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	defer es.Close()
+	report, err := es.ImportTalks(f, opts)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %v\n", path, err)
+		return 2
+	}
+
+	if *jsonOutput {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+	} else {
+		for _, issue := range report.Issues {
+			if issue.Column != "" {
+				fmt.Fprintf(stdout, "%s:%d: %s: %s\n", path, issue.Line, issue.Column, issue.Message)
+			} else {
+				fmt.Fprintf(stdout, "%s:%d: %s\n", path, issue.Line, issue.Message)
+			}
+		}
+		verb := "imported"
+		if report.DryRun {
+			verb = "would import"
+		}
+		fmt.Fprintf(stdout, "%s %d of %d talks\n", verb, len(report.Imported), report.Rows)
+	}
+	if len(report.Issues) > 0 {
+		return 1
+	}
+
+	return 0
+}
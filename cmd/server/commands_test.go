@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/addetz/testing-strategies-demo/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	// the missing comma is at line 3, column 5
+	malformed := "{\"events\": [\n  {\"id\": \"event-1\"}\n    {\"id\": \"event-2\"}\n]}"
+	unknownEvent := `{"talks": [{"id": "talk-1", "event_id": "event-99", "title": "Talk 1", "speakers": ["Speaker 1"], "date": "01/02/2024", "time": "10:00"}]}`
+	tests := map[string]struct {
+		events     string
+		talks      string
+		args       []string
+		noFiles    bool
+		wantCode   int
+		wantStdout string
+		wantStderr string
+	}{
+		"valid data": {
+			events:     reloadEvents,
+			talks:      reloadTalks,
+			wantCode:   0,
+			wantStdout: "{dir}/events.json and {dir}/talks.json are valid: 1 events, 1 talks\n",
+		},
+		"malformed events": {
+			events:     malformed,
+			talks:      reloadTalks,
+			wantCode:   1,
+			wantStdout: "{dir}/events.json:3:5: invalid character '{' after array element\nfound 1 issues\n",
+		},
+		"talk of an unknown event": {
+			events:     reloadEvents,
+			talks:      unknownEvent,
+			wantCode:   1,
+			wantStdout: "{dir}/talks.json: $.talks[0].event_id: no event for id \"event-99\"\nfound 1 issues\n",
+		},
+		"missing file": {
+			events:     reloadEvents,
+			talks:      reloadTalks,
+			args:       []string{"{dir}/events.json", "{dir}/missing.json"},
+			wantCode:   1,
+			wantStdout: "{dir}/missing.json: open {dir}/missing.json: no such file or directory\nfound 1 issues\n",
+		},
+		"missing arguments": {
+			noFiles:    true,
+			args:       []string{},
+			wantCode:   2,
+			wantStderr: "Usage: server validate",
+		},
+		"too many arguments": {
+			noFiles:    true,
+			args:       []string{"a.json", "b.json", "c.json", "d.json"},
+			wantCode:   2,
+			wantStderr: "Usage: server validate",
+		},
+		"unknown flag": {
+			noFiles:    true,
+			args:       []string{"--yaml", "a.json", "b.json"},
+			wantCode:   2,
+			wantStderr: "flag provided but not defined: -yaml",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir := "."
+			if !tc.noFiles {
+				dir = writeDataDir(t, tc.events, tc.talks)
+			}
+			args := tc.args
+			if args == nil {
+				args = []string{"{dir}/events.json", "{dir}/talks.json"}
+			}
+			var stdout, stderr bytes.Buffer
+
+			code := validate(replaceDir(args, dir), &stdout, &stderr)
+
+			assert.Equal(t, tc.wantCode, code)
+			if tc.wantStdout != "" {
+				assert.Equal(t, replaceDir([]string{tc.wantStdout}, dir)[0], stdout.String())
+			}
+			assert.Contains(t, stderr.String(), tc.wantStderr)
+		})
+	}
+}
+
+func TestValidateJSON(t *testing.T) {
+	malformed := "{\"talks\": [\n  {\"title\": 1}\n]}"
+	overlapping := `{"talks": [
+		{"id": "talk-1", "event_id": "event-1", "title": "Talk 1", "speakers": ["Speaker 1"], "date": "01/02/2024", "time": "10:00", "room": "Main"},
+		{"id": "talk-2", "event_id": "event-1", "title": "Talk 2", "speakers": ["Speaker 2"], "date": "01/02/2024", "time": "10:00", "room": "Main"}
+	]}`
+	tests := map[string]struct {
+		talks        string
+		wantCode     int
+		wantValid    bool
+		wantIssues   []validateIssue
+		wantWarnings int
+	}{
+		"valid data": {
+			talks:      reloadTalks,
+			wantCode:   0,
+			wantValid:  true,
+			wantIssues: []validateIssue{},
+		},
+		"parse error with its position": {
+			talks:    malformed,
+			wantCode: 1,
+			wantIssues: []validateIssue{{
+				File:    "{dir}/talks.json",
+				Line:    2,
+				Column:  13,
+				Message: "cannot unmarshal number",
+			}},
+		},
+		"schedule conflicts are warnings": {
+			talks:        overlapping,
+			wantCode:     0,
+			wantValid:    true,
+			wantIssues:   []validateIssue{},
+			wantWarnings: 1,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir := writeDataDir(t, reloadEvents, tc.talks)
+			var stdout, stderr bytes.Buffer
+
+			code := validate(replaceDir([]string{"--json", "{dir}/events.json", "{dir}/talks.json"}, dir), &stdout, &stderr)
+
+			assert.Equal(t, tc.wantCode, code)
+			assert.Empty(t, stderr.String())
+			// every list is present, even when empty
+			var raw map[string]json.RawMessage
+			require.Nil(t, json.Unmarshal(stdout.Bytes(), &raw))
+			assert.Len(t, raw, 4)
+			for _, key := range []string{"issues", "warnings", "duplicate_speakers"} {
+				assert.NotEqual(t, "null", string(raw[key]), key)
+			}
+			var result validateResult
+			require.Nil(t, json.Unmarshal(stdout.Bytes(), &result))
+			assert.Equal(t, tc.wantValid, result.Valid)
+			require.Len(t, result.Issues, len(tc.wantIssues))
+			for i, want := range tc.wantIssues {
+				got := result.Issues[i]
+				assert.Equal(t, replaceDir([]string{want.File}, dir)[0], got.File)
+				assert.Equal(t, want.Line, got.Line)
+				assert.Equal(t, want.Column, got.Column)
+				assert.Equal(t, want.Path, got.Path)
+				// the wording of JSON errors depends on the Go version
+				assert.Contains(t, got.Message, want.Message)
+			}
+			assert.Len(t, result.Warnings, tc.wantWarnings)
+		})
+	}
+}
+
+func TestDump(t *testing.T) {
+	tests := map[string]struct {
+		args []string
+	}{
+		"embedded data": {},
+		"data directory": {
+			args: []string{"-data-dir", writeDataDir(t, reloadEvents, reloadTalks)},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var dumped bytes.Buffer
+			require.Nil(t, dump(tc.args, &dumped))
+			var doc struct {
+				Events   []json.RawMessage `json:"events"`
+				Talks    []json.RawMessage `json:"talks"`
+				Speakers []json.RawMessage `json:"speakers"`
+			}
+			require.Nil(t, json.Unmarshal(dumped.Bytes(), &doc))
+			require.NotEmpty(t, doc.Events)
+			require.NotEmpty(t, doc.Talks)
+			require.NotEmpty(t, doc.Speakers)
+
+			// the dumped files load back into the same data
+			out := t.TempDir()
+			require.Nil(t, dump(append(tc.args, "-out", out), &bytes.Buffer{}))
+			events, talks, speakers, err := dataSource{dir: out}.load()
+			require.Nil(t, err)
+			assert.Len(t, events, len(doc.Events))
+			assert.Len(t, talks, len(doc.Talks))
+			assert.Len(t, speakers, len(doc.Speakers))
+			assert.Nil(t, data.Validate(events, talks, speakers).Issues)
+			var reloaded bytes.Buffer
+			require.Nil(t, dump([]string{"-data-dir", out}, &reloaded))
+			assert.Equal(t, dumped.String(), reloaded.String())
+		})
+	}
+}
+
+// TestEmbeddedTalkIDs checks that every embedded talk has an ID of its own,
+// so that its URL and calendar UID do not change when its title, date or time is corrected.
+func TestEmbeddedTalkIDs(t *testing.T) {
+	events, talks, speakers, err := dataSource{}.load()
+	require.Nil(t, err)
+
+	report := data.Validate(events, talks, speakers)
+
+	assert.Nil(t, report.Issues)
+	for _, w := range report.Warnings {
+		assert.False(t, strings.HasSuffix(w.Path, ".id"), "%s: %s", w.Path, w.Message)
+	}
+}
+
+func TestDumpClosesDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.db")
+
+	require.Nil(t, dump([]string{"-db", path}, &bytes.Buffer{}))
+
+	// the write-ahead log is only removed once the database is closed
+	_, err := os.Stat(path + "-wal")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestDumpInvalidData(t *testing.T) {
+	dir := writeDataDir(t, reloadEvents, `{"talks": [`)
+
+	out := filepath.Join(dir, "out")
+
+	err := dump([]string{"-data-dir", dir, "-out", out}, &bytes.Buffer{})
+
+	var parseErr *data.ParseError
+	assert.ErrorAs(t, err, &parseErr)
+	_, statErr := os.Stat(out)
+	assert.ErrorIs(t, statErr, os.ErrNotExist)
+}
+
+// replaceDir returns the strings with every {dir} replaced by dir.
+func replaceDir(s []string, dir string) []string {
+	replaced := make([]string, len(s))
+	for i := range s {
+		replaced[i] = strings.ReplaceAll(s[i], "{dir}", dir)
+	}
+
+	return replaced
+}
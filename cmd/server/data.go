@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	_ "embed"
+
+	"github.com/addetz/testing-strategies-demo/data"
+)
+
+//go:embed events.json
+var eventsFile []byte
+
+//go:embed talks.json
+var talksFile []byte
+
+// dataSource describes where to load the events, talks and speakers from.
+// An explicit file path takes precedence over dir,
+// and the embedded files are used when neither is set.
+// Speakers are optional: without a speakers file, they are created from the names in the talks.
+type dataSource struct {
+	eventsPath   string
+	talksPath    string
+	speakersPath string
+	dir          string
+}
+
+// load reads and parses the events, talks and speakers of the data source.
+func (ds dataSource) load() ([]data.Event, []data.Talk, []data.Speaker, error) {
+	var events []data.Event
+	var talks []data.Talk
+	var speakers []data.Speaker
+	var err error
+	if path := ds.path(ds.eventsPath, "events.json"); path != "" {
+		events, err = data.LoadEvents(path)
+	} else {
+		events, err = data.ParseEvents("embedded events.json", eventsFile)
+	}
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if path := ds.path(ds.talksPath, "talks.json"); path != "" {
+		talks, err = data.LoadTalks(path)
+	} else {
+		talks, err = data.ParseTalks("embedded talks.json", talksFile)
+	}
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if path := ds.speakersFile(); path != "" {
+		speakers, err = data.LoadSpeakers(path)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	return events, talks, speakers, nil
+}
+
+// speakersFile returns the speakers file to load, or an empty string if there is none.
+// A speakers.json file in dir is only loaded if it exists.
+func (ds dataSource) speakersFile() string {
+	if ds.speakersPath != "" {
+		return ds.speakersPath
+	}
+	if ds.dir == "" {
+		return ""
+	}
+	path := filepath.Join(ds.dir, "speakers.json")
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		return ""
+	}
+
+	return path
+}
+
+// files returns the paths of the files loaded from disk, if any.
+func (ds dataSource) files() []string {
+	var files []string
+	if path := ds.path(ds.eventsPath, "events.json"); path != "" {
+		files = append(files, path)
+	}
+	if path := ds.path(ds.talksPath, "talks.json"); path != "" {
+		files = append(files, path)
+	}
+	if path := ds.speakersFile(); path != "" {
+		files = append(files, path)
+	}
+
+	return files
+}
+
+// path returns the file to load, or an empty string if the embedded file should be used.
+func (ds dataSource) path(explicit, name string) string {
+	if explicit != "" {
+		return explicit
+	}
+	if ds.dir != "" {
+		return filepath.Join(ds.dir, name)
+	}
+
+	return ""
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/addetz/testing-strategies-demo/data"
+	"github.com/addetz/testing-strategies-demo/handlers"
+)
+
+// reloader swaps the handler's event service for one built from freshly loaded data
+// whenever the data files change or the process receives SIGHUP.
+// Data which fails to load or to pass validation is rejected and the current service is kept,
+// whether or not the server was started in strict mode.
+// Changes made through the API are lost on reload, which is logged.
+type reloader struct {
+	cfg     dataConfig
+	handler *handlers.Handler
+	// current is the service being served, and loaded when its data was loaded
+	current *data.EventService
+	loaded  time.Time
+	// modTimes holds the last seen modification time of each watched file
+	modTimes map[string]time.Time
+}
+
+// newReloader returns a reloader for the data of cfg, which is loaded into the handler's current service.
+func newReloader(cfg dataConfig, handler *handlers.Handler, current *data.EventService) *reloader {
+	// invalid talks are only dropped at startup: a reload never swaps in less data than was written
+	cfg.strict = true
+	r := &reloader{
+		cfg:      cfg,
+		handler:  handler,
+		current:  current,
+		loaded:   current.LastModified(),
+		modTimes: make(map[string]time.Time),
+	}
+	r.changed()
+
+	return r
+}
+
+// run polls the data files every interval and listens for SIGHUP, reloading on either.
+// It never returns.
+func (r *reloader) run(interval time.Duration) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	r.watch(ticker.C, hup, nil)
+}
+
+// watch reloads whenever hup receives a signal, or ticks a time and the data files have changed,
+// until done is closed.
+func (r *reloader) watch(ticks <-chan time.Time, hup <-chan os.Signal, done <-chan struct{}) {
+	for {
+		select {
+		case <-done:
+			return
+		case <-hup:
+			log.Println("Received SIGHUP, reloading data")
+			r.changed()
+			r.reload()
+		case <-ticks:
+			if r.changed() {
+				log.Println("Data files changed, reloading data")
+				r.reload()
+			}
+		}
+	}
+}
+
+// changed records the modification times of the watched files
+// and reports whether any of them differs from the last call.
+func (r *reloader) changed() bool {
+	changed := false
+	for _, path := range r.cfg.source.files() {
+		var modTime time.Time
+		if info, err := os.Stat(path); err == nil {
+			modTime = info.ModTime()
+		}
+		if !modTime.Equal(r.modTimes[path]) {
+			changed = true
+			r.modTimes[path] = modTime
+		}
+	}
+
+	return changed
+}
+
+// reload builds a new event service from the data source, which must pass validation, and swaps it in,
+// or logs why the data was rejected.
+func (r *reloader) reload() {
+	es, err := r.cfg.newEventService()
+	if err != nil {
+		log.Printf("Rejected reload, keeping current data: %v\n", err)
+		return
+	}
+	if modified := r.current.LastModified(); modified.After(r.loaded) {
+		log.Printf("Discarding changes made through the API since the data was loaded, last at %s\n", modified.Format(time.RFC3339))
+	}
+	r.handler.SetEventService(es)
+	r.current, r.loaded = es, es.LastModified()
+	log.Printf("Reloaded data from %v\n", r.cfg.source.files())
+}
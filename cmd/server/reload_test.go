@@ -0,0 +1,211 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/addetz/testing-strategies-demo/data"
+	"github.com/addetz/testing-strategies-demo/handlers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const reloadEvents = `{"events": [{"id": "event-1", "name": "Event 1", "date_start": "01/02/2024", "date_end": "02/02/2024", "location": "Amsterdam"}]}`
+
+const reloadTalks = `{"talks": [{"id": "talk-1", "event_id": "event-1", "title": "Talk 1", "speakers": ["Speaker 1"], "date": "01/02/2024", "time": "10:00"}]}`
+
+// writeDataDir writes events.json and talks.json with the given contents to a temporary directory
+// and returns the directory.
+func writeDataDir(t *testing.T, events, talks string) string {
+	t.Helper()
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "events.json"), events)
+	writeFile(t, filepath.Join(dir, "talks.json"), talks)
+
+	return dir
+}
+
+// writeFile writes the contents to path, moving its modification time forward
+// so that the change is seen even on file systems with coarse timestamps.
+func writeFile(t *testing.T, path, contents string) {
+	t.Helper()
+	modTime := time.Now()
+	if info, err := os.Stat(path); err == nil {
+		modTime = info.ModTime().Add(time.Second)
+	}
+	require.Nil(t, os.WriteFile(path, []byte(contents), 0o644))
+	require.Nil(t, os.Chtimes(path, modTime, modTime))
+}
+
+// newTestReloader returns a reloader for the data in dir, serving the data loaded from it.
+func newTestReloader(t *testing.T, dir string, strict bool) *reloader {
+	t.Helper()
+	cfg := dataConfig{source: dataSource{dir: dir}, strict: strict}
+	es, err := cfg.newEventService()
+	require.Nil(t, err)
+
+	return newReloader(cfg, handlers.NewHandler(es), es)
+}
+
+// eventNames returns the names of the events served by the reloader.
+func eventNames(t *testing.T, r *reloader) []string {
+	t.Helper()
+	events, err := r.current.GetEvents()
+	require.Nil(t, err)
+	var names []string
+	for _, e := range events.Events {
+		names = append(names, e.Name)
+	}
+
+	return names
+}
+
+func TestReloaderChanged(t *testing.T) {
+	dir := writeDataDir(t, reloadEvents, reloadTalks)
+	r := newTestReloader(t, dir, false)
+	assert.False(t, r.changed())
+
+	writeFile(t, filepath.Join(dir, "talks.json"), reloadTalks)
+	assert.True(t, r.changed())
+	assert.False(t, r.changed())
+
+	// an optional speakers file is watched once it exists
+	writeFile(t, filepath.Join(dir, "speakers.json"), `{"speakers": []}`)
+	assert.True(t, r.changed())
+	assert.False(t, r.changed())
+
+	require.Nil(t, os.Remove(filepath.Join(dir, "events.json")))
+	assert.True(t, r.changed())
+}
+
+func TestReloaderReload(t *testing.T) {
+	renamed := `{"events": [{"id": "event-1", "name": "Renamed", "date_start": "01/02/2024", "date_end": "02/02/2024", "location": "Amsterdam"}]}`
+	// the talk is after the event, which fails validation
+	outside := `{"talks": [{"id": "talk-1", "event_id": "event-1", "title": "Talk 1", "speakers": ["Speaker 1"], "date": "05/02/2024", "time": "10:00"}]}`
+	tests := map[string]struct {
+		strict   bool
+		events   string
+		talks    string
+		reloaded bool
+	}{
+		"valid data is reloaded": {
+			events:   renamed,
+			talks:    reloadTalks,
+			reloaded: true,
+		},
+		"valid data is reloaded in strict mode": {
+			strict:   true,
+			events:   renamed,
+			talks:    reloadTalks,
+			reloaded: true,
+		},
+		"malformed data is rejected": {
+			events: `{"events": [`,
+			talks:  reloadTalks,
+		},
+		"invalid data is rejected without strict mode": {
+			events: renamed,
+			talks:  outside,
+		},
+		"invalid data is rejected in strict mode": {
+			strict: true,
+			events: renamed,
+			talks:  outside,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir := writeDataDir(t, reloadEvents, reloadTalks)
+			r := newTestReloader(t, dir, tc.strict)
+			before := r.current
+			writeFile(t, filepath.Join(dir, "events.json"), tc.events)
+			writeFile(t, filepath.Join(dir, "talks.json"), tc.talks)
+
+			r.reload()
+
+			if !tc.reloaded {
+				assert.Same(t, before, r.current)
+				assert.Equal(t, []string{"Event 1"}, eventNames(t, r))
+				return
+			}
+			assert.NotSame(t, before, r.current)
+			assert.Equal(t, []string{"Renamed"}, eventNames(t, r))
+		})
+	}
+}
+
+func TestReloaderReloadDiscardsAPIChanges(t *testing.T) {
+	dir := writeDataDir(t, reloadEvents, reloadTalks)
+	r := newTestReloader(t, dir, false)
+	_, err := r.current.CreateEvent(data.Event{
+		ID:        "event-2",
+		Name:      "Event 2",
+		DateStart: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		DateEnd:   time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		Location:  "Barcelona",
+	})
+	require.Nil(t, err)
+	assert.ElementsMatch(t, []string{"Event 1", "Event 2"}, eventNames(t, r))
+
+	r.reload()
+
+	assert.Equal(t, []string{"Event 1"}, eventNames(t, r))
+	assert.Equal(t, r.current.LastModified(), r.loaded)
+}
+
+func TestReloaderWatch(t *testing.T) {
+	tests := map[string]struct {
+		change   bool
+		signal   bool
+		reloaded bool
+	}{
+		"tick without changes does not reload": {},
+		"tick after a change reloads": {
+			change:   true,
+			reloaded: true,
+		},
+		"SIGHUP reloads without changes": {
+			signal:   true,
+			reloaded: true,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir := writeDataDir(t, reloadEvents, reloadTalks)
+			r := newTestReloader(t, dir, false)
+			before := r.current
+			if tc.change {
+				writeFile(t, filepath.Join(dir, "events.json"), reloadEvents)
+			}
+			ticks := make(chan time.Time)
+			hup := make(chan os.Signal)
+			done := make(chan struct{})
+			var wg sync.WaitGroup
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				r.watch(ticks, hup, done)
+			}()
+
+			// the sends are unbuffered, so they return once watch has received them
+			if tc.signal {
+				hup <- syscall.SIGHUP
+			} else {
+				ticks <- time.Now()
+			}
+			close(done)
+			wg.Wait()
+
+			if tc.reloaded {
+				assert.NotSame(t, before, r.current)
+			} else {
+				assert.Same(t, before, r.current)
+			}
+			assert.False(t, r.changed())
+		})
+	}
+}
@@ -1,64 +1,325 @@
 package main
 
 import (
-	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
-
-	_ "embed"
+	"strings"
+	"time"
 
 	"github.com/addetz/testing-strategies-demo/data"
 	"github.com/addetz/testing-strategies-demo/handlers"
 	"github.com/gorilla/mux"
 )
 
-//go:embed events.json
-var eventsFile []byte
+const usage = `Usage:
+  server [serve] [flags]
+        run the conference talks server (default)
+  server validate [--json] <events.json> <talks.json> [speakers.json]
+        check data files for problems without starting the server
+  server dump [flags]
+        print the events, talks and speakers the server would serve with the same flags
+  server import-talks [flags] <talks.csv>
+        import talks from CSV into the data stored with -store or -db
 
-//go:embed talks.json
-var talksFile []byte
+Run "server <command> -h" for the flags of each command.
+`
 
 func main() {
+	cmd, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd, args = args[0], args[1:]
+	}
+	switch cmd {
+	case "serve":
+		if err := serve(args); err != nil {
+			log.Fatal(err)
+		}
+	case "validate":
+		os.Exit(validate(args, os.Stdout, os.Stderr))
+	case "dump":
+		if err := dump(args, os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+	case "import-talks":
+		os.Exit(importTalks(args, os.Stdout, os.Stderr))
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", cmd, usage)
+		os.Exit(2)
+	}
+}
+
+// serve runs the server until it fails.
+func serve(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	var cfg dataConfig
+	cfg.register(fs)
+	reloadInterval := fs.Duration("reload-interval", 2*time.Second, "how often to check data files loaded from disk for changes")
+	cache := defaultCachePolicy()
+	fs.Func("cache-control", "Cache-Control header of a route as route=policy, such as '/events/{id}/schedule=public, max-age=60', or '*=policy' for the other routes; repeatable", cache.set)
+	v1DeprecatedAt, v1Sunset := dateFlag(defaultV1DeprecatedAt), dateFlag(defaultV1Sunset)
+	fs.Var(&v1DeprecatedAt, "v1-deprecated-at", "`date` v1 was deprecated at, as YYYY-MM-DD, sent in its Deprecation header")
+	fs.Var(&v1Sunset, "v1-sunset", "`date` v1 will be removed at, as YYYY-MM-DD, sent in its Sunset header")
+	fs.Parse(args)
+	if time.Time(v1Sunset).Before(time.Time(v1DeprecatedAt)) {
+		return fmt.Errorf("-v1-sunset %s is before -v1-deprecated-at %s", &v1Sunset, &v1DeprecatedAt)
+	}
+
 	log.Println("Initializing Conference Talks Server ... ")
 	port := "8000"
 	if p := os.Getenv("SERVER_PORT"); p != "" {
 		port = p
 	}
-	events, talks := importData()
-	eventService, err := data.NewEventService(events, talks)
+	eventService, err := cfg.newEventService()
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
+	defer eventService.Close()
 	handler := handlers.NewHandler(eventService)
-	router := configureRouter(handler)
+	adminToken := os.Getenv("ADMIN_TOKEN")
+	if adminToken == "" {
+		log.Println("ADMIN_TOKEN is not set, so the admin routes are disabled")
+	}
+	router := configureRouter(handler, routerConfig{
+		cache:          cache,
+		adminToken:     adminToken,
+		v1DeprecatedAt: time.Time(v1DeprecatedAt),
+		v1Sunset:       time.Time(v1Sunset),
+	})
+	// a persistent repository is the source of truth once seeded, so only in-memory data is reloaded
+	if len(cfg.source.files()) > 0 && cfg.storeDir == "" && cfg.dbPath == "" {
+		log.Printf("Watching %v for changes; changes made through the API are discarded on reload\n", cfg.source.files())
+		go newReloader(cfg, handler, eventService).run(*reloadInterval)
+	}
 
 	log.Printf("Server listening on :%s...\n", port)
-	log.Fatal(http.ListenAndServe(":"+port, router))
+	return http.ListenAndServe(":"+port, router)
+}
+
+// v1 is deprecated in favour of v2 and is removed at sunset,
+// unless configured otherwise with the -v1-deprecated-at and -v1-sunset flags.
+var (
+	defaultV1DeprecatedAt = time.Date(2026, time.October, 18, 0, 0, 0, 0, time.UTC)
+	defaultV1Sunset       = time.Date(2027, time.April, 18, 0, 0, 0, 0, time.UTC)
+)
+
+// dateFlag is a flag holding a date in the YYYY-MM-DD format, at midnight UTC.
+type dateFlag time.Time
+
+func (d *dateFlag) String() string {
+	return time.Time(*d).Format(time.DateOnly)
+}
+
+func (d *dateFlag) Set(value string) error {
+	parsed, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return fmt.Errorf("invalid date %q: expected YYYY-MM-DD", value)
+	}
+	*d = dateFlag(parsed)
+
+	return nil
+}
+
+// routeHandler is the set of handlers bound by registerRoutes,
+// implemented by the handlers of each API version.
+type routeHandler interface {
+	GetEventsHandler(w http.ResponseWriter, r *http.Request)
+	CreateEventHandler(w http.ResponseWriter, r *http.Request)
+	UpdateEventHandler(w http.ResponseWriter, r *http.Request)
+	PatchEventHandler(w http.ResponseWriter, r *http.Request)
+	DeleteEventHandler(w http.ResponseWriter, r *http.Request)
+	GetEventTalksHandler(w http.ResponseWriter, r *http.Request)
+	CreateTalkHandler(w http.ResponseWriter, r *http.Request)
+	GetTalkHandler(w http.ResponseWriter, r *http.Request)
+	UpdateTalkHandler(w http.ResponseWriter, r *http.Request)
+	DeleteTalkHandler(w http.ResponseWriter, r *http.Request)
+	ImportTalksHandler(w http.ResponseWriter, r *http.Request)
+	SearchHandler(w http.ResponseWriter, r *http.Request)
+	GetSpeakersHandler(w http.ResponseWriter, r *http.Request)
+	GetSpeakerHandler(w http.ResponseWriter, r *http.Request)
+	GetSpeakerTalksHandler(w http.ResponseWriter, r *http.Request)
+	GetEventRoomsHandler(w http.ResponseWriter, r *http.Request)
+	GetEventTracksHandler(w http.ResponseWriter, r *http.Request)
+	GetEventScheduleHandler(w http.ResponseWriter, r *http.Request)
+	GetEventConflictsHandler(w http.ResponseWriter, r *http.Request)
+	GetEventCalendarHandler(w http.ResponseWriter, r *http.Request)
+	GetEventTalksCalendarHandler(w http.ResponseWriter, r *http.Request)
+}
+
+// cachePolicy is the handlers.CachePolicy configured with the -cache-control flag.
+type cachePolicy handlers.CachePolicy
+
+// defaultCachePolicy returns the cache policy of the read routes unless configured otherwise.
+// Clients revalidate with the ETag before reusing most responses,
+// while schedules and calendars, which attendees poll, may be reused for a while.
+func defaultCachePolicy() *cachePolicy {
+	return &cachePolicy{
+		Default: "no-cache",
+		Routes: map[string]string{
+			"/events/{id}/schedule":     "public, max-age=60",
+			"/events/{id}/calendar.ics": "public, max-age=300",
+			"/events/{id}/talks.ics":    "public, max-age=300",
+		},
+	}
+}
+
+// set configures the policy of a route from a route=policy flag value.
+func (p *cachePolicy) set(value string) error {
+	route, policy, ok := strings.Cut(value, "=")
+	route = strings.TrimSpace(route)
+	if !ok || route == "" {
+		return fmt.Errorf("invalid cache policy %q: expected route=policy", value)
+	}
+	policy = strings.TrimSpace(policy)
+	if route == "*" {
+		p.Default = policy
+		return nil
+	}
+	p.Routes[route] = policy
+
+	return nil
 }
 
-// configureRouter configures the routes of this server and binds handler functions to them
-func configureRouter(handler *handlers.Handler) *mux.Router {
+// routerConfig holds the settings of the routes of this server.
+type routerConfig struct {
+	cache *cachePolicy
+	// adminToken is the bearer token of the admin routes, which are not served without one
+	adminToken string
+	// v1DeprecatedAt and v1Sunset are the dates sent in the Deprecation and Sunset headers of v1
+	v1DeprecatedAt time.Time
+	v1Sunset       time.Time
+}
+
+// configureRouter configures the routes of this server and binds handler functions to them.
+// The v1 API is served under /v1 and, for existing clients such as the pinned Pact consumers, at the root.
+// It returns an event's talks from /events/{id}, while v2 under /v2 returns the event itself.
+// Read routes answer HEAD and conditional requests and set the Cache-Control headers of the cache policy.
+func configureRouter(handler *handlers.Handler, cfg routerConfig) *mux.Router {
 	router := mux.NewRouter().StrictSlash(true)
+	router.NotFoundHandler = http.HandlerFunc(handlers.NotFoundHandler)
+	router.MethodNotAllowedHandler = http.HandlerFunc(handlers.MethodNotAllowedHandler)
+	deprecated := handlers.Deprecated(cfg.v1DeprecatedAt, cfg.v1Sunset, "/v2")
+	conditional := handler.Conditional(handlers.CachePolicy(*cfg.cache))
+
+	// the archive has its own version, so the admin routes are not versioned;
+	// since they expose and replace all the data, they are only served to holders of the admin token
+	if cfg.adminToken != "" {
+		admin := router.PathPrefix("/admin").Subrouter()
+		admin.Use(handlers.RequireToken(cfg.adminToken))
+		admin.Methods("GET").Path("/export").Handler(http.HandlerFunc(handler.ExportHandler))
+		admin.Methods("POST").Path("/import").Handler(http.HandlerFunc(handler.ImportHandler))
+	}
+
+	v2 := router.PathPrefix("/v2").Subrouter()
+	v2.Use(conditional)
+	v2Handler := handler.V2()
+	v2.Methods("GET", "HEAD").Path("/events/{id}").Handler(http.HandlerFunc(v2Handler.GetEventHandler))
+	registerRoutes(v2, v2Handler)
+
+	v1 := router.PathPrefix("/v1").Subrouter()
+	v1.Use(deprecated, conditional)
+	registerV1Routes(v1, handler)
 
-	router.Methods("GET").Path("/events").Handler(http.HandlerFunc(handler.GetEventsHandler))
-	router.Methods("GET").Path("/events/{id}").Handler(http.HandlerFunc(handler.GetEventTalksHandler))
+	// registered last so that the version prefixes take precedence
+	root := router.NewRoute().Subrouter()
+	root.Use(deprecated, conditional)
+	registerV1Routes(root, handler)
 
 	return router
 }
 
-func importData() ([]data.Event, []data.Talk) {
-	var events data.Events
-	var talks data.Talks
+// registerV1Routes binds the routes of the v1 API.
+func registerV1Routes(router *mux.Router, handler *handlers.Handler) {
+	router.Methods("GET", "HEAD").Path("/events/{id}").Handler(http.HandlerFunc(handler.GetEventTalksHandler))
+	registerRoutes(router, handler)
+}
+
+// registerRoutes binds the routes shared by all API versions.
+func registerRoutes(router *mux.Router, handler routeHandler) {
+	router.Methods("GET", "HEAD").Path("/events").Handler(http.HandlerFunc(handler.GetEventsHandler))
+	router.Methods("POST").Path("/events").Handler(http.HandlerFunc(handler.CreateEventHandler))
+	router.Methods("PUT").Path("/events/{id}").Handler(http.HandlerFunc(handler.UpdateEventHandler))
+	router.Methods("PATCH").Path("/events/{id}").Handler(http.HandlerFunc(handler.PatchEventHandler))
+	router.Methods("DELETE").Path("/events/{id}").Handler(http.HandlerFunc(handler.DeleteEventHandler))
+	router.Methods("GET", "HEAD").Path("/events/{id}/talks").Handler(http.HandlerFunc(handler.GetEventTalksHandler))
+	router.Methods("POST").Path("/events/{id}/talks").Handler(http.HandlerFunc(handler.CreateTalkHandler))
+	router.Methods("POST").Path("/events/{id}/talks:import").Handler(http.HandlerFunc(handler.ImportTalksHandler))
+	router.Methods("GET", "HEAD").Path("/events/{id}/talks/{talkID}").Handler(http.HandlerFunc(handler.GetTalkHandler))
+	router.Methods("PUT").Path("/events/{id}/talks/{talkID}").Handler(http.HandlerFunc(handler.UpdateTalkHandler))
+	router.Methods("DELETE").Path("/events/{id}/talks/{talkID}").Handler(http.HandlerFunc(handler.DeleteTalkHandler))
+	router.Methods("GET", "HEAD").Path("/events/{id}/rooms").Handler(http.HandlerFunc(handler.GetEventRoomsHandler))
+	router.Methods("GET", "HEAD").Path("/events/{id}/tracks").Handler(http.HandlerFunc(handler.GetEventTracksHandler))
+	router.Methods("GET", "HEAD").Path("/events/{id}/schedule").Handler(http.HandlerFunc(handler.GetEventScheduleHandler))
+	router.Methods("GET", "HEAD").Path("/events/{id}/conflicts").Handler(http.HandlerFunc(handler.GetEventConflictsHandler))
+	router.Methods("GET", "HEAD").Path("/events/{id}/calendar.ics").Handler(http.HandlerFunc(handler.GetEventCalendarHandler))
+	router.Methods("GET", "HEAD").Path("/events/{id}/talks.ics").Handler(http.HandlerFunc(handler.GetEventTalksCalendarHandler))
+	router.Methods("GET", "HEAD").Path("/search").Handler(http.HandlerFunc(handler.SearchHandler))
+	router.Methods("GET", "HEAD").Path("/speakers").Handler(http.HandlerFunc(handler.GetSpeakersHandler))
+	router.Methods("GET", "HEAD").Path("/speakers/{id}").Handler(http.HandlerFunc(handler.GetSpeakerHandler))
+	router.Methods("GET", "HEAD").Path("/speakers/{id}/talks").Handler(http.HandlerFunc(handler.GetSpeakerTalksHandler))
+}
+
+// dataConfig holds the flags which select where the data is loaded from and stored in.
+type dataConfig struct {
+	source   dataSource
+	storeDir string
+	dbPath   string
+	strict   bool
+}
+
+// register adds the data flags to fs.
+func (cfg *dataConfig) register(fs *flag.FlagSet) {
+	fs.StringVar(&cfg.storeDir, "store", "", "directory to persist events and talks in as JSON files")
+	fs.StringVar(&cfg.dbPath, "db", "", "SQLite database file to persist events and talks in")
+	fs.StringVar(&cfg.source.eventsPath, "events", "", "events JSON file to load instead of the embedded one")
+	fs.StringVar(&cfg.source.talksPath, "talks", "", "talks JSON file to load instead of the embedded one")
+	fs.StringVar(&cfg.source.speakersPath, "speakers", "", "speakers JSON file with the details of the speakers named in the talks")
+	fs.StringVar(&cfg.source.dir, "data-dir", "", "directory containing events.json, talks.json and optionally speakers.json to load instead of the embedded ones")
+	fs.BoolVar(&cfg.strict, "strict", false, "refuse to start if the data fails validation, instead of dropping invalid talks")
+}
 
-	err := json.Unmarshal(eventsFile, &events)
+// newEventService returns an EventService backed by a SQLite database at dbPath
+// or a file repository in storeDir, or by memory if neither is given.
+// A persistent repository is seeded with the data from source if it is empty.
+// In strict mode, data which fails validation is an error.
+func (cfg dataConfig) newEventService() (*data.EventService, error) {
+	events, talks, speakers, err := cfg.source.load()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.strict {
+		if report := data.Validate(events, talks, speakers); !report.Valid() {
+			return nil, &data.ValidationError{Report: report}
+		}
+	}
+	var repo data.EventRepository
+	switch {
+	case cfg.storeDir != "" && cfg.dbPath != "":
+		return nil, errors.New("only one of -store and -db can be set")
+	case cfg.dbPath != "":
+		repo, err = data.NewSQLiteRepository(cfg.dbPath)
+	case cfg.storeDir != "":
+		repo, err = data.NewFileRepository(cfg.storeDir)
+	default:
+		return data.NewEventService(events, talks, data.WithSpeakers(speakers))
+	}
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
-	err = json.Unmarshal(talksFile, &talks)
+	seeded, err := data.Seed(repo, events, talks, speakers)
 	if err != nil {
-		log.Fatal(err)
+		if c, ok := repo.(io.Closer); ok {
+			c.Close()
+		}
+		return nil, err
+	}
+	if seeded {
+		log.Println("Seeded empty repository with the loaded data")
 	}
 
-	return events.Events, talks.Talks
+	return data.NewEventServiceWithRepository(repo), nil
 }
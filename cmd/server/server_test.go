@@ -0,0 +1,127 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/addetz/testing-strategies-demo/data"
+	"github.com/addetz/testing-strategies-demo/handlers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDateFlag(t *testing.T) {
+	tests := map[string]struct {
+		value       string
+		expected    time.Time
+		expectedErr string
+	}{
+		"date": {
+			value:    "2027-10-18",
+			expected: time.Date(2027, time.October, 18, 0, 0, 0, 0, time.UTC),
+		},
+		"legacy format": {
+			value:       "18/10/2027",
+			expectedErr: `invalid date "18/10/2027": expected YYYY-MM-DD`,
+		},
+		"invalid date": {
+			value:       "2027-02-30",
+			expectedErr: `invalid date "2027-02-30": expected YYYY-MM-DD`,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			d := dateFlag(defaultV1Sunset)
+
+			err := d.Set(tc.value)
+
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+				assert.Equal(t, defaultV1Sunset, time.Time(d))
+				return
+			}
+			require.Nil(t, err)
+			assert.Equal(t, tc.expected, time.Time(d))
+			assert.Equal(t, tc.value, d.String())
+		})
+	}
+}
+
+func TestConfigureRouterDeprecation(t *testing.T) {
+	es, err := data.NewEventService([]data.Event{}, []data.Talk{})
+	require.Nil(t, err)
+	router := configureRouter(handlers.NewHandler(es), routerConfig{
+		cache:          defaultCachePolicy(),
+		v1DeprecatedAt: time.Date(2027, time.January, 1, 0, 0, 0, 0, time.UTC),
+		v1Sunset:       time.Date(2027, time.July, 1, 0, 0, 0, 0, time.UTC),
+	})
+	tests := map[string]struct {
+		path        string
+		deprecation string
+		sunset      string
+	}{
+		"v1": {
+			path:        "/v1/events",
+			deprecation: "@1798761600",
+			sunset:      "Thu, 01 Jul 2027 00:00:00 GMT",
+		},
+		"v1 at the root": {
+			path:        "/events",
+			deprecation: "@1798761600",
+			sunset:      "Thu, 01 Jul 2027 00:00:00 GMT",
+		},
+		"v2": {
+			path: "/v2/events",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+
+			router.ServeHTTP(rr, httptest.NewRequest("GET", tc.path, nil))
+
+			require.Equal(t, http.StatusOK, rr.Code)
+			assert.Equal(t, tc.deprecation, rr.Header().Get("Deprecation"))
+			assert.Equal(t, tc.sunset, rr.Header().Get("Sunset"))
+		})
+	}
+}
+
+func TestConfigureRouterHead(t *testing.T) {
+	es, err := data.NewEventService([]data.Event{{ID: "event-1", DateStart: time.Now(), DateEnd: time.Now()}}, []data.Talk{})
+	require.Nil(t, err)
+	router := configureRouter(handlers.NewHandler(es), routerConfig{cache: defaultCachePolicy()})
+	tests := map[string]struct {
+		path   string
+		status int
+	}{
+		"v1 events":     {path: "/v1/events", status: http.StatusOK},
+		"root events":   {path: "/events", status: http.StatusOK},
+		"v2 event":      {path: "/v2/events/event-1", status: http.StatusOK},
+		"schedule":      {path: "/events/event-1/schedule", status: http.StatusOK},
+		"calendar":      {path: "/v2/events/event-1/calendar.ics", status: http.StatusOK},
+		"unknown event": {path: "/v2/events/event-9", status: http.StatusNotFound},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			get := httptest.NewRecorder()
+			router.ServeHTTP(get, httptest.NewRequest("GET", tc.path, nil))
+			head := httptest.NewRecorder()
+
+			router.ServeHTTP(head, httptest.NewRequest("HEAD", tc.path, nil))
+
+			require.Equal(t, tc.status, head.Code)
+			assert.Empty(t, head.Body.String())
+			assert.Equal(t, get.Header().Get("Content-Type"), head.Header().Get("Content-Type"))
+			assert.Equal(t, get.Header().Get("ETag"), head.Header().Get("ETag"))
+			assert.Equal(t, get.Header().Get("Cache-Control"), head.Header().Get("Cache-Control"))
+			if tc.status == http.StatusOK {
+				assert.NotEmpty(t, head.Header().Get("ETag"))
+				assert.Equal(t, strconv.Itoa(get.Body.Len()), head.Header().Get("Content-Length"))
+			}
+		})
+	}
+}
@@ -6,7 +6,7 @@ import (
 	"os"
 	"testing"
 
-	"github.com/addetz/testing-strategies-demo/data"
+	"github.com/addetz/testing-strategies-demo/handlers"
 	"github.com/pact-foundation/pact-go/dsl"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -16,7 +16,7 @@ func TestConsumerEvents(t *testing.T) {
 	if os.Getenv("CONTRACT") == "" {
 		t.Skip("Skipping TestConsumerEvents in short mode.")
 	}
-	expectedEvents := []data.Event{
+	expectedEvents := []handlers.EventV1{
 		{
 			ID:        "ewit-2023",
 			Name:      "European Women in Tech",
@@ -73,7 +73,7 @@ func TestConsumerEvents(t *testing.T) {
 			}).
 			WillRespondWith(dsl.Response{
 				Status: http.StatusOK,
-				Body: dsl.Like(data.Events{
+				Body: dsl.Like(handlers.EventsV1{
 					Events: expectedEvents,
 				}),
 			})
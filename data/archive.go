@@ -0,0 +1,516 @@
+package data
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"path"
+	"sort"
+	"time"
+)
+
+// ArchiveVersion is the version of the archive format written by Export.
+// It is increased whenever a change to the format would break reading older archives.
+const ArchiveVersion = 1
+
+// manifestFileName is the file of a tar.gz archive holding its version and export time.
+const manifestFileName = "manifest.json"
+
+// MaxArchiveFileSize limits the size of each file read from a tar.gz archive,
+// so that a malicious archive cannot exhaust memory.
+const MaxArchiveFileSize = 64 << 20
+
+// Archive is the full dataset of an EventService: every event, talk and speaker.
+// Its JSON has the fields of events.json, talks.json and speakers.json, together with the version of the format.
+type Archive struct {
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exported_at"`
+	Events     []Event   `json:"events"`
+	Talks      []Talk    `json:"talks"`
+	Speakers   []Speaker `json:"speakers"`
+}
+
+// archiveManifest is the content of manifest.json in a tar.gz archive.
+type archiveManifest struct {
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exported_at"`
+}
+
+// Export returns the full dataset of the service as an archive.
+// Events and speakers are sorted by ID, and talks by event ID and then in the order of their event.
+func (es *EventService) Export() (*Archive, error) {
+	es.mu.RLock()
+	defer es.mu.RUnlock()
+	events, err := es.repo.List()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].ID < events[j].ID
+	})
+	talks := []Talk{}
+	for i, e := range events {
+		talks = append(talks, e.Talks...)
+		events[i].Talks = nil
+	}
+	speakers, err := es.repo.ListSpeakers()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(speakers, func(i, j int) bool {
+		return speakers[i].ID < speakers[j].ID
+	})
+
+	return &Archive{
+		Version:    ArchiveVersion,
+		ExportedAt: time.Now().UTC().Truncate(time.Second),
+		Events:     events,
+		Talks:      talks,
+		Speakers:   speakers,
+	}, nil
+}
+
+// ParseArchive decodes an archive from its JSON in b.
+// The name is used to identify the data in errors.
+func ParseArchive(name string, b []byte) (*Archive, error) {
+	var a Archive
+	if err := decodeJSON(name, b, &a); err != nil {
+		return nil, newError(ErrInvalidArchive, "%v", err)
+	}
+
+	return &a, nil
+}
+
+// WriteTarGz writes the archive as a gzipped tar file holding manifest.json, events.json, talks.json and speakers.json,
+// which have the same format as the data files the server loads.
+func (a Archive) WriteTarGz(w io.Writer) error {
+	files := []struct {
+		name string
+		v    any
+	}{
+		{manifestFileName, archiveManifest{Version: a.Version, ExportedAt: a.ExportedAt}},
+		{eventsFileName, Events{Events: a.Events}},
+		{talksFileName, Talks{Talks: a.Talks}},
+		{speakersFileName, Speakers{Speakers: a.Speakers}},
+	}
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	for _, f := range files {
+		b, err := json.MarshalIndent(f.v, "", "  ")
+		if err != nil {
+			return err
+		}
+		b = append(b, '\n')
+		header := &tar.Header{
+			Name:    f.name,
+			Mode:    0o644,
+			Size:    int64(len(b)),
+			ModTime: a.ExportedAt,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := tw.Write(b); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	return gw.Close()
+}
+
+// ReadTarGz reads an archive from a gzipped tar file, which must hold an events.json and a talks.json file
+// and may hold a speakers.json and a manifest.json file, in any directory.
+// Without a manifest, the files are taken to be of the current version,
+// so that the data files the server loads can be imported once packed.
+func ReadTarGz(r io.Reader) (*Archive, error) {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, newError(ErrInvalidArchive, "invalid tar.gz: %w", err)
+	}
+	defer gr.Close()
+	files := make(map[string][]byte)
+	tr := tar.NewReader(gr)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, newError(ErrInvalidArchive, "invalid tar.gz: %w", err)
+		}
+		name := path.Base(header.Name)
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		switch name {
+		case manifestFileName, eventsFileName, talksFileName, speakersFileName:
+		default:
+			continue
+		}
+		if header.Size > MaxArchiveFileSize {
+			return nil, newError(ErrInvalidArchive, "%s is larger than %d bytes", header.Name, MaxArchiveFileSize)
+		}
+		var b bytes.Buffer
+		if _, err := io.Copy(&b, io.LimitReader(tr, MaxArchiveFileSize)); err != nil {
+			return nil, newError(ErrInvalidArchive, "invalid tar.gz: %w", err)
+		}
+		files[name] = b.Bytes()
+	}
+
+	a := &Archive{Version: ArchiveVersion}
+	if b, ok := files[manifestFileName]; ok {
+		var manifest archiveManifest
+		if err := decodeJSON(manifestFileName, b, &manifest); err != nil {
+			return nil, newError(ErrInvalidArchive, "%v", err)
+		}
+		a.Version, a.ExportedAt = manifest.Version, manifest.ExportedAt
+	}
+	for _, name := range []string{eventsFileName, talksFileName} {
+		if _, ok := files[name]; !ok {
+			return nil, newError(ErrInvalidArchive, "archive has no %s", name)
+		}
+	}
+	if a.Events, err = ParseEvents(eventsFileName, files[eventsFileName]); err != nil {
+		return nil, newError(ErrInvalidArchive, "%v", err)
+	}
+	if a.Talks, err = ParseTalks(talksFileName, files[talksFileName]); err != nil {
+		return nil, newError(ErrInvalidArchive, "%v", err)
+	}
+	if b, ok := files[speakersFileName]; ok {
+		if a.Speakers, err = ParseSpeakers(speakersFileName, b); err != nil {
+			return nil, newError(ErrInvalidArchive, "%v", err)
+		}
+	}
+
+	return a, nil
+}
+
+// ArchiveImportMode selects how ImportArchive combines an archive with the stored data.
+type ArchiveImportMode string
+
+const (
+	// ArchiveMerge adds the events, talks and speakers of the archive and updates those which are already stored,
+	// keeping everything else.
+	ArchiveMerge ArchiveImportMode = "merge"
+	// ArchiveReplace makes the stored data the same as the archive,
+	// deleting the events, talks and speakers which are not in it.
+	ArchiveReplace ArchiveImportMode = "replace"
+)
+
+// ArchiveImportOptions configures ImportArchive.
+type ArchiveImportOptions struct {
+	// Mode is ArchiveMerge if empty.
+	Mode ArchiveImportMode
+	// DryRun reports the changes without making them.
+	DryRun bool
+}
+
+// Changes lists the IDs of the entities an import creates, updates and deletes, sorted,
+// and counts those it leaves unchanged. Talks are identified by their event ID and ID, as in event-1/talk-1.
+type Changes struct {
+	Created   []string `json:"created"`
+	Updated   []string `json:"updated"`
+	Deleted   []string `json:"deleted"`
+	Unchanged int      `json:"unchanged"`
+}
+
+func newChanges() Changes {
+	return Changes{
+		Created: []string{},
+		Updated: []string{},
+		Deleted: []string{},
+	}
+}
+
+// compare records the change from before to after of the entity with the given id.
+// A nil before means the entity is created, and a nil after that it is deleted.
+func (c *Changes) compare(id string, before, after any) {
+	switch {
+	case before == nil:
+		c.Created = append(c.Created, id)
+	case after == nil:
+		c.Deleted = append(c.Deleted, id)
+	case sameJSON(before, after):
+		c.Unchanged++
+	default:
+		c.Updated = append(c.Updated, id)
+	}
+}
+
+// add appends the changes in other to c.
+func (c *Changes) add(other Changes) {
+	c.Created = append(c.Created, other.Created...)
+	c.Updated = append(c.Updated, other.Updated...)
+	c.Deleted = append(c.Deleted, other.Deleted...)
+	c.Unchanged += other.Unchanged
+}
+
+func (c *Changes) sort() {
+	sort.Strings(c.Created)
+	sort.Strings(c.Updated)
+	sort.Strings(c.Deleted)
+}
+
+// changed reports whether there are any changes.
+func (c Changes) changed() bool {
+	return len(c.Created)+len(c.Updated)+len(c.Deleted) > 0
+}
+
+// ArchiveImportReport lists what an import of an archive changed, or would change in a dry run.
+type ArchiveImportReport struct {
+	Mode     ArchiveImportMode `json:"mode"`
+	DryRun   bool              `json:"dry_run"`
+	Events   Changes           `json:"events"`
+	Talks    Changes           `json:"talks"`
+	Speakers Changes           `json:"speakers"`
+}
+
+// ImportArchive combines the archive with the stored data as selected by the mode of opts,
+// and returns a report of the changes.
+// The archive must pass Validate, and its talks must reference existing speakers by ID,
+// otherwise an error matching ErrInvalidArchive is returned and nothing is changed.
+func (es *EventService) ImportArchive(a Archive, opts ArchiveImportOptions) (*ArchiveImportReport, error) {
+	mode := opts.Mode
+	if mode == "" {
+		mode = ArchiveMerge
+	}
+	if mode != ArchiveMerge && mode != ArchiveReplace {
+		return nil, newError(ErrInvalidArchive, "unknown import mode %q, expected %s or %s", mode, ArchiveMerge, ArchiveReplace)
+	}
+	switch {
+	case a.Version == 0:
+		return nil, newError(ErrInvalidArchive, "archive has no version")
+	case a.Version > ArchiveVersion:
+		return nil, newError(ErrInvalidArchive, "archive version %d is newer than the supported version %d", a.Version, ArchiveVersion)
+	}
+
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	stored, err := es.repo.List()
+	if err != nil {
+		return nil, err
+	}
+	storedEvents := make(map[string]Event, len(stored))
+	for _, e := range stored {
+		storedEvents[e.ID] = e
+	}
+	// as on update, events moving to another time zone keep their days, and their kept talks their local times
+	events := make([]Event, len(a.Events))
+	for i, e := range a.Events {
+		if before, ok := storedEvents[e.ID]; ok && before.TimeZone != e.TimeZone {
+			e = rezoneDates(e)
+		}
+		events[i] = e
+	}
+	storedSpeakers, err := es.repo.ListSpeakers()
+	if err != nil {
+		return nil, err
+	}
+	// on merge, talks can also reference the stored speakers
+	knownSpeakers := a.Speakers
+	if mode == ArchiveMerge {
+		knownSpeakers = append(append([]Speaker{}, storedSpeakers...), a.Speakers...)
+	}
+	if report := Validate(events, a.Talks, knownSpeakers); !report.Valid() {
+		return nil, newError(ErrInvalidArchive, "%v", &ValidationError{Report: report})
+	}
+	speakersBefore := make(map[string]Speaker, len(storedSpeakers))
+	for _, s := range storedSpeakers {
+		speakersBefore[s.ID] = s
+	}
+
+	// the speakers of the archive, and any it lacks which are created from the names in its talks;
+	// on merge, talks can also reference the stored speakers
+	speakers := newSpeakerRegistry(a.Speakers)
+	getSpeaker := speakers.get
+	if mode == ArchiveMerge {
+		getSpeaker = func(id string) (Speaker, bool, error) {
+			if s, ok, _ := speakers.get(id); ok {
+				return s, true, nil
+			}
+			s, ok := speakersBefore[id]
+			return s, ok, nil
+		}
+	}
+	imported := groupTalks(events, a.Talks)
+	for _, e := range imported {
+		for i := range e.Talks {
+			if err := speakers.resolveWith(&e.Talks[i], getSpeaker); err != nil {
+				return nil, newError(ErrInvalidArchive, "talk %s of event %s: %v", e.Talks[i].ID, e.ID, err)
+			}
+		}
+	}
+
+	report := &ArchiveImportReport{
+		Mode:     mode,
+		DryRun:   opts.DryRun,
+		Events:   newChanges(),
+		Talks:    newChanges(),
+		Speakers: newChanges(),
+	}
+	var saves []Event
+	inArchive := make(map[string]bool, len(imported))
+	for _, e := range imported {
+		inArchive[e.ID] = true
+		before, ok := storedEvents[e.ID]
+		talks := newChanges()
+		if !ok {
+			report.Events.compare(e.ID, nil, e)
+			for _, t := range e.Talks {
+				talks.compare(talkKey(t), nil, t)
+			}
+		} else {
+			report.Events.compare(e.ID, eventDetails(before), eventDetails(e))
+			kept, err := keptTalks(before, e, mode)
+			if err != nil {
+				return nil, err
+			}
+			e.Talks = append(e.Talks, kept...)
+			compareTalks(&talks, before.Talks, e.Talks)
+		}
+		if !ok || talks.changed() || !sameJSON(eventDetails(before), eventDetails(e)) {
+			saves = append(saves, e)
+		}
+		report.Talks.add(talks)
+	}
+	var deletes []string
+	if mode == ArchiveReplace {
+		for _, e := range stored {
+			if inArchive[e.ID] {
+				continue
+			}
+			deletes = append(deletes, e.ID)
+			report.Events.compare(e.ID, e, nil)
+			for _, t := range e.Talks {
+				report.Talks.compare(talkKey(t), t, nil)
+			}
+		}
+	}
+	var speakerSaves []Speaker
+	importedSpeakers := speakers.list()
+	inArchive = make(map[string]bool, len(importedSpeakers))
+	for _, s := range importedSpeakers {
+		inArchive[s.ID] = true
+		before, ok := speakersBefore[s.ID]
+		if !ok {
+			report.Speakers.compare(s.ID, nil, s)
+			speakerSaves = append(speakerSaves, s)
+			continue
+		}
+		report.Speakers.compare(s.ID, before, s)
+		if !sameJSON(before, s) {
+			speakerSaves = append(speakerSaves, s)
+		}
+	}
+	var speakerDeletes []string
+	if mode == ArchiveReplace {
+		for _, s := range storedSpeakers {
+			if !inArchive[s.ID] {
+				speakerDeletes = append(speakerDeletes, s.ID)
+				report.Speakers.compare(s.ID, s, nil)
+			}
+		}
+	}
+	report.Events.sort()
+	report.Talks.sort()
+	report.Speakers.sort()
+	if opts.DryRun || len(speakerSaves)+len(saves)+len(deletes)+len(speakerDeletes) == 0 {
+		// an import which changes nothing keeps the cached responses of clients valid
+		return report, nil
+	}
+
+	// everything is checked before the first write, but a repository failing part way leaves the earlier writes,
+	// so the search index and last modified time are updated whether or not all writes succeed
+	defer es.changed()
+	// speakers are saved before the talks referencing them, and deleted after the talks
+	for _, s := range speakerSaves {
+		if err := es.repo.SaveSpeaker(s); err != nil {
+			return nil, err
+		}
+	}
+	for _, e := range saves {
+		if err := es.repo.Save(e); err != nil {
+			return nil, err
+		}
+	}
+	for _, id := range deletes {
+		if err := es.repo.Delete(id); err != nil {
+			return nil, err
+		}
+	}
+	for _, id := range speakerDeletes {
+		if err := es.repo.DeleteSpeaker(id); err != nil {
+			return nil, err
+		}
+	}
+
+	return report, nil
+}
+
+// keptTalks returns the stored talks of an event which a merge keeps because the archive does not have them,
+// at the same local times if the time zone of the event changes.
+// It returns an error if the new dates of the event exclude any of them.
+// Replacing keeps no talks.
+func keptTalks(before, after Event, mode ArchiveImportMode) ([]Talk, error) {
+	if mode == ArchiveReplace {
+		return nil, nil
+	}
+	var kept []Talk
+	for _, t := range before.Talks {
+		if findTalk(after.Talks, t.ID) < 0 {
+			kept = append(kept, t)
+		}
+	}
+	if after.TimeZone != before.TimeZone {
+		kept = rezoneTalks(kept, after.location())
+	}
+	for _, t := range kept {
+		if err := checkTalkDate(after, t); err != nil {
+			return nil, newError(ErrInvalidArchive, "new dates of event %s exclude its talk %s: %v", after.ID, t.ID, err)
+		}
+	}
+
+	return kept, nil
+}
+
+// compareTalks records the changes from the talks of an event before to those after.
+func compareTalks(c *Changes, before, after []Talk) {
+	for _, t := range after {
+		if i := findTalk(before, t.ID); i >= 0 {
+			c.compare(talkKey(t), before[i], t)
+		} else {
+			c.compare(talkKey(t), nil, t)
+		}
+	}
+	for _, t := range before {
+		if findTalk(after, t.ID) < 0 {
+			c.compare(talkKey(t), t, nil)
+		}
+	}
+}
+
+func talkKey(t Talk) string {
+	return t.EventID + "/" + t.ID
+}
+
+// eventDetails returns the event without its talks, to compare the details of events.
+func eventDetails(e Event) Event {
+	e.Talks = nil
+
+	return e
+}
+
+// sameJSON reports whether a and b have the same JSON,
+// which compares dates and times by their instant and UTC offset rather than their *time.Location.
+func sameJSON(a, b any) bool {
+	ja, errA := json.Marshal(a)
+	jb, errB := json.Marshal(b)
+
+	return errA == nil && errB == nil && bytes.Equal(ja, jb)
+}
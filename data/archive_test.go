@@ -0,0 +1,296 @@
+package data_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/addetz/testing-strategies-demo/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArchiveRoundTrip(t *testing.T) {
+	events := []data.Event{
+		{ID: "event-1", Name: "Event 1", DateStart: date("01/01/2010"), DateEnd: date("02/01/2010"), TimeZone: "Europe/Amsterdam"},
+		{ID: "event-2", Name: "Event 2", DateStart: date("01/02/2010"), DateEnd: date("01/02/2010")},
+	}
+	talks := []data.Talk{
+		{ID: "talk-1", EventID: "event-1", Title: "Talk 1", Speakers: []string{"Speaker 1"},
+			Date: date("01/01/2010"), Time: dateTime("01/01/2010 09:30"), Duration: 30, Room: "Main", Track: "Go"},
+		{ID: "talk-2", EventID: "event-2", Title: "Talk 2", Date: date("01/02/2010")},
+	}
+	speakers := []data.Speaker{{ID: "speaker-1", Name: "Speaker 1", Bio: "Speaks"}}
+	es, err := data.NewEventService(events, talks, data.WithSpeakers(speakers))
+	require.Nil(t, err)
+	exported, err := es.Export()
+	require.Nil(t, err)
+	assert.Equal(t, data.ArchiveVersion, exported.Version)
+	require.Len(t, exported.Talks, 2)
+	assert.Equal(t, []string{"speaker-1"}, exported.Talks[0].SpeakerIDs)
+
+	var b bytes.Buffer
+	require.Nil(t, exported.WriteTarGz(&b))
+	archive, err := data.ReadTarGz(&b)
+	require.Nil(t, err)
+	assert.Equal(t, exported.ExportedAt, archive.ExportedAt)
+
+	target, err := data.NewEventService([]data.Event{}, []data.Talk{})
+	require.Nil(t, err)
+	report, err := target.ImportArchive(*archive, data.ArchiveImportOptions{Mode: data.ArchiveReplace})
+	require.Nil(t, err)
+	assert.Equal(t, []string{"event-1", "event-2"}, report.Events.Created)
+	assert.Equal(t, []string{"event-1/talk-1", "event-2/talk-2"}, report.Talks.Created)
+	assert.Equal(t, []string{"speaker-1"}, report.Speakers.Created)
+	reexported, err := target.Export()
+	require.Nil(t, err)
+	reexported.ExportedAt = exported.ExportedAt
+	assert.Equal(t, mustJSON(t, exported), mustJSON(t, reexported))
+
+	// importing the same archive again changes nothing
+	report, err = target.ImportArchive(*exported, data.ArchiveImportOptions{})
+	require.Nil(t, err)
+	assert.Equal(t, data.ArchiveMerge, report.Mode)
+	assert.Equal(t, 2, report.Events.Unchanged)
+	assert.Equal(t, 2, report.Talks.Unchanged)
+	assert.Equal(t, 1, report.Speakers.Unchanged)
+	assert.Empty(t, report.Events.Updated)
+}
+
+func TestImportArchive(t *testing.T) {
+	events := []data.Event{
+		{ID: "event-1", Name: "Event 1", DateStart: date("01/01/2010"), DateEnd: date("02/01/2010")},
+		{ID: "event-2", Name: "Event 2", DateStart: date("01/02/2010"), DateEnd: date("01/02/2010")},
+	}
+	talks := []data.Talk{
+		{ID: "talk-1", EventID: "event-1", Title: "Talk 1", Speakers: []string{"Speaker 1"}, Date: date("01/01/2010")},
+		{ID: "talk-2", EventID: "event-1", Title: "Talk 2", Date: date("02/01/2010")},
+		{ID: "talk-3", EventID: "event-2", Title: "Talk 3", Speakers: []string{"Speaker 2"}, Date: date("01/02/2010")},
+	}
+	// the archive renames event 1, updates talk 1, drops talk 2 and event 2, and adds event 3
+	archive := data.Archive{
+		Version: data.ArchiveVersion,
+		Events: []data.Event{
+			{ID: "event-1", Name: "Event 1 renamed", DateStart: date("01/01/2010"), DateEnd: date("02/01/2010")},
+			{ID: "event-3", Name: "Event 3", DateStart: date("01/03/2010"), DateEnd: date("01/03/2010")},
+		},
+		Talks: []data.Talk{
+			{ID: "talk-1", EventID: "event-1", Title: "Talk 1 updated", Speakers: []string{"Speaker 1"}, Date: date("01/01/2010")},
+			{ID: "talk-4", EventID: "event-3", Title: "Talk 4", Speakers: []string{"Speaker 3"}, Date: date("01/03/2010")},
+		},
+		Speakers: []data.Speaker{{ID: "speaker-1", Name: "Speaker 1"}},
+	}
+	testCases := map[string]struct {
+		archive          data.Archive
+		opts             data.ArchiveImportOptions
+		expectedEvents   data.Changes
+		expectedTalks    data.Changes
+		expectedSpeakers data.Changes
+		expectedStored   []string
+		expectedErrMsg   string
+	}{
+		"merge": {
+			archive: archive,
+			opts:    data.ArchiveImportOptions{Mode: data.ArchiveMerge},
+			expectedEvents: data.Changes{
+				Created: []string{"event-3"}, Updated: []string{"event-1"}, Deleted: []string{},
+			},
+			expectedTalks: data.Changes{
+				Created: []string{"event-3/talk-4"}, Updated: []string{"event-1/talk-1"}, Deleted: []string{}, Unchanged: 1,
+			},
+			expectedSpeakers: data.Changes{
+				Created: []string{"speaker-3"}, Updated: []string{}, Deleted: []string{}, Unchanged: 1,
+			},
+			expectedStored: []string{"event-1/talk-1", "event-1/talk-2", "event-2/talk-3", "event-3/talk-4"},
+		},
+		"replace": {
+			archive: archive,
+			opts:    data.ArchiveImportOptions{Mode: data.ArchiveReplace},
+			expectedEvents: data.Changes{
+				Created: []string{"event-3"}, Updated: []string{"event-1"}, Deleted: []string{"event-2"},
+			},
+			expectedTalks: data.Changes{
+				Created: []string{"event-3/talk-4"}, Updated: []string{"event-1/talk-1"}, Deleted: []string{"event-1/talk-2", "event-2/talk-3"},
+			},
+			expectedSpeakers: data.Changes{
+				Created: []string{"speaker-3"}, Updated: []string{}, Deleted: []string{"speaker-2"}, Unchanged: 1,
+			},
+			expectedStored: []string{"event-1/talk-1", "event-3/talk-4"},
+		},
+		"dry run": {
+			archive: archive,
+			opts:    data.ArchiveImportOptions{Mode: data.ArchiveReplace, DryRun: true},
+			expectedEvents: data.Changes{
+				Created: []string{"event-3"}, Updated: []string{"event-1"}, Deleted: []string{"event-2"},
+			},
+			expectedTalks: data.Changes{
+				Created: []string{"event-3/talk-4"}, Updated: []string{"event-1/talk-1"}, Deleted: []string{"event-1/talk-2", "event-2/talk-3"},
+			},
+			expectedSpeakers: data.Changes{
+				Created: []string{"speaker-3"}, Updated: []string{}, Deleted: []string{"speaker-2"}, Unchanged: 1,
+			},
+			expectedStored: []string{"event-1/talk-1", "event-1/talk-2", "event-2/talk-3"},
+		},
+		"merge excluding kept talk": {
+			archive: data.Archive{
+				Version: data.ArchiveVersion,
+				Events:  []data.Event{{ID: "event-1", DateStart: date("01/01/2010"), DateEnd: date("01/01/2010")}},
+				Talks:   []data.Talk{},
+			},
+			expectedErrMsg: "new dates of event event-1 exclude its talk talk-2: talk date 02/01/2010 is outside of event event-1 dates 01/01/2010-01/01/2010",
+		},
+		"invalid data": {
+			archive: data.Archive{
+				Version: data.ArchiveVersion,
+				Events:  []data.Event{{ID: "event-1", DateStart: date("02/01/2010"), DateEnd: date("01/01/2010")}},
+				Talks:   []data.Talk{},
+			},
+			expectedErrMsg: "data failed validation with 1 issues\n$.events[0].date_start: date_start 02/01/2010 is after date_end 01/01/2010",
+		},
+		"unknown speaker": {
+			archive: data.Archive{
+				Version: data.ArchiveVersion,
+				Events:  []data.Event{{ID: "event-1", DateStart: date("01/01/2010"), DateEnd: date("02/01/2010")}},
+				Talks:   []data.Talk{{ID: "talk-1", EventID: "event-1", Title: "Talk 1", SpeakerIDs: []string{"speaker-9"}, Date: date("01/01/2010")}},
+			},
+			expectedErrMsg: "data failed validation with 1 issues\n$.talks[0].speaker_ids[0]: no speaker for id \"speaker-9\"",
+		},
+		"missing version": {
+			archive:        data.Archive{Events: []data.Event{}, Talks: []data.Talk{}},
+			expectedErrMsg: "archive has no version",
+		},
+		"newer version": {
+			archive:        data.Archive{Version: data.ArchiveVersion + 1},
+			expectedErrMsg: "archive version 2 is newer than the supported version 1",
+		},
+		"unknown mode": {
+			archive:        archive,
+			opts:           data.ArchiveImportOptions{Mode: "append"},
+			expectedErrMsg: `unknown import mode "append", expected merge or replace`,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			es, err := data.NewEventService(events, talks)
+			require.Nil(t, err)
+			report, err := es.ImportArchive(tc.archive, tc.opts)
+			if tc.expectedErrMsg != "" {
+				assert.Nil(t, report)
+				assert.ErrorIs(t, err, data.ErrInvalidArchive)
+				assert.EqualError(t, err, tc.expectedErrMsg)
+				return
+			}
+			require.Nil(t, err)
+			assert.Equal(t, tc.expectedEvents, report.Events)
+			assert.Equal(t, tc.expectedTalks, report.Talks)
+			assert.Equal(t, tc.expectedSpeakers, report.Speakers)
+			exported, err := es.Export()
+			require.Nil(t, err)
+			var stored []string
+			for _, talk := range exported.Talks {
+				stored = append(stored, talk.EventID+"/"+talk.ID)
+			}
+			assert.ElementsMatch(t, tc.expectedStored, stored)
+		})
+	}
+}
+
+func TestImportArchiveTimeZone(t *testing.T) {
+	es, err := data.NewEventService([]data.Event{
+		{ID: "event-1", DateStart: date("28/06/2023"), DateEnd: date("29/06/2023"), TimeZone: "Europe/Amsterdam"},
+	}, []data.Talk{
+		// 09:30 in Amsterdam
+		{ID: "talk-1", EventID: "event-1", Title: "Talk 1", Date: date("29/06/2023"), Time: dateTime("29/06/2023 07:30")},
+	})
+	require.Nil(t, err)
+	archive, err := es.Export()
+	require.Nil(t, err)
+	// the archive only moves the event to another time zone, so the merge keeps its talk
+	archive.Events[0].TimeZone = "America/New_York"
+	archive.Talks = []data.Talk{}
+
+	_, err = es.ImportArchive(*archive, data.ArchiveImportOptions{Mode: data.ArchiveMerge})
+	require.Nil(t, err)
+	event, err := es.GetEvent("event-1")
+	require.Nil(t, err)
+	loc, err := time.LoadLocation("America/New_York")
+	require.Nil(t, err)
+	assert.Equal(t, time.Date(2023, time.June, 28, 0, 0, 0, 0, loc), event.DateStart)
+	assert.Equal(t, time.Date(2023, time.June, 29, 0, 0, 0, 0, loc), event.DateEnd)
+	require.Len(t, event.Talks, 1)
+	assert.Equal(t, time.Date(2023, time.June, 29, 9, 30, 0, 0, loc), event.Talks[0].Time)
+}
+
+// failingRepository is a MemoryRepository whose saves of events fail after the first failAfter.
+type failingRepository struct {
+	*data.MemoryRepository
+	failAfter int
+	saves     int
+}
+
+func (r *failingRepository) Save(e data.Event) error {
+	if r.saves >= r.failAfter {
+		return errors.New("disk full")
+	}
+	r.saves++
+	return r.MemoryRepository.Save(e)
+}
+
+func TestImportArchivePartialFailure(t *testing.T) {
+	memory := data.NewMemoryRepository()
+	_, err := data.Seed(memory, []data.Event{
+		{ID: "event-1", Name: "Event 1", DateStart: date("01/01/2010"), DateEnd: date("01/01/2010")},
+	}, []data.Talk{}, nil)
+	require.Nil(t, err)
+	es := data.NewEventServiceWithRepository(&failingRepository{MemoryRepository: memory, failAfter: 1})
+	_, err = es.Search("event")
+	require.Nil(t, err)
+	before := es.LastModified()
+	archive := data.Archive{
+		Version: data.ArchiveVersion,
+		Events: []data.Event{
+			{ID: "event-2", Name: "Event 2", DateStart: date("01/02/2010"), DateEnd: date("01/02/2010")},
+			{ID: "event-3", Name: "Event 3", DateStart: date("01/03/2010"), DateEnd: date("01/03/2010")},
+		},
+		Talks: []data.Talk{},
+	}
+
+	report, err := es.ImportArchive(archive, data.ArchiveImportOptions{Mode: data.ArchiveMerge})
+	assert.Nil(t, report)
+	assert.EqualError(t, err, "disk full")
+
+	// the event saved before the failure is served, searched and cached as changed data
+	assert.True(t, es.LastModified().After(before))
+	events, err := es.GetEvents()
+	require.Nil(t, err)
+	assert.Len(t, events.Events, 2)
+	results, err := es.Search("event")
+	require.Nil(t, err)
+	assert.Len(t, results.Events, 2)
+}
+
+func TestReadTarGz(t *testing.T) {
+	t.Run("not gzip", func(t *testing.T) {
+		archive, err := data.ReadTarGz(bytes.NewReader([]byte(`{"version": 1}`)))
+		assert.Nil(t, archive)
+		assert.ErrorIs(t, err, data.ErrInvalidArchive)
+	})
+	t.Run("empty archive", func(t *testing.T) {
+		var b bytes.Buffer
+		require.Nil(t, data.Archive{Version: data.ArchiveVersion}.WriteTarGz(&b))
+		archive, err := data.ReadTarGz(&b)
+		require.Nil(t, err)
+		assert.Equal(t, data.ArchiveVersion, archive.Version)
+		assert.Empty(t, archive.Events)
+	})
+}
+
+// mustJSON returns the JSON encoding of v.
+func mustJSON(t *testing.T, v any) string {
+	b, err := json.Marshal(v)
+	require.Nil(t, err)
+
+	return string(b)
+}
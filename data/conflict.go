@@ -0,0 +1,164 @@
+package data
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Kinds of schedule conflicts.
+const (
+	ConflictRoomOverlap         = "room_overlap"
+	ConflictSpeakerDoubleBooked = "speaker_double_booked"
+	ConflictOutsideEventDates   = "outside_event_dates"
+)
+
+// Conflict is a problem with the schedule of an event, such as two talks given in the same room at the same time.
+type Conflict struct {
+	Kind    string   `json:"kind"`
+	Message string   `json:"message"`
+	TalkIDs []string `json:"talk_ids"`
+	// Room is set for room overlaps, SpeakerID for double-booked speakers.
+	Room      string `json:"room,omitempty"`
+	SpeakerID string `json:"speaker_id,omitempty"`
+}
+
+type Conflicts struct {
+	EventID   string     `json:"event_id"`
+	Conflicts []Conflict `json:"conflicts"`
+}
+
+// GetEventConflicts returns the schedule conflicts of the event corresponding to the given id,
+// or an error if no event is found.
+func (es *EventService) GetEventConflicts(id string) (*Conflicts, error) {
+	event, err := es.GetEvent(id)
+	if err != nil {
+		return nil, err
+	}
+	conflicts := &Conflicts{EventID: event.ID, Conflicts: []Conflict{}}
+	for _, c := range findConflicts(*event, event.Talks) {
+		conflict := Conflict{
+			Kind:      c.kind,
+			TalkIDs:   []string{event.Talks[c.talks[0]].ID},
+			Room:      c.room,
+			SpeakerID: c.speakerID,
+		}
+		if c.kind != ConflictOutsideEventDates {
+			conflict.TalkIDs = append(conflict.TalkIDs, event.Talks[c.talks[1]].ID)
+		}
+		conflict.Message = c.message(conflict.TalkIDs)
+		conflicts.Conflicts = append(conflicts.Conflicts, conflict)
+	}
+
+	return conflicts, nil
+}
+
+// talkConflict is a conflict between the talks at the given indexes,
+// of which only the first is set for talks outside their event's dates.
+type talkConflict struct {
+	kind      string
+	talks     [2]int
+	room      string
+	speakerID string
+	event     Event
+}
+
+// message describes the conflict, naming the talks by the given names.
+func (c talkConflict) message(names []string) string {
+	switch c.kind {
+	case ConflictRoomOverlap:
+		return fmt.Sprintf("talks %s and %s overlap in room %s", names[0], names[1], c.room)
+	case ConflictSpeakerDoubleBooked:
+		return fmt.Sprintf("speaker %s is booked for talks %s and %s at the same time", c.speakerID, names[0], names[1])
+	default:
+		return fmt.Sprintf("talk %s is outside of event %s dates %s-%s", names[0], c.event.ID,
+			formatDate(c.event.DateStart), formatDate(c.event.DateEnd))
+	}
+}
+
+// scheduledTalk is a talk with a parsed start and end.
+type scheduledTalk struct {
+	index      int
+	start, end time.Time
+}
+
+// findConflicts returns the conflicts between the given talks of event e, in the order the talks start.
+// The talks must be in the time zone of the event.
+// Talks overlap if they start at the same time, or if one starts before the other ends.
+// Talks without a duration end as they start, and talks without a time are not scheduled,
+// so they cannot overlap.
+func findConflicts(e Event, talks []Talk) []talkConflict {
+	var conflicts []talkConflict
+	for i, t := range talks {
+		if err := checkTalkDate(e, t); err != nil {
+			conflicts = append(conflicts, talkConflict{kind: ConflictOutsideEventDates, talks: [2]int{i}, event: e})
+		}
+	}
+
+	var scheduled []scheduledTalk
+	for i, t := range talks {
+		if t.Time.IsZero() {
+			continue
+		}
+		scheduled = append(scheduled, scheduledTalk{
+			index: i,
+			start: t.Time,
+			end:   t.Time.Add(time.Duration(max(t.Duration, 0)) * time.Minute),
+		})
+	}
+	sort.SliceStable(scheduled, func(i, j int) bool {
+		return scheduled[i].start.Before(scheduled[j].start)
+	})
+	for i, a := range scheduled {
+		for _, b := range scheduled[i+1:] {
+			// b starts after a starts, so neither it nor any later talk can overlap a once it starts after a ends
+			if !b.start.Equal(a.start) && !b.start.Before(a.end) {
+				break
+			}
+			ta, tb := talks[a.index], talks[b.index]
+			pair := [2]int{a.index, b.index}
+			if ta.Room != "" && strings.EqualFold(ta.Room, tb.Room) {
+				conflicts = append(conflicts, talkConflict{kind: ConflictRoomOverlap, talks: pair, room: ta.Room})
+			}
+			for _, id := range sharedSpeakers(ta, tb) {
+				conflicts = append(conflicts, talkConflict{kind: ConflictSpeakerDoubleBooked, talks: pair, speakerID: id})
+			}
+		}
+	}
+
+	return conflicts
+}
+
+// sharedSpeakers returns the IDs of the speakers of both talks, in the order of the first talk.
+// Talks which only name their speakers are matched by the IDs derived from the names.
+func sharedSpeakers(a, b Talk) []string {
+	other := make(map[string]bool)
+	for _, id := range talkSpeakerIDs(b) {
+		other[id] = true
+	}
+	var shared []string
+	for _, id := range talkSpeakerIDs(a) {
+		if other[id] {
+			shared = append(shared, id)
+			// a speaker listed twice is only reported once
+			delete(other, id)
+		}
+	}
+
+	return shared
+}
+
+func talkSpeakerIDs(t Talk) []string {
+	if len(t.SpeakerIDs) > 0 {
+		return t.SpeakerIDs
+	}
+	ids := make([]string, 0, len(t.Speakers))
+	for _, name := range t.Speakers {
+		if id := SpeakerID(name); id != "" {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids
+}
@@ -0,0 +1,89 @@
+package data_test
+
+import (
+	"testing"
+
+	"github.com/addetz/testing-strategies-demo/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetEventConflicts(t *testing.T) {
+	testCases := map[string]struct {
+		talks             []data.Talk
+		expectedConflicts []data.Conflict
+	}{
+		"no conflicts": {
+			talks: []data.Talk{
+				{ID: "talk-1", Date: date("01/02/2023"), Time: dateTime("01/02/2023 09:00"), Duration: 30, Room: "Main", SpeakerIDs: []string{"ada"}},
+				{ID: "talk-2", Date: date("01/02/2023"), Time: dateTime("01/02/2023 09:30"), Duration: 30, Room: "Main", SpeakerIDs: []string{"ada"}},
+				{ID: "talk-3", Date: date("02/02/2023"), Time: dateTime("02/02/2023 09:00"), Room: "Main", SpeakerIDs: []string{"ada"}},
+				{ID: "talk-4", Date: date("01/02/2023"), Room: "Main", SpeakerIDs: []string{"ada"}},
+			},
+			expectedConflicts: []data.Conflict{},
+		},
+		"room overlap ignoring case": {
+			talks: []data.Talk{
+				{ID: "talk-1", Date: date("01/02/2023"), Time: dateTime("01/02/2023 09:00"), Duration: 45, Room: "Main"},
+				{ID: "talk-2", Date: date("01/02/2023"), Time: dateTime("01/02/2023 09:30"), Room: "main"},
+				{ID: "talk-3", Date: date("01/02/2023"), Time: dateTime("01/02/2023 09:30"), Room: "Side"},
+			},
+			expectedConflicts: []data.Conflict{
+				{
+					Kind:    data.ConflictRoomOverlap,
+					Message: "talks talk-1 and talk-2 overlap in room Main",
+					TalkIDs: []string{"talk-1", "talk-2"},
+					Room:    "Main",
+				},
+			},
+		},
+		"talks without duration at the same time": {
+			talks: []data.Talk{
+				{ID: "talk-1", Date: date("01/02/2023"), Time: dateTime("01/02/2023 09:00"), SpeakerIDs: []string{"ada", "grace"}},
+				{ID: "talk-2", Date: date("01/02/2023"), Time: dateTime("01/02/2023 09:00"), SpeakerIDs: []string{"grace"}},
+			},
+			expectedConflicts: []data.Conflict{
+				{
+					Kind:      data.ConflictSpeakerDoubleBooked,
+					Message:   "speaker grace is booked for talks talk-1 and talk-2 at the same time",
+					TalkIDs:   []string{"talk-1", "talk-2"},
+					SpeakerID: "grace",
+				},
+			},
+		},
+		"outside event dates": {
+			talks: []data.Talk{
+				{ID: "talk-1", Date: date("03/02/2023")},
+			},
+			expectedConflicts: []data.Conflict{
+				{
+					Kind:    data.ConflictOutsideEventDates,
+					Message: "talk talk-1 is outside of event event-1 dates 01/02/2023-02/02/2023",
+					TalkIDs: []string{"talk-1"},
+				},
+			},
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			// the repository is written to directly, since the service rejects talks outside their event's dates
+			repo := data.NewMemoryRepository()
+			for i := range tc.talks {
+				tc.talks[i].EventID = "event-1"
+			}
+			require.Nil(t, repo.Save(data.Event{ID: "event-1", DateStart: date("01/02/2023"), DateEnd: date("02/02/2023"), Talks: tc.talks}))
+			es := data.NewEventServiceWithRepository(repo)
+
+			conflicts, err := es.GetEventConflicts("event-1")
+			require.Nil(t, err)
+			assert.Equal(t, "event-1", conflicts.EventID)
+			assert.Equal(t, tc.expectedConflicts, conflicts.Conflicts)
+		})
+	}
+
+	t.Run("invalid event", func(t *testing.T) {
+		es := data.NewEventServiceWithRepository(data.NewMemoryRepository())
+		_, err := es.GetEventConflicts("event-2")
+		assert.ErrorIs(t, err, data.ErrEventNotFound)
+	})
+}
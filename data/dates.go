@@ -0,0 +1,384 @@
+package data
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	// embeds the time zone database, so that event time zones load on systems without one
+	_ "time/tzdata"
+)
+
+// Formats accepted on input besides RFC 3339. Output always uses RFC 3339.
+const (
+	// dateFormat is the legacy format of dates in the data files and the v1 API.
+	dateFormat    = "02/01/2006"
+	isoDateFormat = "2006-01-02"
+	timeFormat    = "15:04"
+)
+
+// floating is the location of dates and times parsed without a UTC offset.
+// They are moved to the time zone of their event keeping their wall clock,
+// while dates and times with an offset keep their instant.
+var floating = time.FixedZone("", 0)
+
+// ParseDate parses a date in the legacy DD/MM/YYYY format, as an RFC 3339 date such as 2023-06-28,
+// or as an RFC 3339 date and time with a UTC offset.
+// Dates without an offset are taken to be in the time zone of their event once saved.
+func ParseDate(value string) (time.Time, error) {
+	for _, layout := range []string{dateFormat, isoDateFormat} {
+		if parsed, err := time.ParseInLocation(layout, value, floating); err == nil {
+			return parsed, nil
+		}
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q: expected format %s or RFC 3339", value, dateFormat)
+	}
+
+	return parsed, nil
+}
+
+// ParseTalkTime parses the start of a talk given as a time of day in the HH:MM format on the given date,
+// or as an RFC 3339 date and time with a UTC offset.
+// Times of day are taken to be in the time zone of the talk's event once saved.
+func ParseTalkTime(date time.Time, value string) (time.Time, error) {
+	if clock, err := time.Parse(timeFormat, value); err == nil && !date.IsZero() {
+		y, m, d := date.Date()
+		return time.Date(y, m, d, clock.Hour(), clock.Minute(), 0, 0, floating), nil
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q: expected format %s or RFC 3339", value, timeFormat)
+	}
+
+	return parsed, nil
+}
+
+// formatTime formats t in RFC 3339, or returns an empty string if t is the zero time.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.Format(time.RFC3339)
+}
+
+// formatDate formats t in the legacy date format used in messages, or returns an empty string if t is the zero time.
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.Format(dateFormat)
+}
+
+// formatClock formats the time of day of t, or returns an empty string if t is the zero time.
+func formatClock(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.Format(timeFormat)
+}
+
+var (
+	locationsMu sync.Mutex
+	locations   = make(map[string]*time.Location)
+)
+
+// loadLocation returns the IANA time zone with the given name, or UTC if name is empty.
+// The Local time zone of the server is rejected, since it differs between servers.
+func loadLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	locationsMu.Lock()
+	defer locationsMu.Unlock()
+	if loc, ok := locations[name]; ok {
+		return loc, nil
+	}
+	if strings.EqualFold(name, "Local") {
+		return nil, fmt.Errorf("unknown time zone %s", name)
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	locations[name] = loc
+
+	return loc, nil
+}
+
+// location returns the time zone of the event, or UTC if it has none or it is unknown.
+func (e Event) location() *time.Location {
+	loc, err := loadLocation(e.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+
+	return loc
+}
+
+// localTime returns t in loc. Floating times keep their wall clock, all others their instant.
+func localTime(t time.Time, loc *time.Location) time.Time {
+	if t.IsZero() {
+		return t
+	}
+	if t.Location() != floating {
+		return t.In(loc)
+	}
+
+	return wallTime(t, loc)
+}
+
+// wallTime returns the time in loc with the same wall clock as t.
+func wallTime(t time.Time, loc *time.Location) time.Time {
+	if t.IsZero() {
+		return t
+	}
+	y, m, d := t.Date()
+	hour, min, sec := t.Clock()
+
+	return time.Date(y, m, d, hour, min, sec, t.Nanosecond(), loc)
+}
+
+// startOfDay returns midnight of the day of t, in the location of t.
+func startOfDay(t time.Time) time.Time {
+	if t.IsZero() {
+		return t
+	}
+	y, m, d := t.Date()
+
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+// sameDay reports whether a and b fall on the same date, each in its own location.
+func sameDay(a, b time.Time) bool {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+
+	return ay == by && am == bm && ad == bd
+}
+
+// civilDate returns the date of t in its own location as midnight UTC,
+// so that dates in different time zones can be compared.
+func civilDate(t time.Time) time.Time {
+	y, m, d := t.Date()
+
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// localise returns a copy of the event with its dates and the dates and times of its talks
+// in the event's time zone. Dates are moved to midnight, and the date of a talk with a time is the day of that time.
+func localise(e Event) Event {
+	loc := e.location()
+	e.DateStart = startOfDay(localTime(e.DateStart, loc))
+	e.DateEnd = startOfDay(localTime(e.DateEnd, loc))
+	if e.Talks != nil {
+		talks := make([]Talk, len(e.Talks))
+		for i, t := range e.Talks {
+			talks[i] = localiseTalk(t, loc)
+		}
+		e.Talks = talks
+	}
+
+	return e
+}
+
+// localiseTalk returns the talk with its date and time in loc.
+func localiseTalk(t Talk, loc *time.Location) Talk {
+	t.Time = localTime(t.Time, loc)
+	if t.Time.IsZero() {
+		t.Date = startOfDay(localTime(t.Date, loc))
+	} else {
+		t.Date = startOfDay(t.Time)
+	}
+
+	return t
+}
+
+// rezoneDates returns the event with its dates moved to its time zone, keeping their wall clock.
+// It is used when the time zone of an event changes, so that the event keeps its days,
+// which localise would otherwise shift by the difference between the time zones.
+func rezoneDates(e Event) Event {
+	loc := e.location()
+	e.DateStart = wallTime(e.DateStart, loc)
+	e.DateEnd = wallTime(e.DateEnd, loc)
+
+	return e
+}
+
+// rezoneTalks returns the talks moved to loc, keeping the wall clock of their dates and times.
+// It is used when the time zone of an event changes, so that its talks keep their local schedule.
+func rezoneTalks(talks []Talk, loc *time.Location) []Talk {
+	if talks == nil {
+		return nil
+	}
+	rezoned := make([]Talk, len(talks))
+	for i, t := range talks {
+		t.Date = wallTime(t.Date, loc)
+		t.Time = wallTime(t.Time, loc)
+		rezoned[i] = t
+	}
+
+	return rezoned
+}
+
+// unparsed holds the values of the date and time fields of an event or talk which could not be parsed
+// when it was decoded from JSON, by field name, so that validation can report them.
+type unparsed map[string]string
+
+// value returns the unparsed value of the field, and false if it was parsed or not given.
+func (u unparsed) value(field string) (string, bool) {
+	v, ok := u[field]
+
+	return v, ok
+}
+
+// set records the outcome of parsing the field.
+// The map is copied rather than changed in place, since copies of an event or talk share it.
+func (u *unparsed) set(field, value string, err error) {
+	if _, ok := (*u)[field]; !ok && err == nil {
+		return
+	}
+	updated := make(unparsed, len(*u)+1)
+	for k, v := range *u {
+		updated[k] = v
+	}
+	if err == nil {
+		delete(updated, field)
+	} else {
+		updated[field] = value
+	}
+	if len(updated) == 0 {
+		updated = nil
+	}
+	*u = updated
+}
+
+// MarshalJSON encodes the dates of the event in RFC 3339.
+func (e Event) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		event
+		DateStart string `json:"date_start"`
+		DateEnd   string `json:"date_end"`
+	}{
+		event:     event(e),
+		DateStart: formatTime(e.DateStart),
+		DateEnd:   formatTime(e.DateEnd),
+	})
+}
+
+// eventJSON is the JSON form of an event, with its dates as given.
+type eventJSON struct {
+	event
+	DateStart *string `json:"date_start"`
+	DateEnd   *string `json:"date_end"`
+}
+
+// event has the fields of Event without its JSON methods.
+type event Event
+
+// UnmarshalJSON decodes an event with dates in the legacy DD/MM/YYYY format or RFC 3339.
+// Only the fields present in b are changed. Dates which cannot be parsed are left as the zero time,
+// to be reported by validation rather than failing to decode the whole file.
+func (e *Event) UnmarshalJSON(b []byte) error {
+	aux := eventJSON{event: event(*e)}
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+	*e = aux.toEvent()
+
+	return nil
+}
+
+// toEvent returns the event with its dates parsed.
+func (aux eventJSON) toEvent() Event {
+	e := Event(aux.event)
+	dates := []struct {
+		field string
+		value *string
+		date  *time.Time
+	}{
+		{"date_start", aux.DateStart, &e.DateStart},
+		{"date_end", aux.DateEnd, &e.DateEnd},
+	}
+	for _, d := range dates {
+		if d.value == nil {
+			continue
+		}
+		*d.date = time.Time{}
+		var err error
+		if *d.value != "" {
+			*d.date, err = ParseDate(*d.value)
+		}
+		e.unparsed.set(d.field, *d.value, err)
+	}
+
+	return e
+}
+
+// MarshalJSON encodes the date and time of the talk in RFC 3339.
+// The time is empty if it is not known.
+func (t Talk) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		talk
+		Date string `json:"date"`
+		Time string `json:"time"`
+	}{
+		talk: talk(t),
+		Date: formatTime(t.Date),
+		Time: formatTime(t.Time),
+	})
+}
+
+// talkJSON is the JSON form of a talk, with its date and time as given.
+type talkJSON struct {
+	talk
+	Date *string `json:"date"`
+	Time *string `json:"time"`
+}
+
+// talk has the fields of Talk without its JSON methods.
+type talk Talk
+
+// UnmarshalJSON decodes a talk with a date in the legacy DD/MM/YYYY format or RFC 3339,
+// and a time in the HH:MM format on that date or in RFC 3339.
+// Only the fields present in b are changed. Dates and times which cannot be parsed are left as the zero time,
+// to be reported by validation rather than failing to decode the whole file.
+func (t *Talk) UnmarshalJSON(b []byte) error {
+	aux := talkJSON{talk: talk(*t)}
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+	*t = aux.toTalk()
+
+	return nil
+}
+
+// toTalk returns the talk with its date and time parsed.
+func (aux talkJSON) toTalk() Talk {
+	t := Talk(aux.talk)
+	if aux.Date != nil {
+		t.Date = time.Time{}
+		var err error
+		if *aux.Date != "" {
+			t.Date, err = ParseDate(*aux.Date)
+		}
+		t.unparsed.set("date", *aux.Date, err)
+	}
+	if aux.Time != nil {
+		t.Time = time.Time{}
+		var err error
+		// a time of day without a valid date is not reported, since the date is
+		if _, clockErr := time.Parse(timeFormat, *aux.Time); *aux.Time != "" && (clockErr != nil || !t.Date.IsZero()) {
+			t.Time, err = ParseTalkTime(t.Date, *aux.Time)
+		}
+		t.unparsed.set("time", *aux.Time, err)
+	}
+
+	return t
+}
@@ -0,0 +1,94 @@
+package data_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/addetz/testing-strategies-demo/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDate(t *testing.T) {
+	testCases := map[string]struct {
+		value          string
+		expectedDate   string
+		expectedErrMsg string
+	}{
+		"legacy date": {
+			value:        "28/06/2023",
+			expectedDate: "2023-06-28T00:00:00",
+		},
+		"iso date": {
+			value:        "2023-06-28",
+			expectedDate: "2023-06-28T00:00:00",
+		},
+		"date and time with offset": {
+			value:        "2023-06-28T09:30:00+02:00",
+			expectedDate: "2023-06-28T09:30:00",
+		},
+		"date with dashes": {
+			value:          "28-06-2023",
+			expectedErrMsg: `invalid date "28-06-2023": expected format 02/01/2006 or RFC 3339`,
+		},
+		"empty date": {
+			value:          "",
+			expectedErrMsg: `invalid date "": expected format 02/01/2006 or RFC 3339`,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			parsed, err := data.ParseDate(tc.value)
+			if tc.expectedErrMsg != "" {
+				assert.EqualError(t, err, tc.expectedErrMsg)
+				return
+			}
+			require.Nil(t, err)
+			assert.Equal(t, tc.expectedDate, parsed.Format("2006-01-02T15:04:05"))
+		})
+	}
+}
+
+func TestEventTimeZones(t *testing.T) {
+	amsterdam, err := time.LoadLocation("Europe/Amsterdam")
+	require.Nil(t, err)
+	london, err := time.LoadLocation("Europe/London")
+	require.Nil(t, err)
+	es, err := data.NewEventService([]data.Event{}, []data.Talk{})
+	require.Nil(t, err)
+	event, err := es.CreateEvent(decode[data.Event](
+		`{"ID": "event-1", "date_start": "2023-06-28", "date_end": "29/06/2023", "time_zone": "Europe/Amsterdam"}`))
+	require.Nil(t, err)
+	assert.Equal(t, time.Date(2023, 6, 28, 0, 0, 0, 0, amsterdam), event.DateStart)
+
+	t.Run("time of day is local to the event", func(t *testing.T) {
+		created, err := es.CreateTalk(event.ID, decode[data.Talk](`{"title": "local", "date": "28/06/2023", "time": "09:30"}`))
+		require.Nil(t, err)
+		assert.True(t, created.Time.Equal(time.Date(2023, 6, 28, 7, 30, 0, 0, time.UTC)))
+		b, err := json.Marshal(created)
+		require.Nil(t, err)
+		assert.Contains(t, string(b), `"date":"2023-06-28T00:00:00+02:00","time":"2023-06-28T09:30:00+02:00"`)
+	})
+	t.Run("time with offset keeps its instant", func(t *testing.T) {
+		created, err := es.CreateTalk(event.ID, decode[data.Talk](`{"title": "utc", "time": "2023-06-29T08:00:00Z"}`))
+		require.Nil(t, err)
+		assert.Equal(t, time.Date(2023, 6, 29, 10, 0, 0, 0, amsterdam), created.Time)
+		assert.Equal(t, time.Date(2023, 6, 29, 0, 0, 0, 0, amsterdam), created.Date)
+	})
+	t.Run("time outside event dates in its time zone", func(t *testing.T) {
+		created, err := es.CreateTalk(event.ID, decode[data.Talk](`{"title": "late", "time": "2023-06-29T22:30:00Z"}`))
+		assert.Nil(t, created)
+		assert.ErrorIs(t, err, data.ErrInvalidTalkDate)
+		assert.EqualError(t, err, "talk date 30/06/2023 is outside of event event-1 dates 28/06/2023-29/06/2023")
+	})
+	t.Run("changing time zone keeps local times", func(t *testing.T) {
+		updated, err := es.UpdateEvent(event.ID, decode[data.Event](
+			`{"date_start": "28/06/2023", "date_end": "29/06/2023", "time_zone": "Europe/London"}`))
+		require.Nil(t, err)
+		assert.Equal(t, time.Date(2023, 6, 28, 0, 0, 0, 0, london), updated.DateStart)
+		require.Len(t, updated.Talks, 2)
+		assert.Equal(t, time.Date(2023, 6, 28, 9, 30, 0, 0, london), updated.Talks[0].Time)
+		assert.Equal(t, time.Date(2023, 6, 29, 10, 0, 0, 0, london), updated.Talks[1].Time)
+	})
+}
@@ -0,0 +1,58 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrEventServiceInitialisation = errors.New("cannot initialise event service with nil events or talks")
+
+// Errors returned by EventService, to be matched with errors.Is.
+// The returned errors carry a more detailed message.
+var (
+	ErrEventNotFound       = errors.New("event not found")
+	ErrTalkNotFound        = errors.New("talk not found")
+	ErrEventExists         = errors.New("event already exists")
+	ErrTalkExists          = errors.New("talk already exists")
+	ErrEmptyEventID        = errors.New("event ID cannot be empty")
+	ErrEmptyTalkTitle      = errors.New("talk title cannot be empty")
+	ErrInvalidEventDates   = errors.New("invalid event dates")
+	ErrInvalidTalkDate     = errors.New("invalid talk date")
+	ErrInvalidTalkDuration = errors.New("invalid talk duration")
+	ErrInvalidTimeZone     = errors.New("invalid time zone")
+	ErrDayOutOfRange       = errors.New("day out of range")
+	ErrInvalidSort         = errors.New("invalid sort")
+	ErrInvalidCursor       = errors.New("invalid cursor")
+	ErrInvalidTalkQuery    = errors.New("invalid talk query")
+	ErrSpeakerNotFound     = errors.New("speaker not found")
+	ErrInvalidSpeaker      = errors.New("invalid speaker")
+	ErrInvalidImport       = errors.New("invalid import")
+	ErrInvalidArchive      = errors.New("invalid archive")
+)
+
+// kindError is an error with a detailed message which matches one of the sentinel errors above,
+// as well as any error wrapped in the message with %w.
+type kindError struct {
+	kind error
+	err  error
+}
+
+func (e *kindError) Error() string {
+	return e.err.Error()
+}
+
+func (e *kindError) Unwrap() []error {
+	if cause := errors.Unwrap(e.err); cause != nil {
+		return []error{e.kind, cause}
+	}
+
+	return []error{e.kind}
+}
+
+// newError returns an error with the formatted message which matches kind with errors.Is.
+func newError(kind error, format string, args ...any) error {
+	return &kindError{
+		kind: kind,
+		err:  fmt.Errorf(format, args...),
+	}
+}
@@ -1,26 +1,58 @@
 package data
 
+import "time"
+
 type Talk struct {
-	Title    string   `json:"title"`
-	Speakers []string `json:"speakers"`
-	Date     string   `json:"date"`
-	Time     string   `json:"time"`
-	EventID  string   `json:"event_id"`
+	ID    string `json:"id"`
+	Title string `json:"title"`
+	// Speakers holds the names of the speakers, in the same order as SpeakerIDs.
+	Speakers   []string `json:"speakers"`
+	SpeakerIDs []string `json:"speaker_ids,omitempty"`
+	// Date is midnight of the day of the talk, in the time zone of its event.
+	Date time.Time `json:"date"`
+	// Time is the start of the talk in the time zone of its event, or the zero time if it is not known.
+	Time time.Time `json:"time"`
+	// Duration is the length of the talk in minutes, or 0 if it is not known.
+	Duration int    `json:"duration,omitempty"`
+	Room     string `json:"room,omitempty"`
+	Track    string `json:"track,omitempty"`
+	EventID  string `json:"event_id"`
+
+	unparsed unparsed
 }
 
 type Event struct {
-	ID        string `json:"ID"`
-	Name      string `json:"name"`
-	DateStart string `json:"date_start"`
-	DateEnd   string `json:"date_end"`
-	Location  string `json:"location"`
-	Talks     []Talk `json:"-"`
+	ID   string `json:"ID"`
+	Name string `json:"name"`
+	// DateStart and DateEnd are midnight of the first and last day of the event, in its time zone.
+	DateStart time.Time `json:"date_start"`
+	DateEnd   time.Time `json:"date_end"`
+	// TimeZone is the IANA name of the time zone of the event, such as Europe/Amsterdam, or empty for UTC.
+	TimeZone string `json:"time_zone,omitempty"`
+	Location string `json:"location"`
+	Talks    []Talk `json:"-"`
+
+	unparsed unparsed
 }
 
 type Events struct {
 	Events []Event `json:"events"`
+	// NextCursor continues a paged query, and is empty on the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
 }
 
 type Talks struct {
 	Talks []Talk `json:"talks"`
 }
+
+type Speaker struct {
+	ID      string   `json:"id"`
+	Name    string   `json:"name"`
+	Bio     string   `json:"bio,omitempty"`
+	Company string   `json:"company,omitempty"`
+	Links   []string `json:"links,omitempty"`
+}
+
+type Speakers struct {
+	Speakers []Speaker `json:"speakers"`
+}
@@ -1,65 +1,137 @@
 package data
 
 import (
-	"errors"
+	"crypto/sha1"
+	"encoding/hex"
 	"fmt"
-	"log"
+	"io"
+	"slices"
+	"sync"
 	"time"
 )
 
-const dateFormat = "02/01/2006"
+// EventService is safe for concurrent use.
+// Writes never modify a talks slice in place, so events handed out
+// to readers are not affected by later writes.
+type EventService struct {
+	// mu serialises writes, which read, modify and save an event
+	mu   sync.RWMutex
+	repo EventRepository
+	// indexMu guards index, which is nil when the data has changed since it was built.
+	// It is acquired after mu, and only written to build or invalidate the index.
+	indexMu sync.RWMutex
+	index   *searchIndex
+	// modified is when the data was last changed through the service, guarded by mu
+	modified time.Time
+}
 
-var ErrEventServiceInitialisation = errors.New("cannot initialise event service with nil events or talks")
+// Option configures the EventService returned by NewEventService.
+type Option func(*options)
 
-type EventService struct {
-	// uuid is key to events map
-	events map[string]Event
+type options struct {
+	strict   bool
+	speakers []Speaker
+}
+
+// WithStrictValidation makes NewEventService return a *ValidationError
+// if Validate finds any problem in the given events and talks,
+// instead of dropping invalid talks.
+func WithStrictValidation() Option {
+	return func(o *options) {
+		o.strict = true
+	}
+}
+
+// WithSpeakers adds the details of speakers to NewEventService,
+// which are referenced by the talks with the speakers of the same names or IDs.
+func WithSpeakers(speakers []Speaker) Option {
+	return func(o *options) {
+		o.speakers = speakers
+	}
 }
 
 // NewEventService initialises and returns and instance to EventService give, slices of events and talks,
 // or an error if either events or talks are nil.
-func NewEventService(ev []Event, talks []Talk) (*EventService, error) {
+// The events and talks are kept in memory.
+func NewEventService(ev []Event, talks []Talk, opts ...Option) (*EventService, error) {
 	if ev == nil || talks == nil {
 		return nil, ErrEventServiceInitialisation
 	}
-	es := &EventService{
-		events: make(map[string]Event),
-	}
-	for _, e := range ev {
-		es.events[e.ID] = e
+	var o options
+	for _, opt := range opts {
+		opt(&o)
 	}
-	for _, t := range talks {
-		event, ok := es.events[t.EventID]
-		if !ok {
-			log.Printf("key %s not found; dropping invalid talk\n", t.EventID)
-			continue
+	if o.strict {
+		if report := Validate(ev, talks, o.speakers); !report.Valid() {
+			return nil, &ValidationError{Report: report}
 		}
-		event.Talks = append(event.Talks, t)
-		es.events[t.EventID] = event
 	}
+	repo := NewMemoryRepository()
+	if _, err := Seed(repo, ev, talks, o.speakers); err != nil {
+		return nil, err
+	}
+	// build the search index up front rather than on the first search
+	seeded, err := repo.List()
+	if err != nil {
+		return nil, err
+	}
+	es := NewEventServiceWithRepository(repo)
+	es.index = newSearchIndex(seeded)
 
 	return es, nil
 }
 
-// GetEvents returns the full list of events.
-func (es *EventService) GetEvents() Events {
-	var events []Event
-	for _, ev := range es.events {
-		events = append(events, ev)
+// NewEventServiceWithRepository returns an EventService serving the events stored in the given repository.
+func NewEventServiceWithRepository(repo EventRepository) *EventService {
+	return &EventService{
+		repo:     repo,
+		modified: time.Now(),
 	}
+}
+
+// LastModified returns when the data was last changed through the service,
+// or when the service was created if it has not been changed since.
+func (es *EventService) LastModified() time.Time {
+	es.mu.RLock()
+	defer es.mu.RUnlock()
+	return es.modified
+}
 
-	return Events{
-		Events: events,
+// Close releases the resources held by the repository, such as its database connection, if it holds any.
+func (es *EventService) Close() error {
+	if c, ok := es.repo.(io.Closer); ok {
+		return c.Close()
 	}
+
+	return nil
+}
+
+// GetEvents returns the full list of events sorted by start date,
+// or an error if the events cannot be read from the repository.
+func (es *EventService) GetEvents() (Events, error) {
+	return es.QueryEvents(EventQuery{})
 }
 
 // GetEvents returns the event corresponding to the given ID,
 // or an error if no event is found.
 func (es *EventService) GetEvent(id string) (*Event, error) {
-	event, ok := es.events[id]
+	es.mu.RLock()
+	defer es.mu.RUnlock()
+	return es.getEvent(id)
+}
+
+// getEvent returns a copy of the event corresponding to the given ID.
+// The caller must hold es.mu.
+func (es *EventService) getEvent(id string) (*Event, error) {
+	event, ok, err := es.repo.Get(id)
+	if err != nil {
+		return nil, err
+	}
 	if !ok {
-		return nil, fmt.Errorf("no event for id %s", id)
+		return nil, newError(ErrEventNotFound, "no event for id %s", id)
 	}
+	// cap the talks so that appending to them never writes to the shared array
+	event.Talks = event.Talks[:len(event.Talks):len(event.Talks)]
 
 	return &event, nil
 }
@@ -80,34 +152,351 @@ func (es *EventService) GetEventTalks(id string) (*Talks, error) {
 // or an error if no event is found.
 func (es *EventService) GetEventFilteredTalks(id string, day int) (*Talks, error) {
 	if day < 1 {
-		return nil, fmt.Errorf("day must be > 1, but was %d", day)
+		return nil, newError(ErrDayOutOfRange, "day must be > 1, but was %d", day)
 	}
-	event, err := es.GetEvent(id)
+	return es.QueryTalks(id, TalkQuery{Day: day})
+}
+
+// eventDay returns the date of the given day of the event, counting its start date as day 1,
+// or an error if the event dates are invalid or the day is after the event ends.
+func eventDay(event *Event, day int) (time.Time, error) {
+	if event.DateStart.IsZero() || event.DateEnd.IsZero() {
+		return time.Time{}, newError(ErrInvalidEventDates, "event %s has no valid dates", event.ID)
+	}
+
+	// minus 1 to count start date as day 1, by calendar day so that daylight saving changes are skipped
+	filteredDate := event.DateStart.AddDate(0, 0, day-1)
+	if filteredDate.After(event.DateEnd) {
+		return time.Time{}, newError(ErrDayOutOfRange, "filtered date %v is after event end date %v", formatDate(filteredDate), formatDate(event.DateEnd))
+	}
+
+	return filteredDate, nil
+}
+
+// CreateEvent adds the given event to the service and returns it,
+// or an error if the event is invalid or an event with the same ID already exists.
+// Its dates are moved to its time zone.
+func (es *EventService) CreateEvent(e Event) (*Event, error) {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	if err := validateEvent(e); err != nil {
+		return nil, err
+	}
+	e.Talks = nil
+	e = localise(e)
+	_, ok, err := es.repo.Get(e.ID)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return nil, newError(ErrEventExists, "event with id %s already exists", e.ID)
+	}
+	if err := es.save(e); err != nil {
+		return nil, err
+	}
+
+	return &e, nil
+}
+
+// UpdateEvent replaces the details of the event corresponding to the given id and returns it,
+// or an error if no event is found or the new details are invalid.
+// The dates and talks of the event are kept at the same local times if its time zone changes.
+func (es *EventService) UpdateEvent(id string, e Event) (*Event, error) {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	existing, err := es.getEvent(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return es.updateEvent(*existing, e)
+}
+
+// PatchEvent applies patch to a copy of the event corresponding to the given id and saves it as UpdateEvent does,
+// or returns an error if no event is found, patch fails or the patched details are invalid.
+// The event cannot change between being read and saved, so concurrent patches are never lost.
+func (es *EventService) PatchEvent(id string, patch func(*Event) error) (*Event, error) {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	existing, err := es.getEvent(id)
+	if err != nil {
+		return nil, err
+	}
+	e := *existing
+	// the patch may decode into the talks, which must not write to the stored ones
+	e.Talks = slices.Clone(existing.Talks)
+	if err := patch(&e); err != nil {
+		return nil, err
+	}
+
+	return es.updateEvent(*existing, e)
+}
+
+// updateEvent replaces the existing event with e, keeping its ID and talks. The caller must hold mu.
+func (es *EventService) updateEvent(existing, e Event) (*Event, error) {
+	e.ID = existing.ID
+	if err := validateEvent(e); err != nil {
+		return nil, err
+	}
+	if e.TimeZone != existing.TimeZone {
+		e = rezoneDates(e)
+	}
+	e.Talks = nil
+	e = localise(e)
+	e.Talks = existing.Talks
+	if e.TimeZone != existing.TimeZone {
+		e.Talks = rezoneTalks(existing.Talks, e.location())
+	}
+	for _, t := range e.Talks {
+		if err := checkTalkDate(e, t); err != nil {
+			return nil, newError(ErrInvalidEventDates, "new dates exclude talk %s: %v", t.ID, err)
+		}
+	}
+	if err := es.save(e); err != nil {
+		return nil, err
+	}
+
+	return &e, nil
+}
+
+// DeleteEvent removes the event corresponding to the given id together with its talks,
+// or returns an error if no event is found.
+func (es *EventService) DeleteEvent(id string) error {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	if _, err := es.getEvent(id); err != nil {
+		return err
+	}
+
+	if err := es.repo.Delete(id); err != nil {
+		return err
+	}
+	es.changed()
+
+	return nil
+}
+
+// validateEvent checks that the event has an ID, a known time zone and valid dates,
+// with the start date not after the end date.
+func validateEvent(e Event) error {
+	if e.ID == "" {
+		return ErrEmptyEventID
+	}
+	if _, err := loadLocation(e.TimeZone); err != nil {
+		return newError(ErrInvalidTimeZone, "unknown time_zone %q", e.TimeZone)
+	}
+	for _, field := range []string{"date_start", "date_end"} {
+		if value, ok := e.unparsed.value(field); ok {
+			return newError(ErrInvalidEventDates, "invalid %s %q: expected format %s or RFC 3339", field, value, dateFormat)
+		}
+	}
+	if e.DateStart.IsZero() {
+		return newError(ErrInvalidEventDates, "date_start is required")
+	}
+	if e.DateEnd.IsZero() {
+		return newError(ErrInvalidEventDates, "date_end is required")
+	}
+	e = localise(e)
+	if e.DateStart.After(e.DateEnd) {
+		return newError(ErrInvalidEventDates, "date_start %s is after date_end %s", formatDate(e.DateStart), formatDate(e.DateEnd))
+	}
+
+	return nil
+}
+
+// GetTalk returns the talk corresponding to the given talkID of the event corresponding to the given eventID,
+// or an error if either is not found.
+func (es *EventService) GetTalk(eventID, talkID string) (*Talk, error) {
+	es.mu.RLock()
+	defer es.mu.RUnlock()
+	event, err := es.getEvent(eventID)
 	if err != nil {
 		return nil, err
 	}
-	startDate, err := time.Parse(dateFormat, event.DateStart)
+	i := findTalk(event.Talks, talkID)
+	if i < 0 {
+		return nil, newError(ErrTalkNotFound, "no talk for id %s in event %s", talkID, eventID)
+	}
+	talk := event.Talks[i]
+
+	return &talk, nil
+}
+
+// CreateTalk adds the given talk to the event corresponding to the given eventID and returns it,
+// or an error if no event is found or the talk is invalid.
+// A stable ID is generated for the talk if it does not have one.
+func (es *EventService) CreateTalk(eventID string, t Talk) (*Talk, error) {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	event, err := es.getEvent(eventID)
 	if err != nil {
 		return nil, err
 	}
-	endDate, err := time.Parse(dateFormat, event.DateEnd)
+	t.EventID = eventID
+	if err := validateTalk(*event, t); err != nil {
+		return nil, err
+	}
+	t = localiseTalk(t, event.location())
+	if t.ID == "" {
+		t.ID = newTalkID(*event, t)
+	}
+	if findTalk(event.Talks, t.ID) >= 0 {
+		return nil, newError(ErrTalkExists, "talk with id %s already exists in event %s", t.ID, eventID)
+	}
+	created, err := es.resolveSpeakers(&t)
+	if err != nil {
+		return nil, err
+	}
+	event.Talks = append(append([]Talk{}, event.Talks...), t)
+	if err := es.save(*event); err != nil {
+		return nil, err
+	}
+	if err := es.saveSpeakers(created); err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+// UpdateTalk replaces the details of the talk corresponding to the given talkID and returns it,
+// or an error if either the event or talk is not found or the new details are invalid.
+func (es *EventService) UpdateTalk(eventID, talkID string, t Talk) (*Talk, error) {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	event, err := es.getEvent(eventID)
+	if err != nil {
+		return nil, err
+	}
+	i := findTalk(event.Talks, talkID)
+	if i < 0 {
+		return nil, newError(ErrTalkNotFound, "no talk for id %s in event %s", talkID, eventID)
+	}
+	t.ID = talkID
+	t.EventID = eventID
+	if err := validateTalk(*event, t); err != nil {
+		return nil, err
+	}
+	t = localiseTalk(t, event.location())
+	created, err := es.resolveSpeakers(&t)
 	if err != nil {
 		return nil, err
 	}
+	event.Talks = append([]Talk{}, event.Talks...)
+	event.Talks[i] = t
+	if err := es.save(*event); err != nil {
+		return nil, err
+	}
+	if err := es.saveSpeakers(created); err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
 
-	// minus 1 to count start date as day 1
-	filteredDate := startDate.Add(time.Hour * 24 * time.Duration(day-1))
-	searchDate := filteredDate.Format(dateFormat)
-	if filteredDate.After(endDate) {
-		return nil, fmt.Errorf("filtered date %v is after event end date %v", searchDate, event.DateEnd)
+// DeleteTalk removes the talk corresponding to the given talkID from the event corresponding to the given eventID,
+// or returns an error if either is not found.
+func (es *EventService) DeleteTalk(eventID, talkID string) error {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	event, err := es.getEvent(eventID)
+	if err != nil {
+		return err
+	}
+	i := findTalk(event.Talks, talkID)
+	if i < 0 {
+		return newError(ErrTalkNotFound, "no talk for id %s in event %s", talkID, eventID)
 	}
+	talks := append([]Talk{}, event.Talks[:i]...)
+	event.Talks = append(talks, event.Talks[i+1:]...)
+
+	return es.save(*event)
+}
 
-	filteredTalks := &Talks{}
-	for _, t := range event.Talks {
-		if t.Date == searchDate {
-			filteredTalks.Talks = append(filteredTalks.Talks, t)
+// save stores the event in the repository and records the change.
+// The caller must hold es.mu for writing.
+func (es *EventService) save(e Event) error {
+	if err := es.repo.Save(e); err != nil {
+		return err
+	}
+	es.changed()
+
+	return nil
+}
+
+// changed marks the search index as stale and updates the last modified time.
+// The caller must hold es.mu for writing.
+func (es *EventService) changed() {
+	es.invalidateIndex()
+	es.modified = time.Now()
+}
+
+// findTalk returns the index of the talk with the given id, or -1 if there is none.
+func findTalk(talks []Talk, id string) int {
+	for i, t := range talks {
+		if t.ID == id {
+			return i
 		}
 	}
 
-	return filteredTalks, nil
+	return -1
+}
+
+// newTalkID derives an ID from the talk's event, title, date and time,
+// so that the same talk gets the same ID every time the data is loaded.
+// The date and time are hashed in the legacy formats, so IDs stay the same as before dates were parsed.
+// A numeric suffix is added if the event already has a talk with that ID.
+func newTalkID(event Event, t Talk) string {
+	base := talkIDBase(event, t)
+	id := base
+	for n := 2; findTalk(event.Talks, id) >= 0; n++ {
+		id = fmt.Sprintf("%s-%d", base, n)
+	}
+
+	return id
+}
+
+// talkIDBase returns the ID derived from the talk's event, title, date and time, without a suffix.
+func talkIDBase(event Event, t Talk) string {
+	sum := sha1.Sum([]byte(event.ID + "|" + t.Title + "|" + formatDate(t.Date) + "|" + formatClock(t.Time)))
+
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// validateTalk checks that the talk has a title, a valid time and a date
+// that lies within the dates of the given event.
+func validateTalk(event Event, t Talk) error {
+	if t.Title == "" {
+		return ErrEmptyTalkTitle
+	}
+	if t.Duration < 0 {
+		return newError(ErrInvalidTalkDuration, "talk duration must not be negative, but was %d", t.Duration)
+	}
+	if value, ok := t.unparsed.value("date"); ok {
+		return newError(ErrInvalidTalkDate, "invalid talk date %q: expected format %s or RFC 3339", value, dateFormat)
+	}
+	if value, ok := t.unparsed.value("time"); ok {
+		return newError(ErrInvalidTalkDate, "invalid talk time %q: expected format %s or RFC 3339", value, timeFormat)
+	}
+	if t.Date.IsZero() && t.Time.IsZero() {
+		return newError(ErrInvalidTalkDate, "talk date is required")
+	}
+	if event.DateStart.IsZero() || event.DateEnd.IsZero() {
+		return newError(ErrInvalidEventDates, "event %s has no valid dates", event.ID)
+	}
+
+	return checkTalkDate(event, localiseTalk(t, event.location()))
+}
+
+// checkTalkDate returns an error if the talk date is outside the dates of the given event.
+// The talk must be in the time zone of the event. Missing dates are not checked.
+func checkTalkDate(event Event, t Talk) error {
+	if t.Date.IsZero() || event.DateStart.IsZero() || event.DateEnd.IsZero() {
+		return nil
+	}
+	if t.Date.Before(event.DateStart) || t.Date.After(event.DateEnd) {
+		return newError(ErrInvalidTalkDate, "talk date %s is outside of event %s dates %s-%s",
+			formatDate(t.Date), event.ID, formatDate(event.DateStart), formatDate(event.DateEnd))
+	}
+
+	return nil
 }
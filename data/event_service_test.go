@@ -1,8 +1,13 @@
 package data_test
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/addetz/testing-strategies-demo/data"
 	"github.com/stretchr/testify/assert"
@@ -17,6 +22,7 @@ func TestNewEventService(t *testing.T) {
 	}
 	talks := []data.Talk{
 		{
+			ID:      "talk-1-1",
 			EventID: "event-1",
 			Title:   "event 1 talk 1",
 		},
@@ -57,7 +63,8 @@ func TestGetEvents(t *testing.T) {
 	assert.NotNil(t, es)
 
 	t.Run("get events", func(t *testing.T) {
-		fetched := es.GetEvents()
+		fetched, err := es.GetEvents()
+		require.Nil(t, err)
 		assert.Len(t, fetched.Events, len(events))
 		for _, e := range events {
 			assert.Contains(t, fetched.Events, e)
@@ -80,14 +87,17 @@ func TestGetEvent(t *testing.T) {
 
 	talks := []data.Talk{
 		{
+			ID:      "talk-1-1",
 			EventID: "event-1",
 			Title:   "event 1 talk 1",
 		},
 		{
+			ID:      "talk-1-2",
 			EventID: "event-1",
 			Title:   "event 1 talk 2",
 		},
 		{
+			ID:      "talk-2-1",
 			EventID: "event-2",
 			Title:   "event 2 talk 1",
 		},
@@ -125,7 +135,8 @@ func TestGetEvent(t *testing.T) {
 		ev, err := es.GetEvent("event-99")
 		assert.Nil(t, ev)
 		assert.NotNil(t, err)
-		assert.Equal(t, "no event for id event-99", err.Error())
+		assert.ErrorIs(t, err, data.ErrEventNotFound)
+		assert.EqualError(t, err, "no event for id event-99")
 	})
 }
 
@@ -168,13 +179,15 @@ func TestGetEventTalks(t *testing.T) {
 
 	talks := []data.Talk{
 		{
+			ID:      "talk-1-1",
 			EventID: eventID,
 			Title:   "event 1 talk 1",
 		},
 		{
+			ID:      "talk-1-2",
 			EventID: eventID,
 			Title:   "event 1 talk 2",
-			Date:    "01/01/2010",
+			Date:    date("01/01/2010"),
 		},
 	}
 
@@ -183,9 +196,10 @@ func TestGetEventTalks(t *testing.T) {
 	assert.NotNil(t, es)
 
 	testCases := map[string]struct {
-		eventID       string
-		expectedTalks []data.Talk
-		expectedErr   error
+		eventID        string
+		expectedTalks  []data.Talk
+		expectedErr    error
+		expectedErrMsg string
 	}{
 		"multiple talks": {
 			eventID:       eventID,
@@ -196,8 +210,9 @@ func TestGetEventTalks(t *testing.T) {
 			expectedTalks: []data.Talk{},
 		},
 		"invalid event": {
-			eventID:     "invalid-event",
-			expectedErr: errors.New("no event for id invalid-event"),
+			eventID:        "invalid-event",
+			expectedErr:    data.ErrEventNotFound,
+			expectedErrMsg: "no event for id invalid-event",
 		},
 	}
 	for name, tc := range testCases {
@@ -205,7 +220,8 @@ func TestGetEventTalks(t *testing.T) {
 			talks, err := es.GetEventTalks(tc.eventID)
 			if tc.expectedErr != nil {
 				assert.Nil(t, talks)
-				assert.Equal(t, tc.expectedErr, err)
+				assert.ErrorIs(t, err, tc.expectedErr)
+				assert.EqualError(t, err, tc.expectedErrMsg)
 				return
 			}
 			assert.Nil(t, err)
@@ -221,26 +237,29 @@ func TestGetEventFilteredTalks(t *testing.T) {
 	events := []data.Event{
 		{
 			ID:        eventID,
-			DateStart: "01/01/2010",
-			DateEnd:   "02/01/2010",
+			DateStart: date("01/01/2010"),
+			DateEnd:   date("02/01/2010"),
 		},
 	}
 
 	talks := []data.Talk{
 		{
+			ID:      "talk-1-1",
 			EventID: eventID,
 			Title:   "event 1 talk 1",
-			Date:    "01/01/2010",
+			Date:    date("01/01/2010"),
 		},
 		{
+			ID:      "talk-1-2",
 			EventID: eventID,
 			Title:   "event 1 talk 2",
-			Date:    "01/01/2010",
+			Date:    date("01/01/2010"),
 		},
 		{
+			ID:      "talk-1-3",
 			EventID: eventID,
 			Title:   "event 1 talk 3",
-			Date:    "02/01/2010",
+			Date:    date("02/01/2010"),
 		},
 	}
 
@@ -249,10 +268,11 @@ func TestGetEventFilteredTalks(t *testing.T) {
 	assert.NotNil(t, es)
 
 	testCases := map[string]struct {
-		eventID       string
-		day           int
-		expectedTalks []data.Talk
-		expectedErr   error
+		eventID        string
+		day            int
+		expectedTalks  []data.Talk
+		expectedErr    error
+		expectedErrMsg string
 	}{
 		"multiple talks": {
 			eventID:       eventID,
@@ -265,24 +285,28 @@ func TestGetEventFilteredTalks(t *testing.T) {
 			expectedTalks: []data.Talk{talks[2]},
 		},
 		"day after end": {
-			eventID:     eventID,
-			day:         3,
-			expectedErr: errors.New("filtered date 03/01/2010 is after event end date 02/01/2010"),
+			eventID:        eventID,
+			day:            3,
+			expectedErr:    data.ErrDayOutOfRange,
+			expectedErrMsg: "filtered date 03/01/2010 is after event end date 02/01/2010",
 		},
 		"invalid event": {
-			eventID:     "invalid-event",
-			day:         1,
-			expectedErr: errors.New("no event for id invalid-event"),
+			eventID:        "invalid-event",
+			day:            1,
+			expectedErr:    data.ErrEventNotFound,
+			expectedErrMsg: "no event for id invalid-event",
 		},
 		"negative day": {
-			eventID:     eventID,
-			day:         -1,
-			expectedErr: errors.New("day must be > 1, but was -1"),
+			eventID:        eventID,
+			day:            -1,
+			expectedErr:    data.ErrDayOutOfRange,
+			expectedErrMsg: "day must be > 1, but was -1",
 		},
 		"zero day": {
-			eventID:     eventID,
-			day:         0,
-			expectedErr: errors.New("day must be > 1, but was 0"),
+			eventID:        eventID,
+			day:            0,
+			expectedErr:    data.ErrDayOutOfRange,
+			expectedErrMsg: "day must be > 1, but was 0",
 		},
 	}
 	for name, tc := range testCases {
@@ -290,7 +314,8 @@ func TestGetEventFilteredTalks(t *testing.T) {
 			talks, err := es.GetEventFilteredTalks(tc.eventID, tc.day)
 			if tc.expectedErr != nil {
 				assert.Nil(t, talks)
-				assert.Equal(t, tc.expectedErr, err)
+				assert.ErrorIs(t, err, tc.expectedErr)
+				assert.EqualError(t, err, tc.expectedErrMsg)
 				return
 			}
 			assert.Nil(t, err)
@@ -301,3 +326,612 @@ func TestGetEventFilteredTalks(t *testing.T) {
 		})
 	}
 }
+
+func TestCreateEvent(t *testing.T) {
+	es, err := data.NewEventService([]data.Event{
+		{
+			ID:        "event-1",
+			DateStart: date("01/01/2010"),
+			DateEnd:   date("02/01/2010"),
+		},
+	}, []data.Talk{})
+	require.Nil(t, err)
+
+	testCases := map[string]struct {
+		event          data.Event
+		expectedErr    error
+		expectedErrMsg string
+	}{
+		"valid event": {
+			event: data.Event{
+				ID:        "event-2",
+				Name:      "Event 2",
+				DateStart: date("01/02/2010"),
+				DateEnd:   date("03/02/2010"),
+			},
+		},
+		"single day event": {
+			event: data.Event{
+				ID:        "event-3",
+				DateStart: date("01/02/2010"),
+				DateEnd:   date("01/02/2010"),
+			},
+		},
+		"duplicate id": {
+			event: data.Event{
+				ID:        "event-1",
+				DateStart: date("01/01/2010"),
+				DateEnd:   date("02/01/2010"),
+			},
+			expectedErr:    data.ErrEventExists,
+			expectedErrMsg: "event with id event-1 already exists",
+		},
+		"empty id": {
+			event: data.Event{
+				DateStart: date("01/01/2010"),
+				DateEnd:   date("02/01/2010"),
+			},
+			expectedErr:    data.ErrEmptyEventID,
+			expectedErrMsg: "event ID cannot be empty",
+		},
+		"invalid start date": {
+			event:          decode[data.Event](`{"ID": "event-4", "date_start": "2010/01/01", "date_end": "02/01/2010"}`),
+			expectedErr:    data.ErrInvalidEventDates,
+			expectedErrMsg: `invalid date_start "2010/01/01": expected format 02/01/2006 or RFC 3339`,
+		},
+		"missing end date": {
+			event: data.Event{
+				ID:        "event-4",
+				DateStart: date("01/01/2010"),
+			},
+			expectedErr:    data.ErrInvalidEventDates,
+			expectedErrMsg: "date_end is required",
+		},
+		"unknown time zone": {
+			event: data.Event{
+				ID:        "event-4",
+				DateStart: date("01/01/2010"),
+				DateEnd:   date("02/01/2010"),
+				TimeZone:  "Mars/Olympus_Mons",
+			},
+			expectedErr:    data.ErrInvalidTimeZone,
+			expectedErrMsg: `unknown time_zone "Mars/Olympus_Mons"`,
+		},
+		"start after end": {
+			event: data.Event{
+				ID:        "event-4",
+				DateStart: date("03/01/2010"),
+				DateEnd:   date("02/01/2010"),
+			},
+			expectedErr:    data.ErrInvalidEventDates,
+			expectedErrMsg: "date_start 03/01/2010 is after date_end 02/01/2010",
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			created, err := es.CreateEvent(tc.event)
+			if tc.expectedErr != nil {
+				assert.Nil(t, created)
+				assert.ErrorIs(t, err, tc.expectedErr)
+				assert.EqualError(t, err, tc.expectedErrMsg)
+				return
+			}
+			require.Nil(t, err)
+			assert.Equal(t, tc.event, *created)
+			fetched, err := es.GetEvent(tc.event.ID)
+			require.Nil(t, err)
+			assert.Equal(t, tc.event, *fetched)
+		})
+	}
+}
+
+func TestUpdateEvent(t *testing.T) {
+	eventID := "event-1"
+	events := []data.Event{
+		{
+			ID:        eventID,
+			Name:      "Event 1",
+			DateStart: date("01/01/2010"),
+			DateEnd:   date("02/01/2010"),
+		},
+	}
+	talks := []data.Talk{
+		{
+			ID:      "talk-1-1",
+			EventID: eventID,
+			Title:   "event 1 talk 1",
+			Date:    date("01/01/2010"),
+		},
+	}
+	es, err := data.NewEventService(events, talks)
+	require.Nil(t, err)
+
+	t.Run("update keeps talks", func(t *testing.T) {
+		updated, err := es.UpdateEvent(eventID, data.Event{
+			Name:      "Event 1 renamed",
+			DateStart: date("01/01/2010"),
+			DateEnd:   date("03/01/2010"),
+			Location:  "Amsterdam",
+		})
+		require.Nil(t, err)
+		assert.Equal(t, eventID, updated.ID)
+		assert.Equal(t, "Event 1 renamed", updated.Name)
+		assert.Equal(t, date("03/01/2010"), updated.DateEnd)
+		assert.Equal(t, talks, updated.Talks)
+		fetched, err := es.GetEvent(eventID)
+		require.Nil(t, err)
+		assert.Equal(t, *updated, *fetched)
+	})
+	t.Run("invalid dates", func(t *testing.T) {
+		updated, err := es.UpdateEvent(eventID, data.Event{
+			DateStart: date("01/01/2010"),
+			DateEnd:   date("31/12/2009"),
+		})
+		assert.Nil(t, updated)
+		assert.ErrorIs(t, err, data.ErrInvalidEventDates)
+		assert.EqualError(t, err, "date_start 01/01/2010 is after date_end 31/12/2009")
+	})
+	t.Run("invalid event", func(t *testing.T) {
+		updated, err := es.UpdateEvent("event-99", data.Event{
+			DateStart: date("01/01/2010"),
+			DateEnd:   date("02/01/2010"),
+		})
+		assert.Nil(t, updated)
+		assert.ErrorIs(t, err, data.ErrEventNotFound)
+		assert.EqualError(t, err, "no event for id event-99")
+	})
+}
+
+func TestPatchEvent(t *testing.T) {
+	eventID := "event-1"
+	events := []data.Event{
+		{
+			ID:        eventID,
+			Name:      "Event 1",
+			DateStart: date("01/01/2010"),
+			DateEnd:   date("02/01/2010"),
+		},
+	}
+	talks := []data.Talk{
+		{
+			ID:      "talk-1-1",
+			EventID: eventID,
+			Title:   "event 1 talk 1",
+			Date:    date("01/01/2010"),
+		},
+	}
+	es, err := data.NewEventService(events, talks)
+	require.Nil(t, err)
+
+	t.Run("patch keeps other fields and talks", func(t *testing.T) {
+		updated, err := es.PatchEvent(eventID, func(e *data.Event) error {
+			e.Location = "Amsterdam"
+			return nil
+		})
+		require.Nil(t, err)
+		assert.Equal(t, "Event 1", updated.Name)
+		assert.Equal(t, "Amsterdam", updated.Location)
+		assert.Equal(t, talks, updated.Talks)
+		fetched, err := es.GetEvent(eventID)
+		require.Nil(t, err)
+		assert.Equal(t, *updated, *fetched)
+	})
+	t.Run("failed patch is not saved", func(t *testing.T) {
+		patchErr := errors.New("invalid patch")
+		updated, err := es.PatchEvent(eventID, func(e *data.Event) error {
+			e.Name = "not saved"
+			e.Talks[0].Title = "not saved"
+			return patchErr
+		})
+		assert.Nil(t, updated)
+		assert.ErrorIs(t, err, patchErr)
+		fetched, err := es.GetEvent(eventID)
+		require.Nil(t, err)
+		assert.Equal(t, "Event 1", fetched.Name)
+		assert.Equal(t, talks, fetched.Talks)
+	})
+	t.Run("invalid dates", func(t *testing.T) {
+		updated, err := es.PatchEvent(eventID, func(e *data.Event) error {
+			e.DateEnd = date("31/12/2009")
+			return nil
+		})
+		assert.Nil(t, updated)
+		assert.ErrorIs(t, err, data.ErrInvalidEventDates)
+	})
+	t.Run("invalid event", func(t *testing.T) {
+		updated, err := es.PatchEvent("event-99", func(e *data.Event) error {
+			return nil
+		})
+		assert.Nil(t, updated)
+		assert.ErrorIs(t, err, data.ErrEventNotFound)
+	})
+	t.Run("concurrent patches are not lost", func(t *testing.T) {
+		const patches = 50
+		var wg sync.WaitGroup
+		for i := 0; i < patches; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := es.PatchEvent(eventID, func(e *data.Event) error {
+					e.Name += "!"
+					return nil
+				})
+				assert.Nil(t, err)
+			}()
+		}
+		wg.Wait()
+		fetched, err := es.GetEvent(eventID)
+		require.Nil(t, err)
+		assert.Equal(t, "Event 1"+strings.Repeat("!", patches), fetched.Name)
+	})
+}
+
+func TestDeleteEvent(t *testing.T) {
+	eventID := "event-1"
+	es, err := data.NewEventService([]data.Event{
+		{
+			ID: eventID,
+		},
+	}, []data.Talk{})
+	require.Nil(t, err)
+
+	t.Run("delete event", func(t *testing.T) {
+		err := es.DeleteEvent(eventID)
+		require.Nil(t, err)
+		ev, err := es.GetEvent(eventID)
+		assert.Nil(t, ev)
+		assert.NotNil(t, err)
+		fetched, err := es.GetEvents()
+		require.Nil(t, err)
+		assert.Len(t, fetched.Events, 0)
+	})
+	t.Run("invalid event", func(t *testing.T) {
+		err := es.DeleteEvent(eventID)
+		assert.ErrorIs(t, err, data.ErrEventNotFound)
+		assert.EqualError(t, err, "no event for id event-1")
+	})
+}
+
+func TestLastModified(t *testing.T) {
+	eventID := "event-1"
+	testCases := map[string]struct {
+		change          func(es *data.EventService) error
+		expectedChanged bool
+	}{
+		"read": {
+			change: func(es *data.EventService) error {
+				_, err := es.GetEvents()
+				return err
+			},
+		},
+		"create talk": {
+			change: func(es *data.EventService) error {
+				_, err := es.CreateTalk(eventID, data.Talk{Title: "New talk", Date: date("01/01/2010")})
+				return err
+			},
+			expectedChanged: true,
+		},
+		"failed update": {
+			change: func(es *data.EventService) error {
+				if _, err := es.UpdateEvent("event-9", data.Event{ID: "event-9"}); !errors.Is(err, data.ErrEventNotFound) {
+					return fmt.Errorf("expected ErrEventNotFound, got %v", err)
+				}
+				return nil
+			},
+		},
+		"delete event": {
+			change: func(es *data.EventService) error {
+				return es.DeleteEvent(eventID)
+			},
+			expectedChanged: true,
+		},
+		"unchanged archive import": {
+			change: func(es *data.EventService) error {
+				archive, err := es.Export()
+				if err != nil {
+					return err
+				}
+				_, err = es.ImportArchive(*archive, data.ArchiveImportOptions{Mode: data.ArchiveReplace})
+				return err
+			},
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			es, err := data.NewEventService([]data.Event{
+				{ID: eventID, DateStart: date("01/01/2010"), DateEnd: date("02/01/2010")},
+			}, []data.Talk{})
+			require.Nil(t, err)
+			before := es.LastModified()
+			require.False(t, before.IsZero())
+			require.Nil(t, tc.change(es))
+			assert.Equal(t, tc.expectedChanged, es.LastModified().After(before))
+		})
+	}
+}
+
+func TestNewEventServiceTalkIDs(t *testing.T) {
+	eventID := "event-1"
+	events := []data.Event{
+		{
+			ID:        eventID,
+			DateStart: date("01/01/2010"),
+			DateEnd:   date("02/01/2010"),
+		},
+	}
+	talks := []data.Talk{
+		{
+			EventID: eventID,
+			Title:   "event 1 talk 1",
+			Date:    date("01/01/2010"),
+		},
+		{
+			EventID: eventID,
+			Title:   "event 1 talk 1",
+			Date:    date("01/01/2010"),
+		},
+		{
+			ID:      "talk-1-3",
+			EventID: eventID,
+			Title:   "event 1 talk 3",
+			Date:    date("02/01/2010"),
+		},
+		{
+			EventID: eventID,
+			Title:   "talk after event",
+			Date:    date("03/01/2010"),
+		},
+	}
+
+	es, err := data.NewEventService(events, talks)
+	require.Nil(t, err)
+	fetched, err := es.GetEventTalks(eventID)
+	require.Nil(t, err)
+	require.Len(t, fetched.Talks, 3)
+	assert.NotEmpty(t, fetched.Talks[0].ID)
+	assert.Equal(t, fetched.Talks[0].ID+"-2", fetched.Talks[1].ID)
+	assert.Equal(t, "talk-1-3", fetched.Talks[2].ID)
+
+	t.Run("stable ids", func(t *testing.T) {
+		reloaded, err := data.NewEventService(events, talks)
+		require.Nil(t, err)
+		refetched, err := reloaded.GetEventTalks(eventID)
+		require.Nil(t, err)
+		assert.Equal(t, fetched, refetched)
+	})
+}
+
+func TestTalkCRUD(t *testing.T) {
+	eventID := "event-1"
+	events := []data.Event{
+		{
+			ID:        eventID,
+			DateStart: date("01/01/2010"),
+			DateEnd:   date("02/01/2010"),
+		},
+		{
+			ID: "event-2",
+		},
+	}
+	talks := []data.Talk{
+		{
+			ID:      "talk-1-1",
+			EventID: eventID,
+			Title:   "event 1 talk 1",
+			Date:    date("01/01/2010"),
+		},
+	}
+	es, err := data.NewEventService(events, talks)
+	require.Nil(t, err)
+
+	t.Run("get talk", func(t *testing.T) {
+		talk, err := es.GetTalk(eventID, "talk-1-1")
+		require.Nil(t, err)
+		assert.Equal(t, talks[0], *talk)
+	})
+	t.Run("get invalid talk", func(t *testing.T) {
+		talk, err := es.GetTalk(eventID, "talk-1-99")
+		assert.Nil(t, talk)
+		assert.ErrorIs(t, err, data.ErrTalkNotFound)
+		assert.EqualError(t, err, "no talk for id talk-1-99 in event event-1")
+	})
+	t.Run("create talk", func(t *testing.T) {
+		created, err := es.CreateTalk(eventID, data.Talk{
+			Title: "event 1 talk 2",
+			Date:  date("02/01/2010"),
+			Time:  dateTime("02/01/2010 10:00"),
+		})
+		require.Nil(t, err)
+		assert.NotEmpty(t, created.ID)
+		assert.Equal(t, eventID, created.EventID)
+		fetched, err := es.GetTalk(eventID, created.ID)
+		require.Nil(t, err)
+		assert.Equal(t, *created, *fetched)
+	})
+	t.Run("create duplicate talk id", func(t *testing.T) {
+		created, err := es.CreateTalk(eventID, data.Talk{
+			ID:    "talk-1-1",
+			Title: "event 1 talk 1",
+			Date:  date("01/01/2010"),
+		})
+		assert.Nil(t, created)
+		assert.ErrorIs(t, err, data.ErrTalkExists)
+		assert.EqualError(t, err, "talk with id talk-1-1 already exists in event event-1")
+	})
+	t.Run("create talk outside event dates", func(t *testing.T) {
+		created, err := es.CreateTalk(eventID, data.Talk{
+			Title: "event 1 talk 3",
+			Date:  date("03/01/2010"),
+		})
+		assert.Nil(t, created)
+		assert.ErrorIs(t, err, data.ErrInvalidTalkDate)
+		assert.EqualError(t, err, "talk date 03/01/2010 is outside of event event-1 dates 01/01/2010-02/01/2010")
+	})
+	t.Run("create talk without title", func(t *testing.T) {
+		created, err := es.CreateTalk(eventID, data.Talk{
+			Date: date("01/01/2010"),
+		})
+		assert.Nil(t, created)
+		assert.Equal(t, data.ErrEmptyTalkTitle, err)
+	})
+	t.Run("create talk for event without dates", func(t *testing.T) {
+		created, err := es.CreateTalk("event-2", data.Talk{
+			Title: "event 2 talk 1",
+			Date:  date("01/01/2010"),
+		})
+		assert.Nil(t, created)
+		assert.ErrorIs(t, err, data.ErrInvalidEventDates)
+		assert.EqualError(t, err, "event event-2 has no valid dates")
+	})
+	t.Run("update talk", func(t *testing.T) {
+		updated, err := es.UpdateTalk(eventID, "talk-1-1", data.Talk{
+			Title: "event 1 talk 1 updated",
+			Date:  date("02/01/2010"),
+		})
+		require.Nil(t, err)
+		assert.Equal(t, "talk-1-1", updated.ID)
+		assert.Equal(t, eventID, updated.EventID)
+		fetched, err := es.GetTalk(eventID, "talk-1-1")
+		require.Nil(t, err)
+		assert.Equal(t, *updated, *fetched)
+	})
+	t.Run("update event dates excluding talks", func(t *testing.T) {
+		updated, err := es.UpdateEvent(eventID, data.Event{
+			DateStart: date("01/01/2010"),
+			DateEnd:   date("01/01/2010"),
+		})
+		assert.Nil(t, updated)
+		assert.ErrorIs(t, err, data.ErrInvalidEventDates)
+	})
+	t.Run("delete talk", func(t *testing.T) {
+		err := es.DeleteTalk(eventID, "talk-1-1")
+		require.Nil(t, err)
+		talk, err := es.GetTalk(eventID, "talk-1-1")
+		assert.Nil(t, talk)
+		assert.NotNil(t, err)
+		fetched, err := es.GetEventTalks(eventID)
+		require.Nil(t, err)
+		assert.Len(t, fetched.Talks, 1)
+	})
+	t.Run("delete invalid talk", func(t *testing.T) {
+		err := es.DeleteTalk(eventID, "talk-1-1")
+		assert.ErrorIs(t, err, data.ErrTalkNotFound)
+		assert.EqualError(t, err, "no talk for id talk-1-1 in event event-1")
+	})
+}
+
+// TestEventServiceConcurrentAccess is most useful when run with the race detector:
+// go test -race ./data
+func TestEventServiceConcurrentAccess(t *testing.T) {
+	eventID := "event-1"
+	events := []data.Event{
+		{
+			ID:        eventID,
+			DateStart: date("01/01/2010"),
+			DateEnd:   date("02/01/2010"),
+		},
+	}
+	talks := []data.Talk{
+		{
+			EventID: eventID,
+			Title:   "event 1 talk 1",
+			Date:    date("01/01/2010"),
+		},
+	}
+	es, err := data.NewEventService(events, talks)
+	require.Nil(t, err)
+
+	const workers = 8
+	const iterations = 100
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(4)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				_, err := es.GetEvents()
+				assert.Nil(t, err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				talks, err := es.GetEventTalks(eventID)
+				assert.Nil(t, err)
+				for _, talk := range talks.Talks {
+					_ = talk.Title
+				}
+			}
+		}()
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				created, err := es.CreateTalk(eventID, data.Talk{
+					Title: fmt.Sprintf("worker %d talk %d", w, i),
+					Date:  date("02/01/2010"),
+				})
+				if assert.Nil(t, err) && i%2 == 0 {
+					assert.Nil(t, es.DeleteTalk(eventID, created.ID))
+				}
+			}
+		}(w)
+		go func(w int) {
+			defer wg.Done()
+			id := fmt.Sprintf("worker-event-%d", w)
+			for i := 0; i < iterations; i++ {
+				_, err := es.CreateEvent(data.Event{
+					ID:        id,
+					DateStart: date("01/01/2010"),
+					DateEnd:   date("02/01/2010"),
+				})
+				assert.Nil(t, err)
+				_, err = es.UpdateEvent(id, data.Event{
+					Name:      "updated",
+					DateStart: date("01/01/2010"),
+					DateEnd:   date("03/01/2010"),
+				})
+				assert.Nil(t, err)
+				assert.Nil(t, es.DeleteEvent(id))
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	fetched, err := es.GetEventTalks(eventID)
+	require.Nil(t, err)
+	assert.Len(t, fetched.Talks, 1+workers*iterations/2)
+	allEvents, err := es.GetEvents()
+	require.Nil(t, err)
+	assert.Len(t, allEvents.Events, 1)
+}
+
+// date returns midnight of the date in the DD/MM/YYYY format in UTC,
+// the time zone of events which do not have one.
+func date(value string) time.Time {
+	parsed, err := time.Parse("02/01/2006", value)
+	if err != nil {
+		panic(err)
+	}
+
+	return parsed
+}
+
+// decode returns the value decoded from the JSON in s.
+func decode[T any](s string) T {
+	var v T
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		panic(err)
+	}
+
+	return v
+}
+
+// dateTime returns the date and time in the DD/MM/YYYY HH:MM format in UTC.
+func dateTime(value string) time.Time {
+	parsed, err := time.Parse("02/01/2006 15:04", value)
+	if err != nil {
+		panic(err)
+	}
+
+	return parsed
+}
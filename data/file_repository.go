@@ -0,0 +1,282 @@
+package data
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+const (
+	eventsFileName   = "events.json"
+	talksFileName    = "talks.json"
+	speakersFileName = "speakers.json"
+)
+
+// FileRepository is an EventRepository which keeps its events in memory and writes them
+// to an events.json, a talks.json and a speakers.json file in its directory after every change.
+// The files have the same format as the ones embedded in the server.
+// A change which cannot be written is undone in memory, and leaves the files as they were.
+// Only a crash while the complete files are renamed into place can leave them out of step.
+type FileRepository struct {
+	dir string
+	// writeMu serialises writes to the files
+	writeMu sync.Mutex
+	mem     *MemoryRepository
+}
+
+// NewFileRepository returns a FileRepository storing its files in dir,
+// loading any events, talks and speakers already stored there.
+// The directory is created if it does not exist.
+func NewFileRepository(dir string) (*FileRepository, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create repository dir: %w", err)
+	}
+	var events Events
+	var talks Talks
+	var speakers Speakers
+	if err := readJSONFile(filepath.Join(dir, eventsFileName), &events); err != nil {
+		return nil, err
+	}
+	if err := readJSONFile(filepath.Join(dir, talksFileName), &talks); err != nil {
+		return nil, err
+	}
+	if err := readJSONFile(filepath.Join(dir, speakersFileName), &speakers); err != nil {
+		return nil, err
+	}
+	fr := &FileRepository{
+		dir: dir,
+		mem: NewMemoryRepository(),
+	}
+	for _, e := range groupTalks(events.Events, talks.Talks) {
+		if err := fr.mem.Save(e); err != nil {
+			return nil, err
+		}
+	}
+	for _, s := range speakers.Speakers {
+		if err := fr.mem.SaveSpeaker(s); err != nil {
+			return nil, err
+		}
+	}
+
+	return fr, nil
+}
+
+func (fr *FileRepository) List() ([]Event, error) {
+	return fr.mem.List()
+}
+
+func (fr *FileRepository) Get(id string) (Event, bool, error) {
+	return fr.mem.Get(id)
+}
+
+func (fr *FileRepository) Save(e Event) error {
+	fr.writeMu.Lock()
+	defer fr.writeMu.Unlock()
+	restore, err := fr.eventRestorer(e.ID)
+	if err != nil {
+		return err
+	}
+	if err := fr.mem.Save(e); err != nil {
+		return err
+	}
+
+	return fr.flush(restore)
+}
+
+func (fr *FileRepository) Delete(id string) error {
+	fr.writeMu.Lock()
+	defer fr.writeMu.Unlock()
+	restore, err := fr.eventRestorer(id)
+	if err != nil {
+		return err
+	}
+	if err := fr.mem.Delete(id); err != nil {
+		return err
+	}
+
+	return fr.flush(restore)
+}
+
+func (fr *FileRepository) ListSpeakers() ([]Speaker, error) {
+	return fr.mem.ListSpeakers()
+}
+
+func (fr *FileRepository) GetSpeaker(id string) (Speaker, bool, error) {
+	return fr.mem.GetSpeaker(id)
+}
+
+func (fr *FileRepository) SaveSpeaker(s Speaker) error {
+	fr.writeMu.Lock()
+	defer fr.writeMu.Unlock()
+	restore, err := fr.speakerRestorer(s.ID)
+	if err != nil {
+		return err
+	}
+	if err := fr.mem.SaveSpeaker(s); err != nil {
+		return err
+	}
+
+	return fr.flush(restore)
+}
+
+func (fr *FileRepository) DeleteSpeaker(id string) error {
+	fr.writeMu.Lock()
+	defer fr.writeMu.Unlock()
+	restore, err := fr.speakerRestorer(id)
+	if err != nil {
+		return err
+	}
+	if err := fr.mem.DeleteSpeaker(id); err != nil {
+		return err
+	}
+
+	return fr.flush(restore)
+}
+
+// eventRestorer returns a function which puts the event with the given ID back in memory as it is now,
+// or removes it if there is no such event yet.
+func (fr *FileRepository) eventRestorer(id string) (func(), error) {
+	prev, ok, err := fr.mem.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return func() {
+		// the memory repository never fails
+		if ok {
+			fr.mem.Save(prev)
+		} else {
+			fr.mem.Delete(id)
+		}
+	}, nil
+}
+
+// speakerRestorer returns a function which puts the speaker with the given ID back in memory as it is now,
+// or removes it if there is no such speaker yet.
+func (fr *FileRepository) speakerRestorer(id string) (func(), error) {
+	prev, ok, err := fr.mem.GetSpeaker(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return func() {
+		if ok {
+			fr.mem.SaveSpeaker(prev)
+		} else {
+			fr.mem.DeleteSpeaker(id)
+		}
+	}, nil
+}
+
+// flush writes all events, talks and speakers to the repository files,
+// calling restore to undo the change in memory if they cannot be written.
+// Every file is written to a temporary file before any of them replaces its predecessor,
+// so that a failed write leaves all files as they were.
+// The caller must hold fr.writeMu.
+func (fr *FileRepository) flush(restore func()) error {
+	if err := fr.writeFiles(); err != nil {
+		restore()
+		return err
+	}
+
+	return nil
+}
+
+// writeFiles writes all events, talks and speakers to the repository files.
+func (fr *FileRepository) writeFiles() error {
+	events, err := fr.mem.List()
+	if err != nil {
+		return err
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].ID < events[j].ID
+	})
+	talks := Talks{Talks: []Talk{}}
+	for _, e := range events {
+		talks.Talks = append(talks.Talks, e.Talks...)
+	}
+	speakers, err := fr.mem.ListSpeakers()
+	if err != nil {
+		return err
+	}
+	sort.Slice(speakers, func(i, j int) bool {
+		return speakers[i].ID < speakers[j].ID
+	})
+	files := []struct {
+		name string
+		v    any
+	}{
+		{eventsFileName, Events{Events: events}},
+		{talksFileName, talks},
+		{speakersFileName, Speakers{Speakers: speakers}},
+	}
+	tmps := make([]string, 0, len(files))
+	renamed := 0
+	defer func() {
+		for _, tmp := range tmps[renamed:] {
+			os.Remove(tmp)
+		}
+	}()
+	for _, f := range files {
+		tmp, err := writeTempJSONFile(filepath.Join(fr.dir, f.name), f.v)
+		if err != nil {
+			return err
+		}
+		tmps = append(tmps, tmp)
+	}
+	for i, tmp := range tmps {
+		if err := os.Rename(tmp, filepath.Join(fr.dir, files[i].name)); err != nil {
+			return err
+		}
+		renamed++
+	}
+
+	return nil
+}
+
+// readJSONFile decodes the file at path into v, leaving v untouched if the file does not exist.
+func readJSONFile(path string, v any) error {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return decodeJSON(path, b, v)
+}
+
+// writeTempJSONFile encodes v to a temporary file next to path, which is synced to disk,
+// and returns the name of the temporary file for the caller to rename to path.
+func writeTempJSONFile(path string, v any) (string, error) {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return "", err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+
+	return tmp.Name(), nil
+}
@@ -0,0 +1,472 @@
+package data
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TalkColumns maps the fields of a talk to the names of the CSV columns they are imported from.
+// Column names are matched ignoring case and surrounding spaces.
+type TalkColumns struct {
+	ID       string
+	Title    string
+	Speakers string
+	Date     string
+	Time     string
+	Duration string
+	Room     string
+	Track    string
+	EventID  string
+}
+
+// DefaultTalkColumns returns the columns named after the JSON fields of a talk,
+// which are the columns of the CSV the API writes talks as.
+func DefaultTalkColumns() TalkColumns {
+	return TalkColumns{
+		ID:       "id",
+		Title:    "title",
+		Speakers: "speakers",
+		Date:     "date",
+		Time:     "time",
+		Duration: "duration",
+		Room:     "room",
+		Track:    "track",
+		EventID:  "event_id",
+	}
+}
+
+// fields returns the columns by the JSON name of the field they hold.
+func (c *TalkColumns) fields() map[string]*string {
+	return map[string]*string{
+		"id":       &c.ID,
+		"title":    &c.Title,
+		"speakers": &c.Speakers,
+		"date":     &c.Date,
+		"time":     &c.Time,
+		"duration": &c.Duration,
+		"room":     &c.Room,
+		"track":    &c.Track,
+		"event_id": &c.EventID,
+	}
+}
+
+// ParseTalkColumns returns the default columns changed by a comma separated list of field=column pairs,
+// such as "title=Session title,speakers=Speaker names".
+func ParseTalkColumns(spec string) (TalkColumns, error) {
+	columns := DefaultTalkColumns()
+	fields := columns.fields()
+	for _, pair := range strings.Split(spec, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		field, column, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(column) == "" {
+			return TalkColumns{}, newError(ErrInvalidImport, "invalid column mapping %q: expected field=column", pair)
+		}
+		name, ok := fields[strings.ToLower(strings.TrimSpace(field))]
+		if !ok {
+			return TalkColumns{}, newError(ErrInvalidImport, "unknown talk field %q in column mapping", strings.TrimSpace(field))
+		}
+		*name = strings.TrimSpace(column)
+	}
+
+	return columns, nil
+}
+
+// TalkImportOptions configures how ImportTalks reads talks from CSV.
+type TalkImportOptions struct {
+	// Columns maps the CSV columns to the fields of the talks.
+	// The zero value uses DefaultTalkColumns.
+	Columns TalkColumns
+	// SpeakerDelimiter separates the speakers in the speakers column, ";" if empty.
+	SpeakerDelimiter string
+	// Comma is the field delimiter of the CSV, ',' if zero.
+	Comma rune
+	// EventID is the event the talks are imported into.
+	// If empty, the event of each talk is read from its event ID column.
+	EventID string
+	// DryRun checks the talks without saving them.
+	DryRun bool
+}
+
+// ImportIssue is a problem with a row of a CSV import, which is left out of the import.
+// Line is the line of the CSV the row starts on, and Column the name of the offending column if known.
+type ImportIssue struct {
+	Line    int    `json:"line"`
+	Column  string `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+// ImportedTalk is a talk added by a CSV import.
+type ImportedTalk struct {
+	Line    int    `json:"line"`
+	EventID string `json:"event_id"`
+	ID      string `json:"id"`
+	Title   string `json:"title"`
+}
+
+// TalkImportReport lists the talks a CSV import added, or would add in a dry run,
+// and the rows which could not be imported.
+type TalkImportReport struct {
+	DryRun   bool           `json:"dry_run"`
+	Rows     int            `json:"rows"`
+	Imported []ImportedTalk `json:"imported"`
+	Issues   []ImportIssue  `json:"issues"`
+}
+
+func (r *TalkImportReport) add(line int, column, format string, args ...any) {
+	r.Issues = append(r.Issues, ImportIssue{
+		Line:    line,
+		Column:  column,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// importRow is a talk read from a row of CSV.
+type importRow struct {
+	line int
+	talk Talk
+}
+
+// ImportTalks reads talks from CSV with a header row and adds them to their events.
+// Every row is checked as CreateTalk would check it, and rows with problems are reported and skipped
+// while the other rows are imported, so an import can be repeated once the problems are fixed:
+// talks imported before get the same IDs and are reported as existing.
+// It returns an error matching ErrInvalidImport if the CSV cannot be read or lacks the required columns,
+// or ErrEventNotFound if opts.EventID does not exist.
+func (es *EventService) ImportTalks(r io.Reader, opts TalkImportOptions) (*TalkImportReport, error) {
+	rows, report, err := readTalksCSV(r, opts)
+	if err != nil {
+		return nil, err
+	}
+	columns := opts.Columns
+	if columns == (TalkColumns{}) {
+		columns = DefaultTalkColumns()
+	}
+
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	if opts.EventID != "" {
+		if _, err := es.getEvent(opts.EventID); err != nil {
+			return nil, err
+		}
+	}
+	// new speakers are only saved with the talks, so that a dry run saves nothing
+	speakers := newSpeakerRegistry(nil)
+	getSpeaker := func(id string) (Speaker, bool, error) {
+		if s, ok, _ := speakers.get(id); ok {
+			return s, true, nil
+		}
+		return es.repo.GetSpeaker(id)
+	}
+	events := make(map[string]*Event)
+	// changed lists the events with imported talks, in the order of their first talk
+	var changed []string
+	pending := make(map[string]bool)
+	for _, row := range rows {
+		t := row.talk
+		event, ok := events[t.EventID]
+		if !ok {
+			event, err = es.getEvent(t.EventID)
+			if errors.Is(err, ErrEventNotFound) {
+				report.add(row.line, columns.EventID, "no event for id %s", t.EventID)
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			events[t.EventID] = event
+		}
+		if err := validateTalk(*event, t); err != nil {
+			report.add(row.line, issueColumn(err, columns), "%s", err)
+			continue
+		}
+		t = localiseTalk(t, event.location())
+		// unlike CreateTalk, the derived ID is not made unique,
+		// so that rows which were imported before are reported rather than imported twice
+		if t.ID == "" {
+			t.ID = talkIDBase(*event, t)
+		}
+		if findTalk(event.Talks, t.ID) >= 0 {
+			report.add(row.line, columns.ID, "talk with id %s already exists in event %s", t.ID, event.ID)
+			continue
+		}
+		if err := speakers.resolveWith(&t, getSpeaker); err != nil {
+			report.add(row.line, columns.Speakers, "%s", err)
+			continue
+		}
+		if !pending[event.ID] {
+			pending[event.ID] = true
+			changed = append(changed, event.ID)
+		}
+		event.Talks = append(event.Talks, t)
+		report.Imported = append(report.Imported, ImportedTalk{
+			Line:    row.line,
+			EventID: event.ID,
+			ID:      t.ID,
+			Title:   t.Title,
+		})
+	}
+	// rows which could not be parsed were reported first
+	sort.SliceStable(report.Issues, func(i, j int) bool {
+		return report.Issues[i].Line < report.Issues[j].Line
+	})
+	if opts.DryRun || len(changed) == 0 {
+		return report, nil
+	}
+	// a repository failing part way leaves the earlier writes,
+	// so the search index and last modified time are updated whether or not all writes succeed
+	defer es.changed()
+	for _, s := range speakers.list() {
+		if err := es.repo.SaveSpeaker(s); err != nil {
+			return nil, fmt.Errorf("save speaker %s: %w", s.ID, err)
+		}
+	}
+	for _, id := range changed {
+		if err := es.repo.Save(*events[id]); err != nil {
+			return nil, err
+		}
+	}
+
+	return report, nil
+}
+
+// issueColumn returns the column holding the field a validation error is about.
+func issueColumn(err error, columns TalkColumns) string {
+	switch {
+	case errors.Is(err, ErrEmptyTalkTitle):
+		return columns.Title
+	case errors.Is(err, ErrInvalidTalkDuration):
+		return columns.Duration
+	case errors.Is(err, ErrInvalidTalkDate):
+		return columns.Date
+	default:
+		return ""
+	}
+}
+
+// readTalksCSV reads the rows of CSV as talks, reporting the rows whose values cannot be parsed.
+// Dates and times are normalised to the formats of the data files.
+func readTalksCSV(r io.Reader, opts TalkImportOptions) ([]importRow, *TalkImportReport, error) {
+	columns := opts.Columns
+	if columns == (TalkColumns{}) {
+		columns = DefaultTalkColumns()
+	}
+	delimiter := opts.SpeakerDelimiter
+	if delimiter == "" {
+		delimiter = ";"
+	}
+	cr := csv.NewReader(r)
+	if opts.Comma != 0 {
+		cr.Comma = opts.Comma
+	}
+	// short rows are read as having empty values, rather than failing the whole import
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil, newError(ErrInvalidImport, "CSV is empty: expected a header row")
+	}
+	if err != nil {
+		return nil, nil, newError(ErrInvalidImport, "invalid CSV: %w", err)
+	}
+	index, err := columnIndex(header, columns)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	report := &TalkImportReport{
+		DryRun:   opts.DryRun,
+		Imported: []ImportedTalk{},
+		Issues:   []ImportIssue{},
+	}
+	var rows []importRow
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, newError(ErrInvalidImport, "invalid CSV: %w", err)
+		}
+		if blank(record) {
+			continue
+		}
+		line, _ := cr.FieldPos(0)
+		report.Rows++
+		value := func(column string) string {
+			i, ok := index[column]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+		t, column, err := rowTalk(value, columns, delimiter)
+		if err != nil {
+			report.add(line, column, "%s", err)
+			continue
+		}
+		if opts.EventID != "" {
+			if t.EventID != "" && t.EventID != opts.EventID {
+				report.add(line, columns.EventID, "talk is for event %s, not %s", t.EventID, opts.EventID)
+				continue
+			}
+			t.EventID = opts.EventID
+		} else if t.EventID == "" {
+			report.add(line, columns.EventID, "event ID is required")
+			continue
+		}
+		rows = append(rows, importRow{line: line, talk: t})
+	}
+
+	return rows, report, nil
+}
+
+// columnIndex returns the index of each mapped column in the header row.
+// The title and date columns are required, as are columns which were mapped to other names than the defaults;
+// other default columns are only read if present.
+func columnIndex(header []string, columns TalkColumns) (map[string]int, error) {
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		if i == 0 {
+			// spreadsheets often start UTF-8 files with a byte order mark
+			name = strings.TrimPrefix(name, "\ufeff")
+		}
+		index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	defaultColumns := DefaultTalkColumns()
+	defaults := defaultColumns.fields()
+	byColumn := make(map[string]int, len(defaults))
+	for field, column := range columns.fields() {
+		if *column == "" {
+			continue
+		}
+		i, ok := index[strings.ToLower(*column)]
+		if !ok {
+			if field == "title" || field == "date" || *column != *defaults[field] {
+				return nil, newError(ErrInvalidImport, "CSV has no %s column for the talk %s", *column, field)
+			}
+			continue
+		}
+		byColumn[*column] = i
+	}
+
+	return byColumn, nil
+}
+
+// rowTalk returns the talk in a row, whose values are returned by value by column name,
+// or the column of the value which cannot be parsed and the error.
+func rowTalk(value func(column string) string, columns TalkColumns, delimiter string) (Talk, string, error) {
+	aux := talkJSON{
+		talk: talk{
+			ID:      value(columns.ID),
+			Title:   value(columns.Title),
+			Room:    value(columns.Room),
+			Track:   value(columns.Track),
+			EventID: value(columns.EventID),
+		},
+	}
+	for _, name := range strings.Split(value(columns.Speakers), delimiter) {
+		if name = strings.TrimSpace(name); name != "" {
+			aux.Speakers = append(aux.Speakers, name)
+		}
+	}
+	if duration := value(columns.Duration); duration != "" {
+		minutes, err := strconv.Atoi(duration)
+		if err != nil {
+			return Talk{}, columns.Duration, fmt.Errorf("invalid duration %q: expected a number of minutes", duration)
+		}
+		aux.Duration = minutes
+	}
+	date, err := normaliseDate(value(columns.Date))
+	if err != nil {
+		return Talk{}, columns.Date, err
+	}
+	aux.Date = &date
+	clock, err := normaliseTime(value(columns.Time))
+	if err != nil {
+		return Talk{}, columns.Time, err
+	}
+	aux.Time = &clock
+
+	return aux.toTalk(), "", nil
+}
+
+// importDateFormats are the formats of dates accepted in CSV imports besides those of ParseDate,
+// as written by spreadsheets and call for papers tools. Days come before months, as in dateFormat.
+var importDateFormats = []string{
+	"2/1/2006",
+	"2.1.2006",
+	"2-1-2006",
+	"2 January 2006",
+	"2 Jan 2006",
+	"January 2, 2006",
+	"Jan 2, 2006",
+	"Monday, 2 January 2006",
+	"Mon, 2 Jan 2006",
+	"2006/01/02",
+}
+
+// importTimeFormats are the formats of times of day accepted in CSV imports besides timeFormat.
+var importTimeFormats = []string{
+	"15:04:05",
+	"15.04",
+	"3:04 PM",
+	"3:04PM",
+	"3 PM",
+	"3PM",
+}
+
+// normaliseDate returns a date of a CSV import in dateFormat, or unchanged if it has a UTC offset.
+func normaliseDate(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	if parsed, err := ParseDate(value); err == nil {
+		if parsed.Location() == floating {
+			return parsed.Format(dateFormat), nil
+		}
+		return value, nil
+	}
+	for _, layout := range importDateFormats {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return parsed.Format(dateFormat), nil
+		}
+	}
+
+	return "", fmt.Errorf("invalid date %q: expected a day, month and year such as %s or %s", value, dateFormat, isoDateFormat)
+}
+
+// normaliseTime returns a time of day of a CSV import in timeFormat, or unchanged if it is in RFC 3339.
+func normaliseTime(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	if _, err := time.Parse(time.RFC3339, value); err == nil {
+		return value, nil
+	}
+	for _, layout := range append([]string{timeFormat}, importTimeFormats...) {
+		if parsed, err := time.Parse(layout, strings.ToUpper(value)); err == nil {
+			return parsed.Format(timeFormat), nil
+		}
+	}
+
+	return "", fmt.Errorf("invalid time %q: expected a time of day such as 09:30 or 9:30 AM", value)
+}
+
+// blank reports whether every value of the record is empty, as in the trailing rows of spreadsheets.
+func blank(record []string) bool {
+	for _, v := range record {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,202 @@
+package data_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/addetz/testing-strategies-demo/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImportTalks(t *testing.T) {
+	events := []data.Event{
+		{ID: "event-1", DateStart: date("28/06/2023"), DateEnd: date("29/06/2023")},
+		{ID: "event-2", DateStart: date("01/09/2023"), DateEnd: date("01/09/2023")},
+	}
+	talks := []data.Talk{
+		{ID: "talk-1", EventID: "event-1", Title: "Existing talk", Date: date("28/06/2023")},
+	}
+	testCases := map[string]struct {
+		csv             string
+		opts            data.TalkImportOptions
+		expectedTalks   []data.Talk
+		expectedIssues  []data.ImportIssue
+		expectedRows    int
+		expectedErr     error
+		expectedErrMsg  string
+		expectedUnsaved bool
+	}{
+		"default columns": {
+			csv: "title,speakers,date,time,duration,room,track\n" +
+				"Testing in Go,Ada Lovelace; Grace Hopper,2023-06-28,9:30 AM,45,Main,Go\n" +
+				"Fuzzing,,29 June 2023,14.00,,,\n",
+			opts: data.TalkImportOptions{EventID: "event-1"},
+			expectedTalks: []data.Talk{
+				{Title: "Testing in Go", Speakers: []string{"Ada Lovelace", "Grace Hopper"}, Date: date("28/06/2023"),
+					Time: dateTime("28/06/2023 09:30"), Duration: 45, Room: "Main", Track: "Go", EventID: "event-1"},
+				{Title: "Fuzzing", Date: date("29/06/2023"), Time: dateTime("29/06/2023 14:00"), EventID: "event-1"},
+			},
+			expectedIssues: []data.ImportIssue{},
+			expectedRows:   2,
+		},
+		"column mapping and delimiters": {
+			csv: "\ufeffSession;Speaker names;Day;Event\n" +
+				"Contract testing;Ada Lovelace, Grace Hopper;01.09.2023;event-2\n" +
+				";;;\n",
+			opts: data.TalkImportOptions{
+				Columns: data.TalkColumns{
+					Title: "Session", Speakers: "Speaker names", Date: "Day", EventID: "event",
+				},
+				SpeakerDelimiter: ",",
+				Comma:            ';',
+			},
+			expectedTalks: []data.Talk{
+				{Title: "Contract testing", Speakers: []string{"Ada Lovelace", "Grace Hopper"}, Date: date("01/09/2023"), EventID: "event-2"},
+			},
+			expectedIssues: []data.ImportIssue{},
+			expectedRows:   1,
+		},
+		"row issues": {
+			csv: "title,date,time,duration,event_id\n" +
+				"Valid,28/06/2023,,,event-1\n" +
+				"Bad date,2023/13/45,,,event-1\n" +
+				"Bad time,28/06/2023,half past nine,,event-1\n" +
+				"Bad duration,28/06/2023,,an hour,event-1\n" +
+				",28/06/2023,,,event-1\n" +
+				"Outside event,30/06/2023,,,event-1\n" +
+				"Unknown event,28/06/2023,,,event-9\n" +
+				"No event,28/06/2023,,,\n" +
+				"Valid,28/06/2023,,,event-1\n",
+			expectedTalks: []data.Talk{
+				{Title: "Valid", Date: date("28/06/2023"), EventID: "event-1"},
+			},
+			expectedIssues: []data.ImportIssue{
+				{Line: 3, Column: "date", Message: `invalid date "2023/13/45": expected a day, month and year such as 02/01/2006 or 2006-01-02`},
+				{Line: 4, Column: "time", Message: `invalid time "half past nine": expected a time of day such as 09:30 or 9:30 AM`},
+				{Line: 5, Column: "duration", Message: `invalid duration "an hour": expected a number of minutes`},
+				{Line: 6, Column: "title", Message: "talk title cannot be empty"},
+				{Line: 7, Column: "date", Message: "talk date 30/06/2023 is outside of event event-1 dates 28/06/2023-29/06/2023"},
+				{Line: 8, Column: "event_id", Message: "no event for id event-9"},
+				{Line: 9, Column: "event_id", Message: "event ID is required"},
+				{Line: 10, Column: "id", Message: "talk with id c470108a0bba already exists in event event-1"},
+			},
+			expectedRows: 9,
+		},
+		"talk of another event": {
+			csv:          "title,date,event_id\nTalk,01/09/2023,event-2\n",
+			opts:         data.TalkImportOptions{EventID: "event-1"},
+			expectedRows: 1,
+			expectedIssues: []data.ImportIssue{
+				{Line: 2, Column: "event_id", Message: "talk is for event event-2, not event-1"},
+			},
+		},
+		"dry run": {
+			csv:             "title,speakers,date\nTalk,New Speaker,28/06/2023\n",
+			opts:            data.TalkImportOptions{EventID: "event-1", DryRun: true},
+			expectedTalks:   []data.Talk{{Title: "Talk", Speakers: []string{"New Speaker"}, Date: date("28/06/2023"), EventID: "event-1"}},
+			expectedIssues:  []data.ImportIssue{},
+			expectedRows:    1,
+			expectedUnsaved: true,
+		},
+		"missing title column": {
+			csv:            "name,date\nTalk,28/06/2023\n",
+			opts:           data.TalkImportOptions{EventID: "event-1"},
+			expectedErr:    data.ErrInvalidImport,
+			expectedErrMsg: "CSV has no title column for the talk title",
+		},
+		"missing mapped column": {
+			csv:            "title,date\nTalk,28/06/2023\n",
+			opts:           data.TalkImportOptions{EventID: "event-1", Columns: data.TalkColumns{Title: "title", Date: "date", Room: "Hall"}},
+			expectedErr:    data.ErrInvalidImport,
+			expectedErrMsg: "CSV has no Hall column for the talk room",
+		},
+		"empty csv": {
+			opts:           data.TalkImportOptions{EventID: "event-1"},
+			expectedErr:    data.ErrInvalidImport,
+			expectedErrMsg: "CSV is empty: expected a header row",
+		},
+		"unknown event": {
+			csv:         "title,date\nTalk,28/06/2023\n",
+			opts:        data.TalkImportOptions{EventID: "event-9"},
+			expectedErr: data.ErrEventNotFound,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			es, err := data.NewEventService(events, talks)
+			require.Nil(t, err)
+			report, err := es.ImportTalks(strings.NewReader(tc.csv), tc.opts)
+			if tc.expectedErr != nil {
+				assert.Nil(t, report)
+				assert.ErrorIs(t, err, tc.expectedErr)
+				if tc.expectedErrMsg != "" {
+					assert.EqualError(t, err, tc.expectedErrMsg)
+				}
+				return
+			}
+			require.Nil(t, err)
+			assert.Equal(t, tc.expectedRows, report.Rows)
+			assert.Equal(t, tc.expectedIssues, report.Issues)
+			require.Len(t, report.Imported, len(tc.expectedTalks))
+			for i, expected := range tc.expectedTalks {
+				imported := report.Imported[i]
+				assert.Equal(t, expected.Title, imported.Title)
+				talk, err := es.GetTalk(imported.EventID, imported.ID)
+				if tc.expectedUnsaved {
+					assert.ErrorIs(t, err, data.ErrTalkNotFound)
+					continue
+				}
+				require.Nil(t, err)
+				expected.ID = imported.ID
+				expected.SpeakerIDs = talk.SpeakerIDs
+				assert.Equal(t, expected, *talk)
+			}
+			if tc.expectedUnsaved {
+				speakers, err := es.GetSpeakers()
+				require.Nil(t, err)
+				assert.Empty(t, speakers.Speakers)
+			}
+		})
+	}
+}
+
+func TestParseTalkColumns(t *testing.T) {
+	testCases := map[string]struct {
+		spec            string
+		expectedColumns data.TalkColumns
+		expectedErrMsg  string
+	}{
+		"defaults": {
+			expectedColumns: data.DefaultTalkColumns(),
+		},
+		"mapped columns": {
+			spec: "title=Session title, Speakers = Speaker names",
+			expectedColumns: func() data.TalkColumns {
+				c := data.DefaultTalkColumns()
+				c.Title, c.Speakers = "Session title", "Speaker names"
+				return c
+			}(),
+		},
+		"unknown field": {
+			spec:           "abstract=Abstract",
+			expectedErrMsg: `unknown talk field "abstract" in column mapping`,
+		},
+		"missing column": {
+			spec:           "title",
+			expectedErrMsg: `invalid column mapping "title": expected field=column`,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			columns, err := data.ParseTalkColumns(tc.spec)
+			if tc.expectedErrMsg != "" {
+				assert.ErrorIs(t, err, data.ErrInvalidImport)
+				assert.EqualError(t, err, tc.expectedErrMsg)
+				return
+			}
+			require.Nil(t, err)
+			assert.Equal(t, tc.expectedColumns, columns)
+		})
+	}
+}
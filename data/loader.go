@@ -0,0 +1,149 @@
+package data
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ParseError reports malformed JSON in a data file,
+// with the line and column at which decoding failed.
+type ParseError struct {
+	File   string
+	Line   int
+	Column int
+	Err    error
+}
+
+func (e *ParseError) Error() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("%s: %v", e.File, e.Err)
+	}
+	return fmt.Sprintf("%s:%d:%d: %v", e.File, e.Line, e.Column, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// ParseEvents decodes the events in b, which has the format of events.json.
+// The name is used to identify the data in errors.
+func ParseEvents(name string, b []byte) ([]Event, error) {
+	// the events are decoded with their dates as strings,
+	// so that decoding errors report their position in the whole file
+	var file struct {
+		Events []eventJSON `json:"events"`
+	}
+	if err := decodeJSON(name, b, &file); err != nil {
+		return nil, err
+	}
+	events := make([]Event, len(file.Events))
+	for i, e := range file.Events {
+		events[i] = e.toEvent()
+	}
+
+	return events, nil
+}
+
+// ParseTalks decodes the talks in b, which has the format of talks.json.
+// The name is used to identify the data in errors.
+func ParseTalks(name string, b []byte) ([]Talk, error) {
+	var file struct {
+		Talks []talkJSON `json:"talks"`
+	}
+	if err := decodeJSON(name, b, &file); err != nil {
+		return nil, err
+	}
+	talks := make([]Talk, len(file.Talks))
+	for i, t := range file.Talks {
+		talks[i] = t.toTalk()
+	}
+
+	return talks, nil
+}
+
+// ParseSpeakers decodes the speakers in b, which has the format of speakers.json.
+// The name is used to identify the data in errors.
+func ParseSpeakers(name string, b []byte) ([]Speaker, error) {
+	var speakers Speakers
+	if err := decodeJSON(name, b, &speakers); err != nil {
+		return nil, err
+	}
+	if speakers.Speakers == nil {
+		return []Speaker{}, nil
+	}
+
+	return speakers.Speakers, nil
+}
+
+// LoadEvents reads and decodes the events file at path.
+func LoadEvents(path string) ([]Event, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseEvents(path, b)
+}
+
+// LoadTalks reads and decodes the talks file at path.
+func LoadTalks(path string) ([]Talk, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseTalks(path, b)
+}
+
+// LoadSpeakers reads and decodes the speakers file at path.
+func LoadSpeakers(path string) ([]Speaker, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseSpeakers(path, b)
+}
+
+// decodeJSON unmarshals b into v, returning a *ParseError
+// with the position of the failure if b is not valid for v.
+func decodeJSON(name string, b []byte, v any) error {
+	err := json.Unmarshal(b, v)
+	if err == nil {
+		return nil
+	}
+	var offset int64
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		offset = syntaxErr.Offset
+	case errors.As(err, &typeErr):
+		offset = typeErr.Offset
+	}
+	parseErr := &ParseError{
+		File: name,
+		Err:  err,
+	}
+	if offset > 0 {
+		// the decoder reports the offset after the byte at which it failed
+		parseErr.Line, parseErr.Column = position(b, offset-1)
+	}
+
+	return parseErr
+}
+
+// position returns the 1-based line and column of the given byte offset in b.
+func position(b []byte, offset int64) (int, int) {
+	if offset > int64(len(b)) {
+		offset = int64(len(b))
+	}
+	before := b[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := len(before) - bytes.LastIndexByte(before, '\n')
+
+	return line, column
+}
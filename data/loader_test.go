@@ -0,0 +1,87 @@
+package data_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/addetz/testing-strategies-demo/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEvents(t *testing.T) {
+	testCases := map[string]struct {
+		input          string
+		expectedEvents []data.Event
+		expectedErr    string
+	}{
+		"valid events": {
+			input: `{"events": [{"id": "event-1", "name": "Event 1"}]}`,
+			expectedEvents: []data.Event{
+				{
+					ID:   "event-1",
+					Name: "Event 1",
+				},
+			},
+		},
+		"no events": {
+			input:          `{}`,
+			expectedEvents: []data.Event{},
+		},
+		"syntax error": {
+			input:       "{\n  \"events\": [\n    {\"id\": \"event-1\",}\n  ]\n}",
+			expectedErr: "events.json:3:22: invalid character '}' looking for beginning of object key string",
+		},
+		"type error": {
+			input:       "{\n  \"events\": [\n    {\"id\": 1}\n  ]\n}",
+			expectedErr: "events.json:3:12: json: cannot unmarshal number into Go struct field",
+		},
+		"unexpected end": {
+			input:       `{"events": [`,
+			expectedErr: "events.json:1:12: unexpected end of JSON input",
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			events, err := data.ParseEvents("events.json", []byte(tc.input))
+			if tc.expectedErr != "" {
+				assert.Nil(t, events)
+				var parseErr *data.ParseError
+				require.True(t, errors.As(err, &parseErr))
+				assert.Equal(t, "events.json", parseErr.File)
+				assert.Contains(t, err.Error(), tc.expectedErr)
+				return
+			}
+			require.Nil(t, err)
+			assert.Equal(t, tc.expectedEvents, events)
+		})
+	}
+}
+
+func TestLoadTalks(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("valid file", func(t *testing.T) {
+		path := filepath.Join(dir, "talks.json")
+		err := os.WriteFile(path, []byte(`{"talks": [{"event_id": "event-1", "title": "event 1 talk 1"}]}`), 0o644)
+		require.Nil(t, err)
+		talks, err := data.LoadTalks(path)
+		require.Nil(t, err)
+		assert.Equal(t, []data.Talk{{EventID: "event-1", Title: "event 1 talk 1"}}, talks)
+	})
+	t.Run("invalid file", func(t *testing.T) {
+		path := filepath.Join(dir, "invalid.json")
+		err := os.WriteFile(path, []byte("{\n\"talks\": {}\n}"), 0o644)
+		require.Nil(t, err)
+		talks, err := data.LoadTalks(path)
+		assert.Nil(t, talks)
+		assert.Contains(t, err.Error(), path+":2:")
+	})
+	t.Run("missing file", func(t *testing.T) {
+		talks, err := data.LoadTalks(filepath.Join(dir, "missing.json"))
+		assert.Nil(t, talks)
+		assert.True(t, errors.Is(err, os.ErrNotExist))
+	})
+}
@@ -0,0 +1,288 @@
+package data
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Orders in which QueryEvents can sort events.
+// Events which sort equally are ordered by ID, so the order is always the same.
+const (
+	SortDateStart     = "date_start"
+	SortDateStartDesc = "-date_start"
+	SortName          = "name"
+)
+
+// EventQuery selects a page of events.
+// The zero value selects all events, sorted by start date.
+type EventQuery struct {
+	// Limit is the maximum number of events returned, or 0 for no limit.
+	Limit int
+	// Cursor continues from the NextCursor of a previous query with the same sort.
+	Cursor string
+	// Sort is one of the Sort constants, SortDateStart if empty.
+	Sort string
+	// Location, if set, only matches events at that location, ignoring case.
+	Location string
+	// From and To, if set, only match events which overlap them,
+	// ending on or after From and starting on or before To.
+	From time.Time
+	To   time.Time
+}
+
+// cursor is the position of the last event of a page, for the next page to continue after.
+// It holds the sort key rather than an offset, so pages do not skip or repeat events
+// when events before the cursor are created or deleted.
+type cursor struct {
+	Sort string `json:"s"`
+	ID   string `json:"id"`
+	Key  string `json:"k"`
+}
+
+// QueryEvents returns the events matching q in a deterministic order.
+// If more events match than q.Limit, the returned Events has a NextCursor
+// which continues the query on the next page.
+func (es *EventService) QueryEvents(q EventQuery) (Events, error) {
+	if q.Sort == "" {
+		q.Sort = SortDateStart
+	}
+	less, ok := eventOrders[q.Sort]
+	if !ok {
+		return Events{}, newError(ErrInvalidSort, "cannot sort events by %q", q.Sort)
+	}
+	var after *Event
+	if q.Cursor != "" {
+		c, err := decodeCursor(q.Cursor)
+		if err != nil || c.Sort != q.Sort {
+			return Events{}, newError(ErrInvalidCursor, "invalid cursor %q", q.Cursor)
+		}
+		after = c.event()
+	}
+
+	es.mu.RLock()
+	events, err := es.repo.List()
+	es.mu.RUnlock()
+	if err != nil {
+		return Events{}, err
+	}
+
+	matched := make([]Event, 0, len(events))
+	for _, e := range events {
+		if q.matches(e) && (after == nil || less(*after, e)) {
+			matched = append(matched, e)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		return less(matched[i], matched[j])
+	})
+
+	resp := Events{Events: matched}
+	if q.Limit > 0 && len(matched) > q.Limit {
+		resp.Events = matched[:q.Limit]
+		resp.NextCursor = encodeCursor(q.Sort, resp.Events[q.Limit-1])
+	}
+
+	return resp, nil
+}
+
+// matches reports whether e passes the filters of q.
+func (q EventQuery) matches(e Event) bool {
+	if q.Location != "" && !strings.EqualFold(q.Location, e.Location) {
+		return false
+	}
+	// the dates are compared by day, since the events are in their own time zones
+	if !q.From.IsZero() && (e.DateEnd.IsZero() || civilDate(e.DateEnd).Before(civilDate(q.From))) {
+		return false
+	}
+	if !q.To.IsZero() && (e.DateStart.IsZero() || civilDate(e.DateStart).After(civilDate(q.To))) {
+		return false
+	}
+
+	return true
+}
+
+// eventOrders holds the less function of each sort order.
+var eventOrders = map[string]func(a, b Event) bool{
+	SortDateStart: func(a, b Event) bool {
+		return compareDates(a.DateStart, b.DateStart, a.ID, b.ID) < 0
+	},
+	SortDateStartDesc: func(a, b Event) bool {
+		return compareDates(b.DateStart, a.DateStart, a.ID, b.ID) < 0
+	},
+	SortName: func(a, b Event) bool {
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.ID < b.ID
+	},
+}
+
+// compareDates compares two dates, falling back to comparing the IDs if the dates are equal.
+// Missing dates sort before all others.
+func compareDates(dateA, dateB time.Time, idA, idB string) int {
+	switch {
+	case dateA.Before(dateB):
+		return -1
+	case dateA.After(dateB):
+		return 1
+	}
+
+	return strings.Compare(idA, idB)
+}
+
+// encodeCursor returns the opaque cursor positioned after e.
+func encodeCursor(sort string, e Event) string {
+	c := cursor{Sort: sort, ID: e.ID, Key: e.DateStart.Format(time.RFC3339)}
+	if sort == SortName {
+		c.Key = e.Name
+	}
+	b, _ := json.Marshal(c)
+
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string) (cursor, error) {
+	var c cursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, err
+	}
+	err = json.Unmarshal(b, &c)
+
+	return c, err
+}
+
+// event returns an event which sorts in the position of the cursor.
+func (c cursor) event() *Event {
+	if c.Sort == SortName {
+		return &Event{ID: c.ID, Name: c.Key}
+	}
+
+	// a key which does not parse positions the cursor before all dates
+	start, _ := time.Parse(time.RFC3339, c.Key)
+
+	return &Event{ID: c.ID, DateStart: start}
+}
+
+// TalkQuery selects the talks of an event. Talks must match all the fields which are set,
+// and the zero value selects all talks.
+type TalkQuery struct {
+	// Day only matches talks on that day of the event, counting its start date as day 1.
+	Day int
+	// Date only matches talks on that date.
+	Date time.Time
+	// FromTime and ToTime only match talks starting within them, inclusive.
+	// They are times of day in the format HH:MM.
+	FromTime string
+	ToTime   string
+	// Speaker only matches talks with a speaker whose name contains it, ignoring case.
+	Speaker string
+	// Title only matches talks whose title contains all of its words, ignoring case.
+	Title string
+}
+
+// QueryTalks returns the talks of the event corresponding to the given id which match q,
+// in the order they are stored, or an error if no event is found or q is invalid.
+func (es *EventService) QueryTalks(id string, q TalkQuery) (*Talks, error) {
+	if q.Day < 0 {
+		return nil, newError(ErrDayOutOfRange, "day must be > 1, but was %d", q.Day)
+	}
+	from, err := parseTimeOfDay("from_time", q.FromTime)
+	if err != nil {
+		return nil, err
+	}
+	to, err := parseTimeOfDay("to_time", q.ToTime)
+	if err != nil {
+		return nil, err
+	}
+	event, err := es.GetEvent(id)
+	if err != nil {
+		return nil, err
+	}
+	var dates []time.Time
+	if q.Day > 0 {
+		date, err := eventDay(event, q.Day)
+		if err != nil {
+			return nil, err
+		}
+		dates = append(dates, date)
+	}
+	if !q.Date.IsZero() {
+		dates = append(dates, q.Date)
+	}
+	speaker := strings.ToLower(q.Speaker)
+	terms := strings.Fields(strings.ToLower(q.Title))
+
+	filteredTalks := &Talks{}
+	for _, t := range event.Talks {
+		if !matchesDates(t, dates) || !matchesTime(t, from, to) ||
+			!matchesSpeaker(t, speaker) || !matchesTitle(t, terms) {
+			continue
+		}
+		filteredTalks.Talks = append(filteredTalks.Talks, t)
+	}
+
+	return filteredTalks, nil
+}
+
+// parseTimeOfDay parses the time of day in the named field of a TalkQuery, returning nil if it is empty.
+func parseTimeOfDay(field, value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	parsed, err := time.Parse(timeFormat, value)
+	if err != nil {
+		return nil, newError(ErrInvalidTalkQuery, "invalid %s %q: expected format %s", field, value, timeFormat)
+	}
+
+	return &parsed, nil
+}
+
+// matchesDates reports whether t is on all the given dates, comparing each in its own location.
+func matchesDates(t Talk, dates []time.Time) bool {
+	for _, d := range dates {
+		if t.Date.IsZero() || !sameDay(t.Date, d) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesTime reports whether t starts within from and to, as times of day in the time zone of its event.
+// Talks without a time only match if neither is set.
+func matchesTime(t Talk, from, to *time.Time) bool {
+	if from == nil && to == nil {
+		return true
+	}
+	if t.Time.IsZero() {
+		return false
+	}
+	// on the same day as the parsed times of day, so that they can be compared
+	start := time.Date(0, time.January, 1, t.Time.Hour(), t.Time.Minute(), 0, 0, time.UTC)
+	return (from == nil || !start.Before(*from)) && (to == nil || !start.After(*to))
+}
+
+func matchesSpeaker(t Talk, speaker string) bool {
+	if speaker == "" {
+		return true
+	}
+	for _, s := range t.Speakers {
+		if strings.Contains(strings.ToLower(s), speaker) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesTitle(t Talk, terms []string) bool {
+	title := strings.ToLower(t.Title)
+	for _, term := range terms {
+		if !strings.Contains(title, term) {
+			return false
+		}
+	}
+	return true
+}
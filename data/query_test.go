@@ -0,0 +1,194 @@
+package data_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/addetz/testing-strategies-demo/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryEvents(t *testing.T) {
+	events := []data.Event{
+		{ID: "event-c", Name: "Alpha", DateStart: date("10/03/2023"), DateEnd: date("11/03/2023"), Location: "Amsterdam"},
+		{ID: "event-a", Name: "Charlie", DateStart: date("01/02/2023"), DateEnd: date("02/02/2023"), Location: "Barcelona"},
+		{ID: "event-b", Name: "Bravo", DateStart: date("01/02/2023"), DateEnd: date("03/02/2023"), Location: "amsterdam"},
+		{ID: "event-d", Name: "Delta", DateStart: date("20/06/2023"), DateEnd: date("21/06/2023"), Location: "Berlin"},
+	}
+	es, err := data.NewEventService(events, []data.Talk{})
+	require.Nil(t, err)
+
+	testCases := map[string]struct {
+		query       data.EventQuery
+		expectedIDs []string
+		expectedErr error
+	}{
+		"default sort": {
+			expectedIDs: []string{"event-a", "event-b", "event-c", "event-d"},
+		},
+		"sort by start date descending": {
+			query:       data.EventQuery{Sort: data.SortDateStartDesc},
+			expectedIDs: []string{"event-d", "event-c", "event-a", "event-b"},
+		},
+		"sort by name": {
+			query:       data.EventQuery{Sort: data.SortName},
+			expectedIDs: []string{"event-c", "event-b", "event-a", "event-d"},
+		},
+		"location ignores case": {
+			query:       data.EventQuery{Location: "AMSTERDAM"},
+			expectedIDs: []string{"event-b", "event-c"},
+		},
+		"from": {
+			query:       data.EventQuery{From: time.Date(2023, time.February, 3, 0, 0, 0, 0, time.UTC)},
+			expectedIDs: []string{"event-b", "event-c", "event-d"},
+		},
+		"to": {
+			query:       data.EventQuery{To: time.Date(2023, time.March, 10, 0, 0, 0, 0, time.UTC)},
+			expectedIDs: []string{"event-a", "event-b", "event-c"},
+		},
+		"invalid sort": {
+			query:       data.EventQuery{Sort: "location"},
+			expectedErr: data.ErrInvalidSort,
+		},
+		"invalid cursor": {
+			query:       data.EventQuery{Cursor: "not-a-cursor"},
+			expectedErr: data.ErrInvalidCursor,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			fetched, err := es.QueryEvents(tc.query)
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+				return
+			}
+			require.Nil(t, err)
+			assert.Equal(t, tc.expectedIDs, eventIDs(fetched.Events))
+			assert.Empty(t, fetched.NextCursor)
+		})
+	}
+}
+
+func TestQueryEventsPages(t *testing.T) {
+	events := []data.Event{
+		{ID: "event-1", Name: "Event 1", DateStart: date("01/02/2023")},
+		{ID: "event-2", Name: "Event 2", DateStart: date("02/02/2023")},
+		{ID: "event-3", Name: "Event 3", DateStart: date("03/02/2023")},
+	}
+	es, err := data.NewEventService(events, []data.Talk{})
+	require.Nil(t, err)
+
+	first, err := es.QueryEvents(data.EventQuery{Limit: 2})
+	require.Nil(t, err)
+	assert.Equal(t, []string{"event-1", "event-2"}, eventIDs(first.Events))
+	require.NotEmpty(t, first.NextCursor)
+
+	// events created before the cursor do not shift the next page
+	_, err = es.CreateEvent(data.Event{ID: "event-0", DateStart: date("01/01/2023"), DateEnd: date("01/01/2023")})
+	require.Nil(t, err)
+
+	second, err := es.QueryEvents(data.EventQuery{Limit: 2, Cursor: first.NextCursor})
+	require.Nil(t, err)
+	assert.Equal(t, []string{"event-3"}, eventIDs(second.Events))
+	assert.Empty(t, second.NextCursor)
+
+	t.Run("cursor of another sort", func(t *testing.T) {
+		_, err := es.QueryEvents(data.EventQuery{Sort: data.SortName, Cursor: first.NextCursor})
+		assert.ErrorIs(t, err, data.ErrInvalidCursor)
+	})
+}
+
+func TestQueryTalks(t *testing.T) {
+	eventID := "event-1"
+	events := []data.Event{
+		{ID: eventID, DateStart: date("01/02/2023"), DateEnd: date("02/02/2023")},
+	}
+	talks := []data.Talk{
+		{ID: "talk-1", EventID: eventID, Title: "Testing Go services", Speakers: []string{"Adelina Simion"}, Date: date("01/02/2023"), Time: dateTime("01/02/2023 09:30")},
+		{ID: "talk-2", EventID: eventID, Title: "Go generics in practice", Speakers: []string{"Ada Lovelace", "Grace Hopper"}, Date: date("01/02/2023"), Time: dateTime("01/02/2023 14:00")},
+		{ID: "talk-3", EventID: eventID, Title: "Testing in production", Speakers: []string{"Grace Hopper"}, Date: date("02/02/2023"), Time: dateTime("02/02/2023 13:30")},
+		{ID: "talk-4", EventID: eventID, Title: "Closing keynote", Speakers: []string{"Alan Turing"}, Date: date("02/02/2023"), Time: dateTime("02/02/2023 17:00")},
+	}
+	es, err := data.NewEventService(events, talks)
+	require.Nil(t, err)
+
+	testCases := map[string]struct {
+		eventID     string
+		query       data.TalkQuery
+		expectedIDs []string
+		expectedErr error
+	}{
+		"no filters": {
+			eventID:     eventID,
+			expectedIDs: []string{"talk-1", "talk-2", "talk-3", "talk-4"},
+		},
+		"day": {
+			eventID:     eventID,
+			query:       data.TalkQuery{Day: 2},
+			expectedIDs: []string{"talk-3", "talk-4"},
+		},
+		"date": {
+			eventID:     eventID,
+			query:       data.TalkQuery{Date: time.Date(2023, time.February, 1, 0, 0, 0, 0, time.UTC)},
+			expectedIDs: []string{"talk-1", "talk-2"},
+		},
+		"time window": {
+			eventID:     eventID,
+			query:       data.TalkQuery{FromTime: "13:30", ToTime: "14:00"},
+			expectedIDs: []string{"talk-2", "talk-3"},
+		},
+		"speaker ignores case": {
+			eventID:     eventID,
+			query:       data.TalkQuery{Speaker: "HOPPER"},
+			expectedIDs: []string{"talk-2", "talk-3"},
+		},
+		"title words": {
+			eventID:     eventID,
+			query:       data.TalkQuery{Title: "production testing"},
+			expectedIDs: []string{"talk-3"},
+		},
+		"after lunch on day 2 by speaker": {
+			eventID:     eventID,
+			query:       data.TalkQuery{Day: 2, FromTime: "13:00", Speaker: "grace"},
+			expectedIDs: []string{"talk-3"},
+		},
+		"day out of range": {
+			eventID:     eventID,
+			query:       data.TalkQuery{Day: 3},
+			expectedErr: data.ErrDayOutOfRange,
+		},
+		"invalid time": {
+			eventID:     eventID,
+			query:       data.TalkQuery{FromTime: "1pm"},
+			expectedErr: data.ErrInvalidTalkQuery,
+		},
+		"invalid event": {
+			eventID:     "invalid-event",
+			expectedErr: data.ErrEventNotFound,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			fetched, err := es.QueryTalks(tc.eventID, tc.query)
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+				return
+			}
+			require.Nil(t, err)
+			ids := make([]string, 0, len(fetched.Talks))
+			for _, talk := range fetched.Talks {
+				ids = append(ids, talk.ID)
+			}
+			assert.Equal(t, tc.expectedIDs, ids)
+		})
+	}
+}
+
+func eventIDs(events []data.Event) []string {
+	ids := make([]string, 0, len(events))
+	for _, e := range events {
+		ids = append(ids, e.ID)
+	}
+	return ids
+}
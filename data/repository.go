@@ -0,0 +1,196 @@
+package data
+
+import (
+	"log"
+	"sync"
+)
+
+// EventRepository stores events together with their talks, and the speakers of the talks.
+// Implementations must be safe for concurrent reads;
+// EventService serialises all writes.
+type EventRepository interface {
+	// List returns all the stored events.
+	List() ([]Event, error)
+	// Get returns the event corresponding to the given id,
+	// and false if there is none.
+	Get(id string) (Event, bool, error)
+	// Save creates the given event or replaces the stored event with the same ID.
+	Save(e Event) error
+	// Delete removes the event corresponding to the given id, if any.
+	Delete(id string) error
+	// ListSpeakers returns all the stored speakers.
+	ListSpeakers() ([]Speaker, error)
+	// GetSpeaker returns the speaker corresponding to the given id,
+	// and false if there is none.
+	GetSpeaker(id string) (Speaker, bool, error)
+	// SaveSpeaker creates the given speaker or replaces the stored speaker with the same ID.
+	SaveSpeaker(s Speaker) error
+	// DeleteSpeaker removes the speaker corresponding to the given id, if any.
+	DeleteSpeaker(id string) error
+}
+
+// MemoryRepository is an EventRepository which keeps all events in memory.
+type MemoryRepository struct {
+	mu sync.RWMutex
+	// uuid is key to events map
+	events   map[string]Event
+	speakers map[string]Speaker
+}
+
+// NewMemoryRepository returns an empty MemoryRepository.
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{
+		events:   make(map[string]Event),
+		speakers: make(map[string]Speaker),
+	}
+}
+
+func (mr *MemoryRepository) List() ([]Event, error) {
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+	events := make([]Event, 0, len(mr.events))
+	for _, e := range mr.events {
+		events = append(events, e)
+	}
+
+	return events, nil
+}
+
+func (mr *MemoryRepository) Get(id string) (Event, bool, error) {
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+	e, ok := mr.events[id]
+
+	return e, ok, nil
+}
+
+func (mr *MemoryRepository) Save(e Event) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	mr.events[e.ID] = e
+
+	return nil
+}
+
+func (mr *MemoryRepository) Delete(id string) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	delete(mr.events, id)
+
+	return nil
+}
+
+func (mr *MemoryRepository) ListSpeakers() ([]Speaker, error) {
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+	speakers := make([]Speaker, 0, len(mr.speakers))
+	for _, s := range mr.speakers {
+		speakers = append(speakers, s)
+	}
+
+	return speakers, nil
+}
+
+func (mr *MemoryRepository) GetSpeaker(id string) (Speaker, bool, error) {
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+	s, ok := mr.speakers[id]
+
+	return s, ok, nil
+}
+
+func (mr *MemoryRepository) SaveSpeaker(s Speaker) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	mr.speakers[s.ID] = s
+
+	return nil
+}
+
+func (mr *MemoryRepository) DeleteSpeaker(id string) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	delete(mr.speakers, id)
+
+	return nil
+}
+
+// Seed saves the given events, talks and speakers to the repository if it does not hold any events yet.
+// It reports whether the repository was seeded.
+// Talks which only list the names of their speakers reference the speakers with the same ID,
+// which are created from the names if they are not among the given speakers.
+// Likely duplicate speakers are logged.
+func Seed(repo EventRepository, ev []Event, talks []Talk, speakers []Speaker) (bool, error) {
+	existing, err := repo.List()
+	if err != nil {
+		return false, err
+	}
+	if len(existing) > 0 {
+		return false, nil
+	}
+	reg := newSpeakerRegistry(speakers)
+	grouped := groupTalks(ev, talks)
+	for i, e := range grouped {
+		var resolved []Talk
+		for _, t := range e.Talks {
+			if err := reg.resolve(&t); err != nil {
+				log.Printf("%v; dropping invalid talk\n", err)
+				continue
+			}
+			resolved = append(resolved, t)
+		}
+		grouped[i].Talks = resolved
+	}
+	for _, s := range reg.list() {
+		if err := repo.SaveSpeaker(s); err != nil {
+			return false, err
+		}
+	}
+	for _, e := range grouped {
+		if err := repo.Save(e); err != nil {
+			return false, err
+		}
+	}
+	logDuplicateSpeakers(reg.list())
+
+	return true, nil
+}
+
+// groupTalks attaches each talk to its event, generating talk IDs where missing,
+// and moves the dates and times of the events and talks to the events' time zones.
+// Talks which do not belong to any event or fall outside their event's dates are dropped.
+func groupTalks(ev []Event, talks []Talk) []Event {
+	events := make(map[string]Event, len(ev))
+	order := make([]string, 0, len(ev))
+	for _, e := range ev {
+		if _, ok := events[e.ID]; !ok {
+			order = append(order, e.ID)
+		}
+		e.Talks = nil
+		events[e.ID] = localise(e)
+	}
+	for _, t := range talks {
+		event, ok := events[t.EventID]
+		if !ok {
+			log.Printf("key %s not found; dropping invalid talk\n", t.EventID)
+			continue
+		}
+		t = localiseTalk(t, event.location())
+		if err := checkTalkDate(event, t); err != nil {
+			log.Printf("%v; dropping invalid talk\n", err)
+			continue
+		}
+		if t.ID == "" {
+			t.ID = newTalkID(event, t)
+		}
+		event.Talks = append(event.Talks, t)
+		events[t.EventID] = event
+	}
+
+	grouped := make([]Event, 0, len(order))
+	for _, id := range order {
+		grouped = append(grouped, events[id])
+	}
+
+	return grouped
+}
@@ -0,0 +1,401 @@
+package data_test
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/addetz/testing-strategies-demo/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeed(t *testing.T) {
+	events := []data.Event{
+		{
+			ID:        "event-1",
+			DateStart: date("01/01/2010"),
+			DateEnd:   date("02/01/2010"),
+		},
+	}
+	talks := []data.Talk{
+		{
+			ID:      "talk-1-1",
+			EventID: "event-1",
+			Title:   "event 1 talk 1",
+			Date:    date("01/01/2010"),
+		},
+		{
+			EventID: "event-99",
+			Title:   "invalid talk",
+		},
+	}
+	repo := data.NewMemoryRepository()
+
+	t.Run("empty repository", func(t *testing.T) {
+		seeded, err := data.Seed(repo, events, talks, nil)
+		require.Nil(t, err)
+		assert.True(t, seeded)
+		ev, ok, err := repo.Get("event-1")
+		require.Nil(t, err)
+		require.True(t, ok)
+		assert.Equal(t, talks[0:1], ev.Talks)
+	})
+	t.Run("non empty repository", func(t *testing.T) {
+		seeded, err := data.Seed(repo, []data.Event{{ID: "event-2"}}, []data.Talk{}, nil)
+		require.Nil(t, err)
+		assert.False(t, seeded)
+		_, ok, err := repo.Get("event-2")
+		require.Nil(t, err)
+		assert.False(t, ok)
+	})
+}
+
+func TestFileRepository(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "store")
+	event := data.Event{
+		ID:        "event-1",
+		Name:      "Event 1",
+		DateStart: date("01/01/2010"),
+		DateEnd:   date("02/01/2010"),
+		Talks: []data.Talk{
+			{
+				ID:      "talk-1-1",
+				EventID: "event-1",
+				Title:   "event 1 talk 1",
+				Date:    date("01/01/2010"),
+			},
+		},
+	}
+
+	repo, err := data.NewFileRepository(dir)
+	require.Nil(t, err)
+	events, err := repo.List()
+	require.Nil(t, err)
+	assert.Len(t, events, 0)
+
+	t.Run("save persists across instances", func(t *testing.T) {
+		require.Nil(t, repo.Save(event))
+		require.Nil(t, repo.Save(data.Event{ID: "event-2"}))
+
+		reopened, err := data.NewFileRepository(dir)
+		require.Nil(t, err)
+		fetched, ok, err := reopened.Get("event-1")
+		require.Nil(t, err)
+		require.True(t, ok)
+		assert.Equal(t, event, fetched)
+		events, err := reopened.List()
+		require.Nil(t, err)
+		assert.Len(t, events, 2)
+	})
+	t.Run("delete persists across instances", func(t *testing.T) {
+		require.Nil(t, repo.Delete("event-1"))
+
+		reopened, err := data.NewFileRepository(dir)
+		require.Nil(t, err)
+		_, ok, err := reopened.Get("event-1")
+		require.Nil(t, err)
+		assert.False(t, ok)
+	})
+	t.Run("invalid file", func(t *testing.T) {
+		invalidDir := t.TempDir()
+		err := os.WriteFile(filepath.Join(invalidDir, "events.json"), []byte(`{"events":`), 0o644)
+		require.Nil(t, err)
+		reopened, err := data.NewFileRepository(invalidDir)
+		assert.Nil(t, reopened)
+		assert.NotNil(t, err)
+	})
+}
+
+func TestFileRepositoryFailedWrite(t *testing.T) {
+	event := data.Event{ID: "event-1", Name: "Event 1"}
+	speaker := data.Speaker{ID: "speaker-1", Name: "Speaker 1"}
+	tests := map[string]struct {
+		write func(repo *data.FileRepository) error
+	}{
+		"save new event": {
+			write: func(repo *data.FileRepository) error {
+				return repo.Save(data.Event{ID: "event-2"})
+			},
+		},
+		"save existing event": {
+			write: func(repo *data.FileRepository) error {
+				return repo.Save(data.Event{ID: "event-1", Name: "Renamed"})
+			},
+		},
+		"delete event": {
+			write: func(repo *data.FileRepository) error {
+				return repo.Delete("event-1")
+			},
+		},
+		"save new speaker": {
+			write: func(repo *data.FileRepository) error {
+				return repo.SaveSpeaker(data.Speaker{ID: "speaker-2"})
+			},
+		},
+		"save existing speaker": {
+			write: func(repo *data.FileRepository) error {
+				return repo.SaveSpeaker(data.Speaker{ID: "speaker-1", Name: "Renamed"})
+			},
+		},
+		"delete speaker": {
+			write: func(repo *data.FileRepository) error {
+				return repo.DeleteSpeaker("speaker-1")
+			},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "store")
+			repo, err := data.NewFileRepository(dir)
+			require.Nil(t, err)
+			require.Nil(t, repo.Save(event))
+			require.Nil(t, repo.SaveSpeaker(speaker))
+			// removing the directory makes every write fail, even when running as root
+			require.Nil(t, os.RemoveAll(dir))
+
+			assert.NotNil(t, tc.write(repo))
+
+			events, err := repo.List()
+			require.Nil(t, err)
+			assert.Equal(t, []data.Event{event}, events)
+			speakers, err := repo.ListSpeakers()
+			require.Nil(t, err)
+			assert.Equal(t, []data.Speaker{speaker}, speakers)
+		})
+	}
+}
+
+func TestFileRepositoryLeavesNoTemporaryFiles(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := data.NewFileRepository(dir)
+	require.Nil(t, err)
+
+	require.Nil(t, repo.Save(data.Event{ID: "event-1"}))
+	require.Nil(t, repo.SaveSpeaker(data.Speaker{ID: "speaker-1"}))
+	require.Nil(t, repo.Delete("event-1"))
+
+	entries, err := os.ReadDir(dir)
+	require.Nil(t, err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	assert.Equal(t, []string{"events.json", "speakers.json", "talks.json"}, names)
+}
+
+func TestEventServiceWithFileRepository(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := data.NewFileRepository(dir)
+	require.Nil(t, err)
+	es := data.NewEventServiceWithRepository(repo)
+
+	_, err = es.CreateEvent(data.Event{
+		ID:        "event-1",
+		DateStart: date("01/01/2010"),
+		DateEnd:   date("02/01/2010"),
+	})
+	require.Nil(t, err)
+	created, err := es.CreateTalk("event-1", data.Talk{
+		Title: "event 1 talk 1",
+		Date:  date("01/01/2010"),
+	})
+	require.Nil(t, err)
+
+	reopened, err := data.NewFileRepository(dir)
+	require.Nil(t, err)
+	talk, err := data.NewEventServiceWithRepository(reopened).GetTalk("event-1", created.ID)
+	require.Nil(t, err)
+	assert.Equal(t, *created, *talk)
+}
+
+// TestRepositories checks the behaviour every EventRepository implementation must share.
+func TestRepositories(t *testing.T) {
+	repos := map[string]func(t *testing.T) data.EventRepository{
+		"memory": func(t *testing.T) data.EventRepository {
+			return data.NewMemoryRepository()
+		},
+		"file": func(t *testing.T) data.EventRepository {
+			repo, err := data.NewFileRepository(t.TempDir())
+			require.Nil(t, err)
+			return repo
+		},
+		"sqlite": func(t *testing.T) data.EventRepository {
+			repo, err := data.NewSQLiteRepository(filepath.Join(t.TempDir(), "events.db"))
+			require.Nil(t, err)
+			t.Cleanup(func() { repo.Close() })
+			return repo
+		},
+	}
+	event := data.Event{
+		ID:        "event-1",
+		Name:      "Event 1",
+		DateStart: date("01/01/2010"),
+		DateEnd:   date("02/01/2010"),
+		Location:  "Amsterdam",
+		Talks: []data.Talk{
+			{
+				ID:         "talk-1-2",
+				EventID:    "event-1",
+				Title:      "event 1 talk 2",
+				Speakers:   []string{"Speaker 1", "Speaker 2"},
+				SpeakerIDs: []string{"speaker-1", "speaker-2"},
+				Date:       date("01/01/2010"),
+				Time:       dateTime("01/01/2010 09:00"),
+				Duration:   45,
+				Room:       "Main",
+				Track:      "Testing",
+			},
+			{
+				ID:      "talk-1-1",
+				EventID: "event-1",
+				Title:   "event 1 talk 1",
+				Date:    date("02/01/2010"),
+			},
+		},
+	}
+	for name, newRepo := range repos {
+		t.Run(name, func(t *testing.T) {
+			repo := newRepo(t)
+
+			_, ok, err := repo.Get(event.ID)
+			require.Nil(t, err)
+			assert.False(t, ok)
+
+			require.Nil(t, repo.Save(event))
+			fetched, ok, err := repo.Get(event.ID)
+			require.Nil(t, err)
+			require.True(t, ok)
+			assert.Equal(t, event, fetched)
+
+			updated := event
+			updated.Name = "Event 1 updated"
+			updated.Talks = event.Talks[1:]
+			require.Nil(t, repo.Save(updated))
+			fetched, _, err = repo.Get(event.ID)
+			require.Nil(t, err)
+			assert.Equal(t, updated, fetched)
+
+			require.Nil(t, repo.Save(data.Event{ID: "event-2"}))
+			events, err := repo.List()
+			require.Nil(t, err)
+			assert.Len(t, events, 2)
+			assert.Contains(t, events, updated)
+
+			require.Nil(t, repo.Delete(event.ID))
+			_, ok, err = repo.Get(event.ID)
+			require.Nil(t, err)
+			assert.False(t, ok)
+			events, err = repo.List()
+			require.Nil(t, err)
+			assert.Len(t, events, 1)
+
+			speaker := data.Speaker{
+				ID:      "speaker-1",
+				Name:    "Speaker 1",
+				Bio:     "Speaks at events",
+				Company: "Company 1",
+				Links:   []string{"https://example.com/speaker-1"},
+			}
+			_, ok, err = repo.GetSpeaker(speaker.ID)
+			require.Nil(t, err)
+			assert.False(t, ok)
+			require.Nil(t, repo.SaveSpeaker(speaker))
+			require.Nil(t, repo.SaveSpeaker(data.Speaker{ID: "speaker-2", Name: "Speaker 2"}))
+			fetchedSpeaker, ok, err := repo.GetSpeaker(speaker.ID)
+			require.Nil(t, err)
+			require.True(t, ok)
+			assert.Equal(t, speaker, fetchedSpeaker)
+			speakers, err := repo.ListSpeakers()
+			require.Nil(t, err)
+			assert.ElementsMatch(t, []data.Speaker{speaker, {ID: "speaker-2", Name: "Speaker 2"}}, speakers)
+
+			require.Nil(t, repo.DeleteSpeaker("speaker-2"))
+			_, ok, err = repo.GetSpeaker("speaker-2")
+			require.Nil(t, err)
+			assert.False(t, ok)
+			speakers, err = repo.ListSpeakers()
+			require.Nil(t, err)
+			assert.Equal(t, []data.Speaker{speaker}, speakers)
+		})
+	}
+}
+
+func TestSQLiteRepositoryReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.db")
+	repo, err := data.NewSQLiteRepository(path)
+	require.Nil(t, err)
+	require.Nil(t, repo.Save(data.Event{ID: "event-1"}))
+	require.Nil(t, repo.Close())
+
+	// migrations must not be applied twice
+	reopened, err := data.NewSQLiteRepository(path)
+	require.Nil(t, err)
+	defer reopened.Close()
+	_, ok, err := reopened.Get("event-1")
+	require.Nil(t, err)
+	assert.True(t, ok)
+}
+
+func TestSQLiteRepositoryPath(t *testing.T) {
+	testCases := map[string]string{
+		"question mark": "events?mode=ro.db",
+		"hash":          "events#1.db",
+		"percent":       "events%20.db",
+		"space":         "my events.db",
+	}
+	for name, file := range testCases {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, file)
+			repo, err := data.NewSQLiteRepository(path)
+			require.Nil(t, err)
+			require.Nil(t, repo.Save(data.Event{ID: "event-1"}))
+			// the WAL file is only there if the pragmas were applied
+			_, err = os.Stat(path + "-wal")
+			assert.Nil(t, err)
+			require.Nil(t, repo.Close())
+
+			entries, err := os.ReadDir(dir)
+			require.Nil(t, err)
+			require.Len(t, entries, 1)
+			assert.Equal(t, file, entries[0].Name())
+			reopened, err := data.NewSQLiteRepository(path)
+			require.Nil(t, err)
+			defer reopened.Close()
+			_, ok, err := reopened.Get("event-1")
+			require.Nil(t, err)
+			assert.True(t, ok)
+		})
+	}
+}
+
+func TestSQLiteRepositoryLegacyDates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.db")
+	repo, err := data.NewSQLiteRepository(path)
+	require.Nil(t, err)
+	require.Nil(t, repo.Close())
+
+	// rows written before dates were stored in RFC 3339
+	db, err := sql.Open("sqlite", path)
+	require.Nil(t, err)
+	_, err = db.Exec(`INSERT INTO events (id, date_start, date_end) VALUES ('event-1', '01/01/2010', '02/01/2010')`)
+	require.Nil(t, err)
+	_, err = db.Exec(`INSERT INTO talks (event_id, id, position, title, date, time)
+		VALUES ('event-1', 'talk-1-1', 0, 'event 1 talk 1', '01/01/2010', '09:00')`)
+	require.Nil(t, err)
+	require.Nil(t, db.Close())
+
+	reopened, err := data.NewSQLiteRepository(path)
+	require.Nil(t, err)
+	defer reopened.Close()
+	event, ok, err := reopened.Get("event-1")
+	require.Nil(t, err)
+	require.True(t, ok)
+	assert.Equal(t, date("01/01/2010"), event.DateStart)
+	assert.Equal(t, date("02/01/2010"), event.DateEnd)
+	require.Len(t, event.Talks, 1)
+	assert.Equal(t, date("01/01/2010"), event.Talks[0].Date)
+	assert.Equal(t, dateTime("01/01/2010 09:00"), event.Talks[0].Time)
+}
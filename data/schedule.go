@@ -0,0 +1,133 @@
+package data
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// Room is a room of an event, with the number of talks given in it.
+type Room struct {
+	Name      string `json:"name"`
+	TalkCount int    `json:"talk_count"`
+}
+
+type Rooms struct {
+	Rooms []Room `json:"rooms"`
+}
+
+// Track is a track of an event, with the number of talks in it.
+type Track struct {
+	Name      string `json:"name"`
+	TalkCount int    `json:"talk_count"`
+}
+
+type Tracks struct {
+	Tracks []Track `json:"tracks"`
+}
+
+// ScheduleSlot holds the talks starting at the same date and time.
+// The time of the slot of talks without a time is the zero time.
+type ScheduleSlot struct {
+	Date  time.Time `json:"date"`
+	Time  time.Time `json:"time"`
+	Talks []Talk    `json:"talks"`
+}
+
+// Schedule holds the talks of an event grouped by start time, in the order they start.
+type Schedule struct {
+	EventID string         `json:"event_id"`
+	Slots   []ScheduleSlot `json:"slots"`
+}
+
+// ScheduleQuery selects the talks of a schedule. Talks must match all the fields which are set,
+// ignoring case, and the zero value selects all talks.
+type ScheduleQuery struct {
+	Room  string
+	Track string
+}
+
+// GetEventRooms returns the rooms of the event corresponding to the given id sorted by name,
+// or an error if no event is found. Talks without a room are not counted.
+func (es *EventService) GetEventRooms(id string) (*Rooms, error) {
+	event, err := es.GetEvent(id)
+	if err != nil {
+		return nil, err
+	}
+	rooms := &Rooms{Rooms: []Room{}}
+	for name, count := range countTalks(event.Talks, func(t Talk) string { return t.Room }) {
+		rooms.Rooms = append(rooms.Rooms, Room{Name: name, TalkCount: count})
+	}
+	sort.Slice(rooms.Rooms, func(i, j int) bool {
+		return rooms.Rooms[i].Name < rooms.Rooms[j].Name
+	})
+
+	return rooms, nil
+}
+
+// GetEventTracks returns the tracks of the event corresponding to the given id sorted by name,
+// or an error if no event is found. Talks without a track are not counted.
+func (es *EventService) GetEventTracks(id string) (*Tracks, error) {
+	event, err := es.GetEvent(id)
+	if err != nil {
+		return nil, err
+	}
+	tracks := &Tracks{Tracks: []Track{}}
+	for name, count := range countTalks(event.Talks, func(t Talk) string { return t.Track }) {
+		tracks.Tracks = append(tracks.Tracks, Track{Name: name, TalkCount: count})
+	}
+	sort.Slice(tracks.Tracks, func(i, j int) bool {
+		return tracks.Tracks[i].Name < tracks.Tracks[j].Name
+	})
+
+	return tracks, nil
+}
+
+// countTalks counts the talks by the non-empty values of the given field.
+func countTalks(talks []Talk, field func(t Talk) string) map[string]int {
+	counts := make(map[string]int)
+	for _, t := range talks {
+		if name := field(t); name != "" {
+			counts[name]++
+		}
+	}
+
+	return counts
+}
+
+// GetEventSchedule returns the talks of the event corresponding to the given id which match q,
+// grouped by start time in the order they start, or an error if no event is found.
+// Talks in the same slot are ordered by room. Talks without a time come last.
+func (es *EventService) GetEventSchedule(id string, q ScheduleQuery) (*Schedule, error) {
+	event, err := es.GetEvent(id)
+	if err != nil {
+		return nil, err
+	}
+	var talks []Talk
+	for _, t := range event.Talks {
+		if (q.Room == "" || strings.EqualFold(q.Room, t.Room)) &&
+			(q.Track == "" || strings.EqualFold(q.Track, t.Track)) {
+			talks = append(talks, t)
+		}
+	}
+	sort.SliceStable(talks, func(i, j int) bool {
+		a, b := talks[i].Time, talks[j].Time
+		if !a.Equal(b) {
+			// the zero time of unscheduled talks sorts last
+			return b.IsZero() || (!a.IsZero() && a.Before(b))
+		}
+		return talks[i].Room < talks[j].Room
+	})
+
+	schedule := &Schedule{EventID: event.ID, Slots: []ScheduleSlot{}}
+	for _, t := range talks {
+		n := len(schedule.Slots)
+		if n > 0 && schedule.Slots[n-1].Date.Equal(t.Date) && schedule.Slots[n-1].Time.Equal(t.Time) {
+			schedule.Slots[n-1].Talks = append(schedule.Slots[n-1].Talks, t)
+			continue
+		}
+		schedule.Slots = append(schedule.Slots, ScheduleSlot{Date: t.Date, Time: t.Time, Talks: []Talk{t}})
+	}
+
+	return schedule, nil
+}
@@ -0,0 +1,83 @@
+package data_test
+
+import (
+	"testing"
+
+	"github.com/addetz/testing-strategies-demo/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchedule(t *testing.T) {
+	events := []data.Event{
+		{ID: "event-1", DateStart: date("01/02/2023"), DateEnd: date("02/02/2023")},
+	}
+	talks := []data.Talk{
+		{ID: "talk-1", EventID: "event-1", Title: "Talk 1", Date: date("02/02/2023"), Time: dateTime("02/02/2023 09:00"), Room: "Main", Track: "Go"},
+		{ID: "talk-2", EventID: "event-1", Title: "Talk 2", Date: date("01/02/2023"), Time: dateTime("01/02/2023 10:00"), Room: "Side", Track: "Go"},
+		{ID: "talk-3", EventID: "event-1", Title: "Talk 3", Date: date("01/02/2023"), Time: dateTime("01/02/2023 10:00"), Room: "Main", Track: "Testing"},
+		{ID: "talk-4", EventID: "event-1", Title: "Talk 4", Date: date("01/02/2023"), Time: dateTime("01/02/2023 09:00"), Room: "Main"},
+		{ID: "talk-5", EventID: "event-1", Title: "Talk 5", Date: date("01/02/2023")},
+	}
+	es, err := data.NewEventService(events, talks)
+	require.Nil(t, err)
+
+	t.Run("rooms", func(t *testing.T) {
+		rooms, err := es.GetEventRooms("event-1")
+		require.Nil(t, err)
+		assert.Equal(t, []data.Room{{Name: "Main", TalkCount: 3}, {Name: "Side", TalkCount: 1}}, rooms.Rooms)
+
+		_, err = es.GetEventRooms("event-2")
+		assert.ErrorIs(t, err, data.ErrEventNotFound)
+	})
+
+	t.Run("tracks", func(t *testing.T) {
+		tracks, err := es.GetEventTracks("event-1")
+		require.Nil(t, err)
+		assert.Equal(t, []data.Track{{Name: "Go", TalkCount: 2}, {Name: "Testing", TalkCount: 1}}, tracks.Tracks)
+	})
+
+	testCases := map[string]struct {
+		query         data.ScheduleQuery
+		expectedSlots [][]string
+	}{
+		"all talks": {
+			expectedSlots: [][]string{{"talk-4"}, {"talk-3", "talk-2"}, {"talk-1"}, {"talk-5"}},
+		},
+		"room ignoring case": {
+			query:         data.ScheduleQuery{Room: "main"},
+			expectedSlots: [][]string{{"talk-4"}, {"talk-3"}, {"talk-1"}},
+		},
+		"room and track": {
+			query:         data.ScheduleQuery{Room: "Main", Track: "Go"},
+			expectedSlots: [][]string{{"talk-1"}},
+		},
+		"unknown room": {
+			query:         data.ScheduleQuery{Room: "Attic"},
+			expectedSlots: [][]string{},
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			schedule, err := es.GetEventSchedule("event-1", tc.query)
+			require.Nil(t, err)
+			assert.Equal(t, "event-1", schedule.EventID)
+			slots := [][]string{}
+			for _, slot := range schedule.Slots {
+				var ids []string
+				for _, talk := range slot.Talks {
+					assert.Equal(t, slot.Date, talk.Date)
+					assert.Equal(t, slot.Time, talk.Time)
+					ids = append(ids, talk.ID)
+				}
+				slots = append(slots, ids)
+			}
+			assert.Equal(t, tc.expectedSlots, slots)
+		})
+	}
+
+	t.Run("negative duration", func(t *testing.T) {
+		_, err := es.CreateTalk("event-1", data.Talk{Title: "Talk 6", Date: date("01/02/2023"), Duration: -30})
+		assert.ErrorIs(t, err, data.ErrInvalidTalkDuration)
+	})
+}
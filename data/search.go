@@ -0,0 +1,304 @@
+package data
+
+import (
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Kinds of documents returned by Search.
+const (
+	SearchKindEvent   = "event"
+	SearchKindTalk    = "talk"
+	SearchKindSpeaker = "speaker"
+)
+
+// SearchResults are the matches of a search, grouped by kind and sorted by descending score.
+type SearchResults struct {
+	Query    string      `json:"query"`
+	Events   []SearchHit `json:"events"`
+	Talks    []SearchHit `json:"talks"`
+	Speakers []SearchHit `json:"speakers"`
+}
+
+// SearchHit is an event, talk or speaker matching a search.
+type SearchHit struct {
+	// ID is the ID of the event, talk or speaker.
+	ID string `json:"id"`
+	// EventID is the event of a talk.
+	EventID string `json:"event_id,omitempty"`
+	// Title is the name of the event or speaker, or the title of the talk.
+	Title string  `json:"title"`
+	Score float64 `json:"score"`
+	// Highlights holds the matched fields, HTML escaped and with the matched words wrapped in <mark> tags.
+	Highlights map[string]string `json:"highlights"`
+}
+
+// searchField is a searchable field of a document.
+// Matches in fields with a higher weight score higher.
+type searchField struct {
+	name   string
+	value  string
+	weight float64
+}
+
+type searchDoc struct {
+	kind    string
+	id      string
+	eventID string
+	title   string
+	fields  []searchField
+}
+
+// searchIndex is an inverted index from the words of events, talks and speakers to their documents.
+type searchIndex struct {
+	docs []searchDoc
+	// postings holds the indexes in docs of the documents containing each word
+	postings map[string][]int
+	// words holds the keys of postings in order, to find the words starting with a prefix
+	words []string
+}
+
+// newSearchIndex indexes the names and locations of events, the titles and speakers of talks,
+// and the names of speakers.
+func newSearchIndex(events []Event) *searchIndex {
+	idx := &searchIndex{postings: make(map[string][]int)}
+	speakers := make(map[string]bool)
+	for _, e := range events {
+		idx.add(searchDoc{
+			kind:  SearchKindEvent,
+			id:    e.ID,
+			title: e.Name,
+			fields: []searchField{
+				{name: "name", value: e.Name, weight: 3},
+				{name: "location", value: e.Location, weight: 1},
+			},
+		})
+		for _, t := range e.Talks {
+			idx.add(searchDoc{
+				kind:    SearchKindTalk,
+				id:      t.ID,
+				eventID: e.ID,
+				title:   t.Title,
+				fields: []searchField{
+					{name: "title", value: t.Title, weight: 3},
+					{name: "speakers", value: strings.Join(t.Speakers, ", "), weight: 1},
+				},
+			})
+			for i, s := range t.Speakers {
+				id := SpeakerID(s)
+				if i < len(t.SpeakerIDs) {
+					id = t.SpeakerIDs[i]
+				}
+				if !speakers[id] {
+					speakers[id] = true
+					idx.add(searchDoc{
+						kind:   SearchKindSpeaker,
+						id:     id,
+						title:  s,
+						fields: []searchField{{name: "name", value: s, weight: 2}},
+					})
+				}
+			}
+		}
+	}
+	for w := range idx.postings {
+		idx.words = append(idx.words, w)
+	}
+	sort.Strings(idx.words)
+
+	return idx
+}
+
+func (idx *searchIndex) add(doc searchDoc) {
+	i := len(idx.docs)
+	idx.docs = append(idx.docs, doc)
+	for _, f := range doc.fields {
+		for _, tok := range tokenize(f.value) {
+			if p := idx.postings[tok.word]; len(p) == 0 || p[len(p)-1] != i {
+				idx.postings[tok.word] = append(p, i)
+			}
+		}
+	}
+}
+
+// search returns the documents containing all the words of q, or words starting with them.
+func (idx *searchIndex) search(q string) SearchResults {
+	results := SearchResults{
+		Query:    q,
+		Events:   []SearchHit{},
+		Talks:    []SearchHit{},
+		Speakers: []SearchHit{},
+	}
+	terms := tokenize(q)
+	if len(terms) == 0 {
+		return results
+	}
+	var matched map[int]bool
+	for _, term := range terms {
+		docs := make(map[int]bool)
+		for _, w := range idx.wordsWithPrefix(term.word) {
+			for _, i := range idx.postings[w] {
+				if matched == nil || matched[i] {
+					docs[i] = true
+				}
+			}
+		}
+		matched = docs
+	}
+
+	for i := range matched {
+		doc := idx.docs[i]
+		hit := SearchHit{
+			ID:         doc.id,
+			EventID:    doc.eventID,
+			Title:      doc.title,
+			Highlights: make(map[string]string),
+		}
+		for _, f := range doc.fields {
+			score, highlighted := matchField(f, terms)
+			if score > 0 {
+				hit.Score += score
+				hit.Highlights[f.name] = highlighted
+			}
+		}
+		switch doc.kind {
+		case SearchKindEvent:
+			results.Events = append(results.Events, hit)
+		case SearchKindTalk:
+			results.Talks = append(results.Talks, hit)
+		case SearchKindSpeaker:
+			results.Speakers = append(results.Speakers, hit)
+		}
+	}
+	for _, hits := range [][]SearchHit{results.Events, results.Talks, results.Speakers} {
+		sortHits(hits)
+	}
+
+	return results
+}
+
+// wordsWithPrefix returns the indexed words which start with prefix.
+func (idx *searchIndex) wordsWithPrefix(prefix string) []string {
+	start := sort.SearchStrings(idx.words, prefix)
+	end := start
+	for end < len(idx.words) && strings.HasPrefix(idx.words[end], prefix) {
+		end++
+	}
+
+	return idx.words[start:end]
+}
+
+// matchField scores the words of f matching terms, with whole words scoring twice as much as prefixes,
+// and returns the value of f with the matching words highlighted.
+func matchField(f searchField, terms []token) (float64, string) {
+	var score float64
+	var b strings.Builder
+	last := 0
+	for _, tok := range tokenize(f.value) {
+		var best float64
+		for _, term := range terms {
+			switch {
+			case tok.word == term.word:
+				best = 2
+			case best == 0 && strings.HasPrefix(tok.word, term.word):
+				best = 1
+			}
+		}
+		if best == 0 {
+			continue
+		}
+		score += best * f.weight
+		b.WriteString(html.EscapeString(f.value[last:tok.start]))
+		b.WriteString("<mark>" + html.EscapeString(f.value[tok.start:tok.end]) + "</mark>")
+		last = tok.end
+	}
+	b.WriteString(html.EscapeString(f.value[last:]))
+
+	return score, b.String()
+}
+
+func sortHits(hits []SearchHit) {
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		if hits[i].Title != hits[j].Title {
+			return hits[i].Title < hits[j].Title
+		}
+		return hits[i].EventID+"/"+hits[i].ID < hits[j].EventID+"/"+hits[j].ID
+	})
+}
+
+// token is a lowercased word of a text, and its position in the text.
+type token struct {
+	word       string
+	start, end int
+}
+
+// tokenize splits s into words of letters and digits.
+func tokenize(s string) []token {
+	var tokens []token
+	start := -1
+	for i, r := range s {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		switch {
+		case isWord && start < 0:
+			start = i
+		case !isWord && start >= 0:
+			tokens = append(tokens, token{word: strings.ToLower(s[start:i]), start: start, end: i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, token{word: strings.ToLower(s[start:]), start: start, end: len(s)})
+	}
+
+	return tokens
+}
+
+// Search returns the events, talks and speakers matching all the words of q.
+// A word also matches the words it is a prefix of, so that results can be shown while typing.
+func (es *EventService) Search(q string) (SearchResults, error) {
+	es.mu.RLock()
+	defer es.mu.RUnlock()
+	es.indexMu.RLock()
+	stale := es.index == nil
+	es.indexMu.RUnlock()
+	if stale {
+		if err := es.buildIndex(); err != nil {
+			return SearchResults{}, err
+		}
+	}
+	// holding mu keeps writes from invalidating the index until the search is done,
+	// and searches only read the index, so they run concurrently
+	es.indexMu.RLock()
+	defer es.indexMu.RUnlock()
+
+	return es.index.search(q), nil
+}
+
+// buildIndex builds the index from the repository, unless another search has built it since it was invalidated.
+// It must be called with mu held.
+func (es *EventService) buildIndex() error {
+	es.indexMu.Lock()
+	defer es.indexMu.Unlock()
+	if es.index != nil {
+		return nil
+	}
+	events, err := es.repo.List()
+	if err != nil {
+		return err
+	}
+	es.index = newSearchIndex(events)
+
+	return nil
+}
+
+// invalidateIndex makes the next search rebuild the index.
+func (es *EventService) invalidateIndex() {
+	es.indexMu.Lock()
+	defer es.indexMu.Unlock()
+	es.index = nil
+}
@@ -0,0 +1,113 @@
+package data_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/addetz/testing-strategies-demo/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearch(t *testing.T) {
+	events := []data.Event{
+		{ID: "gophercon-2023", Name: "GopherCon EU", DateStart: date("26/06/2023"), DateEnd: date("29/06/2023"), Location: "Berlin"},
+		{ID: "devbcn-2023", Name: "DevBcn", DateStart: date("03/07/2023"), DateEnd: date("05/07/2023"), Location: "Barcelona"},
+	}
+	talks := []data.Talk{
+		{ID: "talk-1", EventID: "gophercon-2023", Title: "Testing strategies for Go", Speakers: []string{"Adelina Simion"}, Date: date("27/06/2023")},
+		{ID: "talk-2", EventID: "devbcn-2023", Title: "Go beyond testing", Speakers: []string{"Adelina Simion", "Grace Hopper"}, Date: date("04/07/2023")},
+		{ID: "talk-3", EventID: "devbcn-2023", Title: "Barcelona <3 Kotlin", Speakers: []string{"Ada Lovelace"}, Date: date("04/07/2023")},
+	}
+	es, err := data.NewEventService(events, talks)
+	require.Nil(t, err)
+
+	t.Run("groups and ranks hits", func(t *testing.T) {
+		results, err := es.Search("barcelona")
+		require.Nil(t, err)
+		require.Len(t, results.Events, 1)
+		assert.Equal(t, "devbcn-2023", results.Events[0].ID)
+		assert.Equal(t, "<mark>Barcelona</mark>", results.Events[0].Highlights["location"])
+		require.Len(t, results.Talks, 1)
+		assert.Equal(t, "talk-3", results.Talks[0].ID)
+		assert.Equal(t, "devbcn-2023", results.Talks[0].EventID)
+		assert.Equal(t, "<mark>Barcelona</mark> &lt;3 Kotlin", results.Talks[0].Highlights["title"])
+		assert.Empty(t, results.Speakers)
+	})
+
+	t.Run("equal scores are ordered by title", func(t *testing.T) {
+		results, err := es.Search("testing")
+		require.Nil(t, err)
+		require.Len(t, results.Talks, 2)
+		assert.Equal(t, "talk-2", results.Talks[0].ID)
+		assert.Equal(t, "talk-1", results.Talks[1].ID)
+	})
+
+	t.Run("all words must match, as words or prefixes", func(t *testing.T) {
+		results, err := es.Search("adel sim")
+		require.Nil(t, err)
+		require.Len(t, results.Speakers, 1)
+		assert.Equal(t, "adelina-simion", results.Speakers[0].ID)
+		assert.Equal(t, "<mark>Adelina</mark> <mark>Simion</mark>", results.Speakers[0].Highlights["name"])
+		assert.Len(t, results.Talks, 2)
+
+		results, err = es.Search("adelina kotlin")
+		require.Nil(t, err)
+		assert.Empty(t, results.Talks)
+	})
+
+	t.Run("rebuilt when data changes", func(t *testing.T) {
+		_, err := es.CreateTalk("gophercon-2023", data.Talk{Title: "Fuzzing in Go", Date: date("28/06/2023")})
+		require.Nil(t, err)
+		results, err := es.Search("fuzz")
+		require.Nil(t, err)
+		require.Len(t, results.Talks, 1)
+		assert.Equal(t, "<mark>Fuzzing</mark> in Go", results.Talks[0].Highlights["title"])
+
+		require.Nil(t, es.DeleteEvent("gophercon-2023"))
+		results, err = es.Search("fuzz")
+		require.Nil(t, err)
+		assert.Empty(t, results.Talks)
+	})
+}
+
+// TestSearchConcurrent is most useful when run with the race detector:
+// go test -race ./data
+func TestSearchConcurrent(t *testing.T) {
+	events := []data.Event{
+		{ID: "event-1", Name: "Event 1", DateStart: date("01/01/2010"), DateEnd: date("02/01/2010")},
+	}
+	es, err := data.NewEventService(events, []data.Talk{})
+	require.Nil(t, err)
+
+	const searchers = 8
+	const talks = 50
+	var wg sync.WaitGroup
+	wg.Add(searchers + 1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < talks; i++ {
+			_, err := es.CreateTalk("event-1", data.Talk{Title: fmt.Sprintf("Fuzzing part %d", i), Date: date("01/01/2010")})
+			assert.Nil(t, err)
+		}
+	}()
+	for s := 0; s < searchers; s++ {
+		go func() {
+			defer wg.Done()
+			previous := 0
+			for i := 0; i < talks; i++ {
+				results, err := es.Search("fuzz")
+				assert.Nil(t, err)
+				// talks are only added, so a later search never finds fewer
+				assert.GreaterOrEqual(t, len(results.Talks), previous)
+				previous = len(results.Talks)
+			}
+		}()
+	}
+	wg.Wait()
+
+	results, err := es.Search("fuzz")
+	require.Nil(t, err)
+	assert.Len(t, results.Talks, talks)
+}
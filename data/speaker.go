@@ -0,0 +1,344 @@
+package data
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// NormaliseSpeakerName trims the name and collapses runs of whitespace inside it.
+func NormaliseSpeakerName(name string) string {
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// SpeakerID derives the ID of the speaker with the given name.
+// Names which only differ in case, whitespace or punctuation have the same ID,
+// so they are imported as the same speaker.
+func SpeakerID(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+			continue
+		}
+		dash = true
+	}
+
+	return b.String()
+}
+
+// DuplicateSpeakers are two speakers with different IDs which are likely to be the same person.
+type DuplicateSpeakers struct {
+	IDs    [2]string `json:"ids"`
+	Reason string    `json:"reason"`
+}
+
+// FindDuplicateSpeakers reports the pairs of speakers whose names are likely spellings of the same name:
+// names which differ by at most two characters, or whose first names only match by their initial.
+func FindDuplicateSpeakers(speakers []Speaker) []DuplicateSpeakers {
+	sorted := append([]Speaker{}, speakers...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].ID < sorted[j].ID
+	})
+	var duplicates []DuplicateSpeakers
+	for i, a := range sorted {
+		for _, b := range sorted[i+1:] {
+			if reason := duplicateReason(a.ID, b.ID); reason != "" {
+				duplicates = append(duplicates, DuplicateSpeakers{
+					IDs:    [2]string{a.ID, b.ID},
+					Reason: reason,
+				})
+			}
+		}
+	}
+
+	return duplicates
+}
+
+// duplicateReason explains why the speaker IDs a and b are likely the same person,
+// or returns an empty string if they are not.
+func duplicateReason(a, b string) string {
+	// short names are too likely to be a few edits apart by chance
+	if len(a) >= 6 && len(b) >= 6 && editDistance(a, b) <= 2 {
+		return "names differ by at most two characters"
+	}
+	partsA, partsB := strings.Split(a, "-"), strings.Split(b, "-")
+	if len(partsA) < 2 || len(partsB) < 2 || partsA[len(partsA)-1] != partsB[len(partsB)-1] {
+		return ""
+	}
+	firstA, firstB := []rune(partsA[0]), []rune(partsB[0])
+	if (len(firstA) == 1 || len(firstB) == 1) && firstA[0] == firstB[0] {
+		return "same last name and first initial"
+	}
+
+	return ""
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur := make([]int, len(rb)+1)
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+
+	return prev[len(rb)]
+}
+
+// ImportSpeakers returns the given speakers together with the speakers created from the names in talks
+// which do not match any of them, as Seed would store them.
+func ImportSpeakers(talks []Talk, speakers []Speaker) []Speaker {
+	reg := newSpeakerRegistry(speakers)
+	for _, t := range talks {
+		// talks with invalid speakers are dropped by Seed, so they add no speakers
+		reg.resolve(&t)
+	}
+
+	return reg.list()
+}
+
+// speakerRegistry resolves the speakers of talks while seeding a repository.
+type speakerRegistry struct {
+	speakers map[string]Speaker
+	order    []string
+}
+
+func newSpeakerRegistry(speakers []Speaker) *speakerRegistry {
+	reg := &speakerRegistry{speakers: make(map[string]Speaker)}
+	for _, s := range speakers {
+		if s.ID == "" {
+			s.ID = SpeakerID(s.Name)
+		}
+		s.Name = NormaliseSpeakerName(s.Name)
+		reg.add(s)
+	}
+
+	return reg
+}
+
+func (reg *speakerRegistry) add(s Speaker) {
+	if _, ok := reg.speakers[s.ID]; !ok {
+		reg.order = append(reg.order, s.ID)
+	}
+	reg.speakers[s.ID] = s
+}
+
+func (reg *speakerRegistry) get(id string) (Speaker, bool, error) {
+	s, ok := reg.speakers[id]
+	return s, ok, nil
+}
+
+// resolve fills in the speakers of t from the registry, adding the speakers it names which are not registered yet.
+func (reg *speakerRegistry) resolve(t *Talk) error {
+	return reg.resolveWith(t, reg.get)
+}
+
+// resolveWith fills in the speakers of t looking them up with get,
+// and adds the speakers it names which get does not find to the registry.
+func (reg *speakerRegistry) resolveWith(t *Talk, get func(id string) (Speaker, bool, error)) error {
+	created, err := resolveSpeakers(t, get)
+	if err != nil {
+		return err
+	}
+	for _, s := range created {
+		reg.add(s)
+	}
+
+	return nil
+}
+
+// list returns the speakers in the order they were added.
+func (reg *speakerRegistry) list() []Speaker {
+	speakers := make([]Speaker, 0, len(reg.order))
+	for _, id := range reg.order {
+		speakers = append(speakers, reg.speakers[id])
+	}
+
+	return speakers
+}
+
+// resolveSpeakers fills in the speakers of t, looking them up with get, and returns the speakers to create.
+// If the talk has speaker IDs, its speaker names are taken from the referenced speakers, which must exist,
+// and any names it gives must match them. Otherwise its speaker names are normalised and the speakers
+// with matching IDs are referenced, and those which do not exist are returned to be created.
+// Nothing is saved, so that the caller only creates the speakers once the talk itself is saved.
+func resolveSpeakers(t *Talk, get func(id string) (Speaker, bool, error)) ([]Speaker, error) {
+	if len(t.SpeakerIDs) > 0 {
+		if len(t.Speakers) > 0 && len(t.Speakers) != len(t.SpeakerIDs) {
+			return nil, newError(ErrInvalidSpeaker, "talk has %d speaker names for %d speaker IDs", len(t.Speakers), len(t.SpeakerIDs))
+		}
+		names := make([]string, 0, len(t.SpeakerIDs))
+		for i, id := range t.SpeakerIDs {
+			s, ok, err := get(id)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				return nil, newError(ErrSpeakerNotFound, "no speaker for id %s", id)
+			}
+			if len(t.Speakers) > 0 && SpeakerID(t.Speakers[i]) != SpeakerID(s.Name) {
+				return nil, newError(ErrInvalidSpeaker, "speaker name %q does not match speaker %s named %q", t.Speakers[i], id, s.Name)
+			}
+			names = append(names, s.Name)
+		}
+		t.Speakers = names
+		return nil, nil
+	}
+	if len(t.Speakers) == 0 {
+		return nil, nil
+	}
+	names := make([]string, 0, len(t.Speakers))
+	ids := make([]string, 0, len(t.Speakers))
+	var created []Speaker
+	for _, name := range t.Speakers {
+		id := SpeakerID(name)
+		if id == "" {
+			return nil, newError(ErrInvalidSpeaker, "speaker name %q has no letters or digits", name)
+		}
+		s, ok, err := get(id)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			s, ok = findSpeaker(created, id)
+		}
+		if !ok {
+			s = Speaker{ID: id, Name: NormaliseSpeakerName(name)}
+			created = append(created, s)
+		}
+		names = append(names, s.Name)
+		ids = append(ids, id)
+	}
+	t.Speakers = names
+	t.SpeakerIDs = ids
+
+	return created, nil
+}
+
+// findSpeaker returns the speaker with the given id among speakers, and false if there is none.
+func findSpeaker(speakers []Speaker, id string) (Speaker, bool) {
+	for _, s := range speakers {
+		if s.ID == id {
+			return s, true
+		}
+	}
+
+	return Speaker{}, false
+}
+
+// logDuplicateSpeakers logs the likely duplicates among speakers, for the data to be corrected.
+func logDuplicateSpeakers(speakers []Speaker) {
+	for _, d := range FindDuplicateSpeakers(speakers) {
+		log.Printf("speakers %s and %s may be the same person: %s\n", d.IDs[0], d.IDs[1], d.Reason)
+	}
+}
+
+// GetSpeakers returns all the speakers sorted by name,
+// or an error if the speakers cannot be read from the repository.
+func (es *EventService) GetSpeakers() (Speakers, error) {
+	es.mu.RLock()
+	defer es.mu.RUnlock()
+	speakers, err := es.repo.ListSpeakers()
+	if err != nil {
+		return Speakers{}, err
+	}
+	sort.Slice(speakers, func(i, j int) bool {
+		if speakers[i].Name != speakers[j].Name {
+			return speakers[i].Name < speakers[j].Name
+		}
+		return speakers[i].ID < speakers[j].ID
+	})
+
+	return Speakers{Speakers: speakers}, nil
+}
+
+// GetSpeaker returns the speaker corresponding to the given id,
+// or an error if no speaker is found.
+func (es *EventService) GetSpeaker(id string) (*Speaker, error) {
+	es.mu.RLock()
+	defer es.mu.RUnlock()
+	return es.getSpeaker(id)
+}
+
+// getSpeaker returns the speaker corresponding to the given id.
+// The caller must hold es.mu.
+func (es *EventService) getSpeaker(id string) (*Speaker, error) {
+	s, ok, err := es.repo.GetSpeaker(id)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, newError(ErrSpeakerNotFound, "no speaker for id %s", id)
+	}
+
+	return &s, nil
+}
+
+// GetSpeakerTalks returns the talks of the speaker corresponding to the given id across all events,
+// in the order of the start dates of their events, or an error if no speaker is found.
+func (es *EventService) GetSpeakerTalks(id string) (*Talks, error) {
+	es.mu.RLock()
+	defer es.mu.RUnlock()
+	if _, err := es.getSpeaker(id); err != nil {
+		return nil, err
+	}
+	events, err := es.repo.List()
+	if err != nil {
+		return nil, err
+	}
+	less := eventOrders[SortDateStart]
+	sort.Slice(events, func(i, j int) bool {
+		return less(events[i], events[j])
+	})
+	talks := &Talks{Talks: []Talk{}}
+	for _, e := range events {
+		for _, t := range e.Talks {
+			for _, speakerID := range t.SpeakerIDs {
+				if speakerID == id {
+					talks.Talks = append(talks.Talks, t)
+					break
+				}
+			}
+		}
+	}
+
+	return talks, nil
+}
+
+// resolveSpeakers fills in the speakers of t from the repository,
+// and returns the speakers named by t which do not exist yet, for saveSpeakers to create once the talk is saved.
+// The caller must hold es.mu for writing.
+func (es *EventService) resolveSpeakers(t *Talk) ([]Speaker, error) {
+	return resolveSpeakers(t, es.repo.GetSpeaker)
+}
+
+// saveSpeakers creates the given speakers in the repository.
+// The caller must hold es.mu for writing.
+func (es *EventService) saveSpeakers(speakers []Speaker) error {
+	for _, s := range speakers {
+		if err := es.repo.SaveSpeaker(s); err != nil {
+			return fmt.Errorf("save speaker %s: %w", s.ID, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,145 @@
+package data_test
+
+import (
+	"testing"
+
+	"github.com/addetz/testing-strategies-demo/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpeakerID(t *testing.T) {
+	testCases := map[string]struct {
+		name       string
+		expectedID string
+	}{
+		"name":                 {name: "Adelina Simion", expectedID: "adelina-simion"},
+		"whitespace and case":  {name: "  ADELINA   simion ", expectedID: "adelina-simion"},
+		"punctuation":          {name: "Conan O'Brien-Smith", expectedID: "conan-o-brien-smith"},
+		"non-ascii letters":    {name: "José Müller", expectedID: "josé-müller"},
+		"no letters or digits": {name: " - ", expectedID: ""},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expectedID, data.SpeakerID(tc.name))
+		})
+	}
+}
+
+func TestFindDuplicateSpeakers(t *testing.T) {
+	speakers := []data.Speaker{
+		{ID: "adelina-simion", Name: "Adelina Simion"},
+		{ID: "adelina-simeon", Name: "Adelina Simeon"},
+		{ID: "a-simion", Name: "A. Simion"},
+		{ID: "grace-hopper", Name: "Grace Hopper"},
+		{ID: "ada", Name: "Ada"},
+		{ID: "abe", Name: "Abe"},
+	}
+
+	duplicates := data.FindDuplicateSpeakers(speakers)
+
+	assert.Equal(t, []data.DuplicateSpeakers{
+		{IDs: [2]string{"a-simion", "adelina-simion"}, Reason: "same last name and first initial"},
+		{IDs: [2]string{"adelina-simeon", "adelina-simion"}, Reason: "names differ by at most two characters"},
+	}, duplicates)
+}
+
+func TestSpeakers(t *testing.T) {
+	events := []data.Event{
+		{ID: "event-1", DateStart: date("01/02/2023"), DateEnd: date("01/02/2023")},
+		{ID: "event-2", DateStart: date("01/01/2023"), DateEnd: date("01/01/2023")},
+	}
+	talks := []data.Talk{
+		{ID: "talk-1", EventID: "event-1", Title: "Talk 1", Speakers: []string{"Adelina  Simion", "Grace Hopper"}, Date: date("01/02/2023")},
+		{ID: "talk-2", EventID: "event-2", Title: "Talk 2", Speakers: []string{"adelina simion"}, Date: date("01/01/2023")},
+		{ID: "talk-3", EventID: "event-2", Title: "Talk 3", SpeakerIDs: []string{"unknown"}, Date: date("01/01/2023")},
+	}
+	speakers := []data.Speaker{
+		{ID: "grace-hopper", Name: "Grace Hopper", Company: "US Navy"},
+	}
+	es, err := data.NewEventService(events, talks, data.WithSpeakers(speakers))
+	require.Nil(t, err)
+
+	t.Run("imported speakers are normalised", func(t *testing.T) {
+		fetched, err := es.GetSpeakers()
+		require.Nil(t, err)
+		assert.Equal(t, []data.Speaker{
+			{ID: "adelina-simion", Name: "Adelina Simion"},
+			{ID: "grace-hopper", Name: "Grace Hopper", Company: "US Navy"},
+		}, fetched.Speakers)
+
+		talk, err := es.GetTalk("event-2", "talk-2")
+		require.Nil(t, err)
+		assert.Equal(t, []string{"Adelina Simion"}, talk.Speakers)
+		assert.Equal(t, []string{"adelina-simion"}, talk.SpeakerIDs)
+	})
+
+	t.Run("talks with unknown speaker IDs are dropped", func(t *testing.T) {
+		_, err := es.GetTalk("event-2", "talk-3")
+		assert.ErrorIs(t, err, data.ErrTalkNotFound)
+	})
+
+	t.Run("speaker talks across events", func(t *testing.T) {
+		fetched, err := es.GetSpeakerTalks("adelina-simion")
+		require.Nil(t, err)
+		require.Len(t, fetched.Talks, 2)
+		assert.Equal(t, "talk-2", fetched.Talks[0].ID)
+		assert.Equal(t, "talk-1", fetched.Talks[1].ID)
+
+		_, err = es.GetSpeakerTalks("unknown")
+		assert.ErrorIs(t, err, data.ErrSpeakerNotFound)
+	})
+
+	t.Run("create talk referencing speakers", func(t *testing.T) {
+		created, err := es.CreateTalk("event-1", data.Talk{Title: "Talk 4", SpeakerIDs: []string{"grace-hopper"}, Date: date("01/02/2023")})
+		require.Nil(t, err)
+		assert.Equal(t, []string{"Grace Hopper"}, created.Speakers)
+
+		_, err = es.CreateTalk("event-1", data.Talk{Title: "Talk 5", SpeakerIDs: []string{"unknown"}, Date: date("01/02/2023")})
+		assert.ErrorIs(t, err, data.ErrSpeakerNotFound)
+	})
+
+	t.Run("create talk naming a new speaker", func(t *testing.T) {
+		created, err := es.CreateTalk("event-1", data.Talk{Title: "Talk 6", Speakers: []string{"Ada Lovelace "}, Date: date("01/02/2023")})
+		require.Nil(t, err)
+		assert.Equal(t, []string{"ada-lovelace"}, created.SpeakerIDs)
+
+		speaker, err := es.GetSpeaker("ada-lovelace")
+		require.Nil(t, err)
+		assert.Equal(t, data.Speaker{ID: "ada-lovelace", Name: "Ada Lovelace"}, *speaker)
+	})
+
+	t.Run("speaker names must match speaker IDs", func(t *testing.T) {
+		_, err := es.CreateTalk("event-1", data.Talk{Title: "Talk 8", Speakers: []string{"Ada Lovelace"}, SpeakerIDs: []string{"grace-hopper"}, Date: date("01/02/2023")})
+		assert.ErrorIs(t, err, data.ErrInvalidSpeaker)
+		assert.EqualError(t, err, `speaker name "Ada Lovelace" does not match speaker grace-hopper named "Grace Hopper"`)
+
+		_, err = es.UpdateTalk("event-1", "talk-1", data.Talk{Title: "Talk 1", Speakers: []string{"Grace Hopper", "Ada Lovelace"}, SpeakerIDs: []string{"grace-hopper"}, Date: date("01/02/2023")})
+		assert.ErrorIs(t, err, data.ErrInvalidSpeaker)
+		assert.EqualError(t, err, "talk has 2 speaker names for 1 speaker IDs")
+
+		updated, err := es.UpdateTalk("event-1", "talk-1", data.Talk{Title: "Talk 1", Speakers: []string{"grace  hopper"}, SpeakerIDs: []string{"grace-hopper"}, Date: date("01/02/2023")})
+		require.Nil(t, err)
+		assert.Equal(t, []string{"Grace Hopper"}, updated.Speakers)
+	})
+}
+
+func TestFailedTalkWriteCreatesNoSpeakers(t *testing.T) {
+	memory := data.NewMemoryRepository()
+	_, err := data.Seed(memory, []data.Event{
+		{ID: "event-1", DateStart: date("01/02/2023"), DateEnd: date("01/02/2023")},
+	}, []data.Talk{
+		{ID: "talk-1", EventID: "event-1", Title: "Talk 1", Date: date("01/02/2023")},
+	}, nil)
+	require.Nil(t, err)
+	es := data.NewEventServiceWithRepository(&failingRepository{MemoryRepository: memory})
+
+	_, err = es.CreateTalk("event-1", data.Talk{Title: "Talk 2", Speakers: []string{"Alan Turing"}, Date: date("01/02/2023")})
+	assert.EqualError(t, err, "disk full")
+	_, err = es.UpdateTalk("event-1", "talk-1", data.Talk{Title: "Talk 1", Speakers: []string{"Alan Turing"}, Date: date("01/02/2023")})
+	assert.EqualError(t, err, "disk full")
+
+	speakers, err := es.GetSpeakers()
+	require.Nil(t, err)
+	assert.Empty(t, speakers.Speakers)
+}
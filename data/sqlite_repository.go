@@ -0,0 +1,338 @@
+package data
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	// registers the pure Go "sqlite" driver, so no cgo is needed
+	_ "modernc.org/sqlite"
+)
+
+// migrations are applied in order, each exactly once.
+// Never edit a released migration; append a new one instead.
+var migrations = []string{
+	`CREATE TABLE events (
+		id         TEXT PRIMARY KEY,
+		name       TEXT NOT NULL DEFAULT '',
+		date_start TEXT NOT NULL DEFAULT '',
+		date_end   TEXT NOT NULL DEFAULT '',
+		location   TEXT NOT NULL DEFAULT ''
+	);
+	CREATE TABLE talks (
+		event_id TEXT NOT NULL REFERENCES events(id),
+		id       TEXT NOT NULL,
+		position INTEGER NOT NULL,
+		title    TEXT NOT NULL DEFAULT '',
+		speakers TEXT NOT NULL DEFAULT '[]',
+		date     TEXT NOT NULL DEFAULT '',
+		time     TEXT NOT NULL DEFAULT '',
+		PRIMARY KEY (event_id, id)
+	);
+	CREATE INDEX idx_talks_event_id ON talks(event_id);
+	CREATE INDEX idx_talks_date ON talks(date);`,
+	`CREATE TABLE speakers (
+		id      TEXT PRIMARY KEY,
+		name    TEXT NOT NULL DEFAULT '',
+		bio     TEXT NOT NULL DEFAULT '',
+		company TEXT NOT NULL DEFAULT '',
+		links   TEXT NOT NULL DEFAULT '[]'
+	);
+	ALTER TABLE talks ADD COLUMN speaker_ids TEXT NOT NULL DEFAULT '[]';`,
+	`ALTER TABLE talks ADD COLUMN duration INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE talks ADD COLUMN room TEXT NOT NULL DEFAULT '';
+	ALTER TABLE talks ADD COLUMN track TEXT NOT NULL DEFAULT '';`,
+	`ALTER TABLE events ADD COLUMN time_zone TEXT NOT NULL DEFAULT '';`,
+}
+
+// SQLiteRepository is an EventRepository which stores its events in a SQLite database.
+type SQLiteRepository struct {
+	db *sql.DB
+}
+
+// NewSQLiteRepository opens or creates the SQLite database at path
+// and migrates it to the latest schema.
+func NewSQLiteRepository(path string) (*SQLiteRepository, error) {
+	dsn, err := sqliteURI(path)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", path, err)
+	}
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", path, err)
+	}
+	// a single connection serialises writers, which SQLite cannot run concurrently anyway
+	db.SetMaxOpenConns(1)
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrate %s: %w", path, err)
+	}
+
+	return &SQLiteRepository{
+		db: db,
+	}, nil
+}
+
+// sqliteURI returns the file URI of the database at path, with the pragmas every connection runs.
+// The path is made absolute and escaped, so that characters such as ?, # and % are part of the file name.
+func sqliteURI(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	abs = filepath.ToSlash(abs)
+	if !strings.HasPrefix(abs, "/") {
+		// a Windows path such as C:/data.db
+		abs = "/" + abs
+	}
+	u := url.URL{
+		Scheme:   "file",
+		Path:     abs,
+		RawQuery: url.Values{"_pragma": {"busy_timeout(5000)", "journal_mode(WAL)"}}.Encode(),
+	}
+
+	return u.String(), nil
+}
+
+// Close closes the underlying database.
+func (sr *SQLiteRepository) Close() error {
+	return sr.db.Close()
+}
+
+// migrate applies all migrations newer than the version recorded in the database.
+func migrate(db *sql.DB) error {
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY)`); err != nil {
+		return err
+	}
+	var version int
+	if err := db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version); err != nil {
+		return err
+	}
+	for i := version; i < len(migrations); i++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(migrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+		if _, err := tx.Exec(`INSERT INTO schema_migrations (version) VALUES (?)`, i+1); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (sr *SQLiteRepository) List() ([]Event, error) {
+	rows, err := sr.db.Query(`SELECT id, name, date_start, date_end, time_zone, location FROM events ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	events, err := scanEvents(rows)
+	if err != nil {
+		return nil, err
+	}
+	talks, err := sr.queryTalks(`SELECT event_id, id, title, speakers, speaker_ids, date, time, duration, room, track FROM talks ORDER BY event_id, position`)
+	if err != nil {
+		return nil, err
+	}
+	for i := range events {
+		events[i].Talks = talks[events[i].ID]
+		events[i] = localise(events[i])
+	}
+
+	return events, nil
+}
+
+func (sr *SQLiteRepository) Get(id string) (Event, bool, error) {
+	rows, err := sr.db.Query(`SELECT id, name, date_start, date_end, time_zone, location FROM events WHERE id = ?`, id)
+	if err != nil {
+		return Event{}, false, err
+	}
+	events, err := scanEvents(rows)
+	if err != nil {
+		return Event{}, false, err
+	}
+	if len(events) == 0 {
+		return Event{}, false, nil
+	}
+	talks, err := sr.queryTalks(`SELECT event_id, id, title, speakers, speaker_ids, date, time, duration, room, track FROM talks WHERE event_id = ? ORDER BY position`, id)
+	if err != nil {
+		return Event{}, false, err
+	}
+	event := events[0]
+	event.Talks = talks[id]
+
+	return localise(event), true, nil
+}
+
+func (sr *SQLiteRepository) Save(e Event) error {
+	tx, err := sr.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	_, err = tx.Exec(`INSERT INTO events (id, name, date_start, date_end, time_zone, location) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET name = excluded.name, date_start = excluded.date_start,
+		date_end = excluded.date_end, time_zone = excluded.time_zone, location = excluded.location`,
+		e.ID, e.Name, formatTime(e.DateStart), formatTime(e.DateEnd), e.TimeZone, e.Location)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM talks WHERE event_id = ?`, e.ID); err != nil {
+		return err
+	}
+	for i, t := range e.Talks {
+		speakers, err := json.Marshal(t.Speakers)
+		if err != nil {
+			return err
+		}
+		speakerIDs, err := json.Marshal(t.SpeakerIDs)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`INSERT INTO talks (event_id, id, position, title, speakers, speaker_ids, date, time, duration, room, track)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			e.ID, t.ID, i, t.Title, string(speakers), string(speakerIDs), formatTime(t.Date), formatTime(t.Time), t.Duration, t.Room, t.Track)
+		if err != nil {
+			return fmt.Errorf("save talk %s: %w", t.ID, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (sr *SQLiteRepository) Delete(id string) error {
+	tx, err := sr.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`DELETE FROM talks WHERE event_id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM events WHERE id = ?`, id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (sr *SQLiteRepository) ListSpeakers() ([]Speaker, error) {
+	return sr.querySpeakers(`SELECT id, name, bio, company, links FROM speakers ORDER BY id`)
+}
+
+func (sr *SQLiteRepository) GetSpeaker(id string) (Speaker, bool, error) {
+	speakers, err := sr.querySpeakers(`SELECT id, name, bio, company, links FROM speakers WHERE id = ?`, id)
+	if err != nil || len(speakers) == 0 {
+		return Speaker{}, false, err
+	}
+
+	return speakers[0], true, nil
+}
+
+func (sr *SQLiteRepository) SaveSpeaker(s Speaker) error {
+	links, err := json.Marshal(s.Links)
+	if err != nil {
+		return err
+	}
+	_, err = sr.db.Exec(`INSERT INTO speakers (id, name, bio, company, links) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET name = excluded.name, bio = excluded.bio,
+		company = excluded.company, links = excluded.links`,
+		s.ID, s.Name, s.Bio, s.Company, string(links))
+
+	return err
+}
+
+func (sr *SQLiteRepository) DeleteSpeaker(id string) error {
+	_, err := sr.db.Exec(`DELETE FROM speakers WHERE id = ?`, id)
+
+	return err
+}
+
+// querySpeakers runs the given speakers query and returns the speakers.
+func (sr *SQLiteRepository) querySpeakers(query string, args ...any) ([]Speaker, error) {
+	rows, err := sr.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	speakers := []Speaker{}
+	for rows.Next() {
+		var s Speaker
+		var links string
+		if err := rows.Scan(&s.ID, &s.Name, &s.Bio, &s.Company, &links); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(links), &s.Links); err != nil {
+			return nil, fmt.Errorf("decode links of speaker %s: %w", s.ID, err)
+		}
+		speakers = append(speakers, s)
+	}
+
+	return speakers, rows.Err()
+}
+
+// scanEvents reads all event rows and closes them.
+// The dates are left for localise to move to the time zone of the event.
+func scanEvents(rows *sql.Rows) ([]Event, error) {
+	defer rows.Close()
+	events := []Event{}
+	for rows.Next() {
+		var e Event
+		var start, end string
+		if err := rows.Scan(&e.ID, &e.Name, &start, &end, &e.TimeZone, &e.Location); err != nil {
+			return nil, err
+		}
+		// dates which do not parse were never valid, and are left as the zero time
+		e.DateStart, _ = ParseDate(start)
+		e.DateEnd, _ = ParseDate(end)
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
+
+// queryTalks runs the given talks query and returns the talks grouped by event ID.
+func (sr *SQLiteRepository) queryTalks(query string, args ...any) (map[string][]Talk, error) {
+	rows, err := sr.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	talks := make(map[string][]Talk)
+	for rows.Next() {
+		var t Talk
+		var speakers, speakerIDs, date, start string
+		if err := rows.Scan(&t.EventID, &t.ID, &t.Title, &speakers, &speakerIDs, &date, &start, &t.Duration, &t.Room, &t.Track); err != nil {
+			return nil, err
+		}
+		// rows saved before dates were parsed hold the legacy formats
+		t.Date, _ = ParseDate(date)
+		if start != "" {
+			t.Time, _ = ParseTalkTime(t.Date, start)
+		}
+		if err := json.Unmarshal([]byte(speakers), &t.Speakers); err != nil {
+			return nil, fmt.Errorf("decode speakers of talk %s: %w", t.ID, err)
+		}
+		if err := json.Unmarshal([]byte(speakerIDs), &t.SpeakerIDs); err != nil {
+			return nil, fmt.Errorf("decode speaker IDs of talk %s: %w", t.ID, err)
+		}
+		// talks stored before speakers had IDs have an empty list
+		if len(t.SpeakerIDs) == 0 {
+			t.SpeakerIDs = nil
+		}
+		talks[t.EventID] = append(talks[t.EventID], t)
+	}
+
+	return talks, rows.Err()
+}
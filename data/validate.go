@@ -0,0 +1,217 @@
+package data
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ValidationIssue is a single problem found in the data.
+// Path is a JSONPath to the offending value, relative to the file it came from,
+// such as $.events[0].date_start in events.json or $.talks[3].event_id in talks.json.
+type ValidationIssue struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// ValidationReport lists every problem found in a set of events and talks.
+// Warnings are schedule conflicts and talks without IDs, which organisers should resolve but which do not make the data invalid.
+type ValidationReport struct {
+	Issues   []ValidationIssue `json:"issues"`
+	Warnings []ValidationIssue `json:"warnings,omitempty"`
+}
+
+// Valid reports whether no problems were found.
+func (r ValidationReport) Valid() bool {
+	return len(r.Issues) == 0
+}
+
+func (r *ValidationReport) add(path, format string, args ...any) {
+	r.Issues = append(r.Issues, ValidationIssue{
+		Path:    path,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (r *ValidationReport) warn(path, format string, args ...any) {
+	r.Warnings = append(r.Warnings, ValidationIssue{
+		Path:    path,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// ValidationError is returned by NewEventService in strict mode
+// when the given data does not pass validation.
+type ValidationError struct {
+	Report ValidationReport
+}
+
+func (e *ValidationError) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "data failed validation with %d issues", len(e.Report.Issues))
+	for _, issue := range e.Report.Issues {
+		fmt.Fprintf(&sb, "\n%s: %s", issue.Path, issue.Message)
+	}
+
+	return sb.String()
+}
+
+// Validate checks the given events and talks and returns a report of every problem found:
+// missing or duplicate IDs, unknown time zones, dates and times which do not parse,
+// events which end before they start, talks which belong to no event or fall outside their event's dates,
+// and talks referencing speaker IDs which are not among the given speakers or do not match their speaker names,
+// or naming speakers without letters or digits.
+// Talks overlapping in the same room, speakers booked for overlapping talks and talks without an ID,
+// whose derived ID changes with their title, date or time, are reported as warnings.
+func Validate(events []Event, talks []Talk, speakers []Speaker) ValidationReport {
+	var report ValidationReport
+	knownSpeakers := make(map[string]Speaker, len(speakers))
+	for _, s := range newSpeakerRegistry(speakers).list() {
+		knownSpeakers[s.ID] = s
+	}
+	type eventDates struct {
+		// event is in its own time zone
+		event Event
+		valid bool
+	}
+	dates := make(map[string]eventDates, len(events))
+	for i, e := range events {
+		path := fmt.Sprintf("$.events[%d]", i)
+		if e.ID == "" {
+			report.add(path+".id", "event ID cannot be empty")
+		} else if _, ok := dates[e.ID]; ok {
+			report.add(path+".id", "duplicate event ID %q", e.ID)
+			continue
+		}
+		if _, err := loadLocation(e.TimeZone); err != nil {
+			report.add(path+".time_zone", "unknown time zone %q", e.TimeZone)
+		}
+		startValid := checkDate(&report, path, "date_start", e.DateStart, e.unparsed)
+		endValid := checkDate(&report, path, "date_end", e.DateEnd, e.unparsed)
+		e.Talks = nil
+		e = localise(e)
+		valid := startValid && endValid
+		if valid && e.DateStart.After(e.DateEnd) {
+			report.add(path+".date_start", "date_start %s is after date_end %s", formatDate(e.DateStart), formatDate(e.DateEnd))
+			valid = false
+		}
+		if e.ID != "" {
+			dates[e.ID] = eventDates{event: e, valid: valid}
+		}
+	}
+
+	talkIDs := make(map[string]bool, len(talks))
+	// eventTalks holds the indexes of the talks of each event, in order
+	eventTalks := make(map[string][]int)
+	// localised holds the talks in the time zones of their events
+	localised := make([]Talk, len(talks))
+	for i, t := range talks {
+		path := fmt.Sprintf("$.talks[%d]", i)
+		if t.ID == "" {
+			report.warn(path+".id", "talk has no id, so its ID is derived from its title, date and time and changes with them")
+		} else {
+			key := t.EventID + "/" + t.ID
+			if talkIDs[key] {
+				report.add(path+".id", "duplicate talk ID %q in event %q", t.ID, t.EventID)
+			}
+			talkIDs[key] = true
+		}
+		if t.Title == "" {
+			report.add(path+".title", "talk title cannot be empty")
+		}
+		if value, ok := t.unparsed.value("time"); ok {
+			report.add(path+".time", "invalid time %q: expected format %s or RFC 3339", value, timeFormat)
+		}
+		if t.Duration < 0 {
+			report.add(path+".duration", "talk duration must not be negative, but was %d", t.Duration)
+		}
+		checkTalkSpeakers(&report, path, t, knownSpeakers)
+		dateValid := !t.Time.IsZero() || checkDate(&report, path, "date", t.Date, t.unparsed)
+		event, ok := dates[t.EventID]
+		if !ok {
+			report.add(path+".event_id", "no event for id %q", t.EventID)
+			continue
+		}
+		eventTalks[t.EventID] = append(eventTalks[t.EventID], i)
+		localised[i] = localiseTalk(t, event.event.location())
+		if dateValid && event.valid {
+			if err := checkTalkDate(event.event, localised[i]); err != nil {
+				report.add(path+".date", "%v", err)
+			}
+		}
+	}
+
+	for _, e := range events {
+		indexes, ok := eventTalks[e.ID]
+		if !ok {
+			continue
+		}
+		// each event's talks are only checked once, even if its ID is duplicated
+		delete(eventTalks, e.ID)
+		eTalks := make([]Talk, len(indexes))
+		for i, index := range indexes {
+			eTalks[i] = localised[index]
+		}
+		for _, c := range findConflicts(dates[e.ID].event, eTalks) {
+			// talks outside their event's dates are already reported as issues
+			if c.kind == ConflictOutsideEventDates {
+				continue
+			}
+			a, b := indexes[c.talks[0]], indexes[c.talks[1]]
+			field := ".room"
+			if c.kind == ConflictSpeakerDoubleBooked {
+				field = ".speakers"
+			}
+			report.warn(fmt.Sprintf("$.talks[%d]%s", b, field), "%s", c.message([]string{talkName(talks, a), talkName(talks, b)}))
+		}
+	}
+
+	return report
+}
+
+// checkDate reports the named date field of the value at path if it could not be parsed or is missing,
+// and returns whether it is valid.
+func checkDate(report *ValidationReport, path, field string, date time.Time, u unparsed) bool {
+	value, ok := u.value(field)
+	if !ok && !date.IsZero() {
+		return true
+	}
+	report.add(path+"."+field, "invalid date %q: expected format %s or RFC 3339", value, dateFormat)
+
+	return false
+}
+
+// talkName returns the ID of the talk at index i, or its path if it has none.
+func talkName(talks []Talk, i int) string {
+	if talks[i].ID != "" {
+		return talks[i].ID
+	}
+
+	return fmt.Sprintf("$.talks[%d]", i)
+}
+
+// checkTalkSpeakers reports the speaker IDs of the talk at path which are not known or do not match its speaker names,
+// or, for a talk without speaker IDs, the speaker names from which no ID can be derived.
+func checkTalkSpeakers(report *ValidationReport, path string, t Talk, known map[string]Speaker) {
+	if len(t.SpeakerIDs) > 0 {
+		if len(t.Speakers) > 0 && len(t.Speakers) != len(t.SpeakerIDs) {
+			report.add(path+".speakers", "talk has %d speaker names for %d speaker IDs", len(t.Speakers), len(t.SpeakerIDs))
+			return
+		}
+		for i, id := range t.SpeakerIDs {
+			s, ok := known[id]
+			switch {
+			case !ok:
+				report.add(fmt.Sprintf("%s.speaker_ids[%d]", path, i), "no speaker for id %q", id)
+			case len(t.Speakers) > 0 && SpeakerID(t.Speakers[i]) != SpeakerID(s.Name):
+				report.add(fmt.Sprintf("%s.speakers[%d]", path, i), "speaker name %q does not match speaker %s named %q", t.Speakers[i], id, s.Name)
+			}
+		}
+		return
+	}
+	for i, name := range t.Speakers {
+		if SpeakerID(name) == "" {
+			report.add(fmt.Sprintf("%s.speakers[%d]", path, i), "speaker name %q has no letters or digits", name)
+		}
+	}
+}
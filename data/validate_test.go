@@ -0,0 +1,148 @@
+package data_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/addetz/testing-strategies-demo/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	testCases := map[string]struct {
+		events           []data.Event
+		talks            []data.Talk
+		speakers         []data.Speaker
+		expectedIssues   []data.ValidationIssue
+		expectedWarnings []data.ValidationIssue
+	}{
+		"valid data": {
+			events: []data.Event{
+				{ID: "event-1", DateStart: date("01/01/2010"), DateEnd: date("02/01/2010")},
+			},
+			talks: []data.Talk{
+				{ID: "talk-1-1", EventID: "event-1", Title: "event 1 talk 1", Date: date("02/01/2010"), Time: dateTime("02/01/2010 09:30")},
+				{EventID: "event-1", Title: "event 1 talk 2", Date: date("01/01/2010")},
+			},
+			expectedWarnings: []data.ValidationIssue{
+				{Path: "$.talks[1].id", Message: "talk has no id, so its ID is derived from its title, date and time and changes with them"},
+			},
+		},
+		"invalid events": {
+			events: []data.Event{
+				{DateStart: date("01/01/2010"), DateEnd: date("02/01/2010")},
+				decode[data.Event](`{"ID": "event-2", "date_start": "2010/01/01", "date_end": "02/01/2010"}`),
+				{ID: "event-3", DateStart: date("03/01/2010"), DateEnd: date("02/01/2010")},
+				{ID: "event-3", DateStart: date("01/01/2010"), DateEnd: date("02/01/2010")},
+			},
+			talks: []data.Talk{},
+			expectedIssues: []data.ValidationIssue{
+				{Path: "$.events[0].id", Message: "event ID cannot be empty"},
+				{Path: "$.events[1].date_start", Message: `invalid date "2010/01/01": expected format 02/01/2006 or RFC 3339`},
+				{Path: "$.events[2].date_start", Message: "date_start 03/01/2010 is after date_end 02/01/2010"},
+				{Path: "$.events[3].id", Message: `duplicate event ID "event-3"`},
+			},
+		},
+		"invalid talks": {
+			events: []data.Event{
+				{ID: "event-1", DateStart: date("01/01/2010"), DateEnd: date("02/01/2010")},
+			},
+			talks: []data.Talk{
+				{ID: "talk-1-1", EventID: "event-1", Title: "event 1 talk 1", Date: date("01/01/2010")},
+				{ID: "talk-1-1", EventID: "event-1", Title: "event 1 talk 2", Date: date("01/01/2010")},
+				decode[data.Talk](`{"id": "talk-1-3", "event_id": "event-1", "date": "01/01/2010", "time": "9am"}`),
+				{ID: "talk-1-4", EventID: "event-1", Title: "event 1 talk 4", Date: date("03/01/2010")},
+				decode[data.Talk](`{"id": "talk-1-5", "event_id": "event-1", "title": "event 1 talk 5", "date": "01-01-2010"}`),
+				{ID: "talk-99-1", EventID: "event-99", Title: "invalid talk", Date: date("01/01/2010")},
+			},
+			expectedIssues: []data.ValidationIssue{
+				{Path: "$.talks[1].id", Message: `duplicate talk ID "talk-1-1" in event "event-1"`},
+				{Path: "$.talks[2].title", Message: "talk title cannot be empty"},
+				{Path: "$.talks[2].time", Message: `invalid time "9am": expected format 15:04 or RFC 3339`},
+				{Path: "$.talks[3].date", Message: "talk date 03/01/2010 is outside of event event-1 dates 01/01/2010-02/01/2010"},
+				{Path: "$.talks[4].date", Message: `invalid date "01-01-2010": expected format 02/01/2006 or RFC 3339`},
+				{Path: "$.talks[5].event_id", Message: `no event for id "event-99"`},
+			},
+		},
+		"invalid speakers": {
+			events: []data.Event{
+				{ID: "event-1", DateStart: date("01/01/2010"), DateEnd: date("02/01/2010")},
+			},
+			talks: []data.Talk{
+				{ID: "talk-1-1", EventID: "event-1", Title: "event 1 talk 1", Date: date("01/01/2010"), SpeakerIDs: []string{"ada-lovelace", "ghost"}},
+				{ID: "talk-1-2", EventID: "event-1", Title: "event 1 talk 2", Date: date("01/01/2010"), Speakers: []string{"Grace Hopper", "???"}},
+				{ID: "talk-1-3", EventID: "event-1", Title: "event 1 talk 3", Date: date("01/01/2010"), Speakers: []string{"Grace Hopper"}, SpeakerIDs: []string{"ada-lovelace"}},
+			},
+			speakers: []data.Speaker{{Name: "Ada Lovelace"}},
+			expectedIssues: []data.ValidationIssue{
+				{Path: "$.talks[0].speaker_ids[1]", Message: `no speaker for id "ghost"`},
+				{Path: "$.talks[1].speakers[1]", Message: `speaker name "???" has no letters or digits`},
+				{Path: "$.talks[2].speakers[0]", Message: `speaker name "Grace Hopper" does not match speaker ada-lovelace named "Ada Lovelace"`},
+			},
+		},
+		"schedule conflicts": {
+			events: []data.Event{
+				{ID: "event-1", DateStart: date("01/01/2010"), DateEnd: date("02/01/2010")},
+				{ID: "event-2", DateStart: date("01/01/2010"), DateEnd: date("02/01/2010")},
+			},
+			talks: []data.Talk{
+				{ID: "talk-1-1", EventID: "event-1", Title: "event 1 talk 1", Date: date("01/01/2010"), Time: dateTime("01/01/2010 09:00"), Duration: 60, Room: "Main"},
+				{ID: "talk-2-1", EventID: "event-2", Title: "event 2 talk 1", Date: date("01/01/2010"), Time: dateTime("01/01/2010 09:00"), Room: "Main"},
+				{EventID: "event-1", Title: "event 1 talk 2", Date: date("01/01/2010"), Time: dateTime("01/01/2010 09:45"), Room: "Main", Speakers: []string{"Ada"}},
+				{EventID: "event-1", Title: "event 1 talk 3", Date: date("01/01/2010"), Time: dateTime("01/01/2010 09:45"), Room: "Side", Speakers: []string{"ada "}},
+			},
+			expectedWarnings: []data.ValidationIssue{
+				{Path: "$.talks[2].id", Message: "talk has no id, so its ID is derived from its title, date and time and changes with them"},
+				{Path: "$.talks[3].id", Message: "talk has no id, so its ID is derived from its title, date and time and changes with them"},
+				{Path: "$.talks[2].room", Message: "talks talk-1-1 and $.talks[2] overlap in room Main"},
+				{Path: "$.talks[3].speakers", Message: "speaker ada is booked for talks $.talks[2] and $.talks[3] at the same time"},
+			},
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			report := data.Validate(tc.events, tc.talks, tc.speakers)
+			assert.Equal(t, tc.expectedIssues, report.Issues)
+			assert.Equal(t, tc.expectedWarnings, report.Warnings)
+			assert.Equal(t, len(tc.expectedIssues) == 0, report.Valid())
+		})
+	}
+}
+
+func TestNewEventServiceStrict(t *testing.T) {
+	events := []data.Event{
+		{ID: "event-1", DateStart: date("01/01/2010"), DateEnd: date("02/01/2010")},
+	}
+
+	t.Run("valid data", func(t *testing.T) {
+		es, err := data.NewEventService(events, []data.Talk{
+			{EventID: "event-1", Title: "event 1 talk 1", Date: date("01/01/2010")},
+		}, data.WithStrictValidation())
+		require.Nil(t, err)
+		assert.NotNil(t, es)
+	})
+	t.Run("invalid data", func(t *testing.T) {
+		es, err := data.NewEventService(events, []data.Talk{
+			{EventID: "event-99", Title: "invalid talk", Date: date("01/01/2010")},
+		}, data.WithStrictValidation())
+		assert.Nil(t, es)
+		var validationErr *data.ValidationError
+		require.True(t, errors.As(err, &validationErr))
+		assert.Equal(t, []data.ValidationIssue{
+			{Path: "$.talks[0].event_id", Message: `no event for id "event-99"`},
+		}, validationErr.Report.Issues)
+		assert.Equal(t, "data failed validation with 1 issues\n$.talks[0].event_id: no event for id \"event-99\"", err.Error())
+	})
+	t.Run("unknown speaker", func(t *testing.T) {
+		es, err := data.NewEventService(events, []data.Talk{
+			{EventID: "event-1", Title: "event 1 talk 1", Date: date("01/01/2010"), SpeakerIDs: []string{"ghost"}},
+		}, data.WithStrictValidation(), data.WithSpeakers([]data.Speaker{{ID: "ada-lovelace", Name: "Ada Lovelace"}}))
+		assert.Nil(t, es)
+		var validationErr *data.ValidationError
+		require.True(t, errors.As(err, &validationErr))
+		assert.Equal(t, []data.ValidationIssue{
+			{Path: "$.talks[0].speaker_ids[0]", Message: `no speaker for id "ghost"`},
+		}, validationErr.Report.Issues)
+	})
+}
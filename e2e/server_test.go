@@ -8,7 +8,7 @@ import (
 	"os"
 	"testing"
 
-	"github.com/addetz/testing-strategies-demo/data"
+	"github.com/addetz/testing-strategies-demo/handlers"
 	"github.com/docker/go-connections/nat"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -20,12 +20,13 @@ func TestServerEvents(t *testing.T) {
 	if os.Getenv("E2E") == "" {
 		t.Skip("Skipping TestServerEvents in short mode.")
 	}
-	expectedEvents := []data.Event{
+	expectedEvents := []handlers.EventV1{
 		{
 			ID:        "ewit-2023",
 			Name:      "European Women in Tech",
 			DateStart: "28/06/2023",
 			DateEnd:   "29/06/2023",
+			TimeZone:  "Europe/Amsterdam",
 			Location:  "Amsterdam",
 		},
 		{
@@ -33,6 +34,7 @@ func TestServerEvents(t *testing.T) {
 			Name:      "DevBcn - The Barcelona Developers Conference",
 			DateStart: "03/07/2023",
 			DateEnd:   "05/07/2023",
+			TimeZone:  "Europe/Madrid",
 			Location:  "Barcelona",
 		},
 		{
@@ -40,6 +42,7 @@ func TestServerEvents(t *testing.T) {
 			Name:      "Copenhagen Developers Festival",
 			DateStart: "30/08/2023",
 			DateEnd:   "01/09/2023",
+			TimeZone:  "Europe/Copenhagen",
 			Location:  "Copenhagen",
 		},
 	}
@@ -69,7 +72,7 @@ func TestServerEvents(t *testing.T) {
 	r.Body.Close()
 	require.Nil(t, err)
 
-	var resp data.Events
+	var resp handlers.EventsV1
 	err = json.Unmarshal(body, &resp)
 	require.Nil(t, err)
 	assert.Len(t, resp.Events, len(expectedEvents))
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/subtle"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/addetz/testing-strategies-demo/data"
+	"github.com/gorilla/mux"
+)
+
+// gzipMagic starts every gzip stream, so that tar.gz archives are recognised whatever their Content-Type.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// RequireToken returns a middleware which only lets through requests with the given bearer token
+// in their Authorization header, and answers the others with a 401 Problem.
+// An empty token lets no request through.
+func RequireToken(token string) mux.MiddlewareFunc {
+	expected := []byte("Bearer " + token)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			given := []byte(r.Header.Get("Authorization"))
+			if token == "" || subtle.ConstantTimeCompare(given, expected) != 1 {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+				p := problem(r, http.StatusUnauthorized, CodeUnauthorized, "a valid admin token is required")
+				writeResponse[Problem](w, r, p.Status, p)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ExportHandler returns every event, talk and speaker as a versioned archive,
+// as JSON by default or as a tar.gz of the data files with format=tar.gz or an Accept header of application/gzip.
+func (h *Handler) ExportHandler(w http.ResponseWriter, r *http.Request) {
+	archive, err := h.service().Export()
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeResponse[data.Archive](w, r, http.StatusOK, archive)
+}
+
+// ImportHandler imports an archive written by ExportHandler, as JSON or tar.gz, and returns a report of the changes.
+// The mode query parameter is merge, the default, or replace, and dry_run reports the changes without making them.
+// Request bodies larger than data.MaxArchiveFileSize are rejected with a 413 Problem.
+func (h *Handler) ImportHandler(w http.ResponseWriter, r *http.Request) {
+	opts, err := archiveImportOptions(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	archive, err := readArchive(http.MaxBytesReader(w, r.Body, data.MaxArchiveFileSize))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	report, err := h.service().ImportArchive(*archive, opts)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeResponse[data.ArchiveImportReport](w, r, http.StatusOK, report)
+}
+
+// archiveImportOptions returns the options of an archive import given by the query parameters of the request.
+func archiveImportOptions(r *http.Request) (data.ArchiveImportOptions, error) {
+	params := r.URL.Query()
+	var opts data.ArchiveImportOptions
+	switch mode := data.ArchiveImportMode(params.Get("mode")); mode {
+	case "", data.ArchiveMerge, data.ArchiveReplace:
+		opts.Mode = mode
+	default:
+		return opts, invalidParam("mode", "must be merge or replace")
+	}
+	if dryRun := params.Get("dry_run"); dryRun != "" {
+		parsed, err := strconv.ParseBool(dryRun)
+		if err != nil {
+			return opts, invalidParam("dry_run", "must be true or false")
+		}
+		opts.DryRun = parsed
+	}
+
+	return opts, nil
+}
+
+// readArchive reads an archive from a request body holding either a tar.gz or JSON.
+func readArchive(body io.Reader) (*data.Archive, error) {
+	br := bufio.NewReader(body)
+	if magic, err := br.Peek(len(gzipMagic)); err == nil && bytes.Equal(magic, gzipMagic) {
+		return data.ReadTarGz(br)
+	}
+	b, err := io.ReadAll(br)
+	if err != nil {
+		return nil, invalidBody(err)
+	}
+
+	return data.ParseArchive("request body", b)
+}
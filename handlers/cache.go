@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// CachePolicy selects the Cache-Control header of successful responses by route.
+type CachePolicy struct {
+	// Default applies to routes without a policy of their own.
+	// No Cache-Control header is set if it is empty.
+	Default string
+	// Routes maps path templates without the API version prefix, such as /events/{id}/schedule,
+	// to the Cache-Control header of their responses.
+	Routes map[string]string
+}
+
+// versionPrefix matches the API version at the start of a path template.
+var versionPrefix = regexp.MustCompile(`^/v[0-9]+(/|$)`)
+
+// For returns the Cache-Control header for the route with the given path template.
+func (p CachePolicy) For(template string) string {
+	if loc := versionPrefix.FindStringIndex(template); loc != nil {
+		template = "/" + template[loc[1]:]
+	}
+	if policy, ok := p.Routes[template]; ok {
+		return policy
+	}
+
+	return p.Default
+}
+
+// maxMemoisedETags bounds the number of ETags remembered by Conditional between changes of the data.
+const maxMemoisedETags = 4096
+
+// Conditional returns a middleware for GET and HEAD requests which sets the Cache-Control header of the policy,
+// an ETag hashed from the response body and a Last-Modified date of when the data last changed.
+// Requests whose If-None-Match or If-Modified-Since headers show that the client is up to date get 304 Not Modified.
+// ETags are remembered until the data changes, so that such requests are answered without building the response again.
+func (h *Handler) Conditional(policy CachePolicy) mux.MiddlewareFunc {
+	var memo etagMemo
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+			var template string
+			if route := mux.CurrentRoute(r); route != nil {
+				template, _ = route.GetPathTemplate()
+			}
+			// read before the response is built, so that the date is never later than the data it describes
+			modified := h.service().LastModified()
+			// the body depends on the Accept header when there is no format parameter
+			key := r.URL.RequestURI() + "\n" + r.Header.Get("Accept")
+			cacheControl := policy.For(template)
+			if etag, ok := memo.get(key, modified); ok && notModified(r, etag, modified) {
+				w.Header().Add("Vary", "Accept")
+				writeNotModified(w, etag, modified, cacheControl)
+				return
+			}
+
+			rec := &bufferedResponse{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+			body := rec.body.Bytes()
+			if r.Method == http.MethodHead {
+				// the response to HEAD is that of GET without its body
+				w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+				body = nil
+			}
+			if rec.status != http.StatusOK {
+				w.WriteHeader(rec.status)
+				w.Write(body)
+				return
+			}
+			etag := fmt.Sprintf("%q", fmt.Sprintf("%x", sha256.Sum256(rec.body.Bytes()))[:32])
+			memo.put(key, modified, etag)
+			if notModified(r, etag, modified) {
+				writeNotModified(w, etag, modified, cacheControl)
+				return
+			}
+			setValidators(w, etag, modified, cacheControl)
+			w.WriteHeader(http.StatusOK)
+			w.Write(body)
+		})
+	}
+}
+
+// notModified reports whether the conditional headers of the request match the response with the given validators.
+// If-Modified-Since is only evaluated without If-None-Match, as RFC 9110 requires.
+func notModified(r *http.Request, etag string, modified time.Time) bool {
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		for _, candidate := range strings.Split(match, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == "*" || candidate == etag {
+				return true
+			}
+		}
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil || modified.IsZero() {
+		return false
+	}
+
+	return !modified.Truncate(time.Second).After(since)
+}
+
+// setValidators sets the caching headers shared by full and 304 responses.
+func setValidators(w http.ResponseWriter, etag string, modified time.Time, cacheControl string) {
+	w.Header().Set("ETag", etag)
+	if !modified.IsZero() {
+		w.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+	if cacheControl != "" {
+		w.Header().Set("Cache-Control", cacheControl)
+	}
+}
+
+// writeNotModified writes a 304 response, which has no body.
+func writeNotModified(w http.ResponseWriter, etag string, modified time.Time, cacheControl string) {
+	w.Header().Del("Content-Type")
+	w.Header().Del("Content-Length")
+	setValidators(w, etag, modified, cacheControl)
+	w.WriteHeader(http.StatusNotModified)
+}
+
+// bufferedResponse holds back the status and body written by a handler, while sharing its headers.
+type bufferedResponse struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	b.status = status
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	return b.body.Write(p)
+}
+
+// etagMemo remembers the ETags of responses to requests for the data last modified at the same time.
+type etagMemo struct {
+	mu       sync.Mutex
+	modified time.Time
+	etags    map[string]string
+}
+
+// get returns the ETag of the response to the request with the given key, if the data has not changed since.
+func (m *etagMemo) get(key string, modified time.Time) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.modified.Equal(modified) {
+		return "", false
+	}
+	etag, ok := m.etags[key]
+
+	return etag, ok
+}
+
+// put remembers the ETag of the response to the request with the given key,
+// forgetting the ETags for older data or when the memo is full.
+func (m *etagMemo) put(key string, modified time.Time, etag string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if modified.Before(m.modified) {
+		return
+	}
+	if !modified.Equal(m.modified) || len(m.etags) >= maxMemoisedETags {
+		m.modified = modified
+		m.etags = make(map[string]string)
+	}
+	m.etags[key] = etag
+}
@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/addetz/testing-strategies-demo/data"
+	"github.com/gorilla/mux"
+)
+
+// calendarContentType is the media type of iCalendar documents (RFC 5545).
+const calendarContentType = "text/calendar; charset=utf-8"
+
+const (
+	// calendarProductID identifies the server as the producer of its calendars.
+	calendarProductID = "-//conftalks//Conference Talks//EN"
+	// calendarUIDDomain makes the UIDs of talks unique across producers.
+	calendarUIDDomain = "conftalks"
+	// calendarDateTime and calendarDate are the iCalendar formats of UTC times and dates.
+	calendarDateTime = "20060102T150405Z"
+	calendarDate     = "20060102"
+	// calendarLineLength is the maximum length of a content line in octets, excluding the line break.
+	calendarLineLength = 75
+	// defaultTalkDuration is how long talks without a duration last in calendars,
+	// which would otherwise show them as a point in time.
+	defaultTalkDuration = 30 * time.Minute
+)
+
+// GetEventCalendarHandler returns all the talks of an event as an iCalendar document,
+// which calendar apps can subscribe to.
+func (h *Handler) GetEventCalendarHandler(w http.ResponseWriter, r *http.Request) {
+	// the service is read once, so that a reload during the request cannot mix the data of two services
+	es := h.service()
+	// read before the talks, so that the stamp is never later than the data it describes
+	modified := es.LastModified()
+	event, err := es.GetEvent(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeCalendar(w, r, *event, event.Talks, modified)
+}
+
+// GetEventTalksCalendarHandler returns the talks of an event as an iCalendar document,
+// selected by the same query parameters as GetEventTalksHandler.
+// Talks can also be picked by ID with one or more talk parameters, for a personal agenda.
+func (h *Handler) GetEventTalksCalendarHandler(w http.ResponseWriter, r *http.Request) {
+	h.writeTalksCalendar(w, r, legacyDateFormat)
+}
+
+// writeTalksCalendar writes the talks selected by the request as an iCalendar document,
+// parsing the date parameter with the given layout.
+func (h *Handler) writeTalksCalendar(w http.ResponseWriter, r *http.Request, dateLayout string) {
+	es := h.service()
+	modified := es.LastModified()
+	eventID := mux.Vars(r)["id"]
+	event, err := es.GetEvent(eventID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	talks, err := eventTalks(es, r, dateLayout)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	selected := talks.Talks
+	if ids := r.URL.Query()["talk"]; len(ids) > 0 {
+		picked := make(map[string]bool, len(ids))
+		for _, id := range ids {
+			// unknown talks are reported rather than silently left out of the agenda
+			if _, err := es.GetTalk(eventID, id); err != nil {
+				writeError(w, r, err)
+				return
+			}
+			picked[id] = true
+		}
+		selected = nil
+		for _, t := range talks.Talks {
+			if picked[t.ID] {
+				selected = append(selected, t)
+			}
+		}
+	}
+	writeCalendar(w, r, *event, selected, modified)
+}
+
+// writeCalendar writes the talks of event e as an iCalendar document with one VEVENT per talk,
+// stamped with when the data was last modified so that the same data always gives the same document.
+func writeCalendar(w http.ResponseWriter, r *http.Request, e data.Event, talks []data.Talk, modified time.Time) {
+	if modified.IsZero() {
+		modified = time.Now()
+	}
+	var b bytes.Buffer
+	if err := encodeCalendar(&b, e, talks, modified); err != nil {
+		writeError(w, r, fmt.Errorf("encode calendar %s: %w", e.ID, err))
+		return
+	}
+	w.Header().Set("Content-Type", calendarContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", e.ID+".ics"))
+	w.Write(b.Bytes())
+}
+
+// encodeCalendar writes the talks of event e to out as an iCalendar document, stamped with the given time.
+// Times are written in UTC, so that they are correct in calendar apps without the event's time zone definition,
+// and the time zone of the event is named for apps to display the calendar in.
+func encodeCalendar(out io.Writer, e data.Event, talks []data.Talk, stamp time.Time) error {
+	cw := &calendarWriter{w: out}
+	cw.line("BEGIN", "VCALENDAR")
+	cw.line("VERSION", "2.0")
+	cw.line("PRODID", calendarProductID)
+	cw.line("CALSCALE", "GREGORIAN")
+	cw.line("METHOD", "PUBLISH")
+	cw.line("X-WR-CALNAME", escapeText(calendarName(e)))
+	if e.TimeZone != "" {
+		cw.line("X-WR-TIMEZONE", e.TimeZone)
+	}
+	for _, t := range talks {
+		cw.line("BEGIN", "VEVENT")
+		// the UID depends on the IDs of the talk and event, so updated talks replace the old entries,
+		// as long as the talk's ID is given in the data files rather than derived from its title, date and time
+		cw.line("UID", escapeText(t.ID+"."+e.ID+"@"+calendarUIDDomain))
+		cw.line("DTSTAMP", stamp.UTC().Format(calendarDateTime))
+		if t.Time.IsZero() {
+			// a talk without a time lasts all day
+			cw.line("DTSTART;VALUE=DATE", t.Date.Format(calendarDate))
+			cw.line("DTEND;VALUE=DATE", t.Date.AddDate(0, 0, 1).Format(calendarDate))
+		} else {
+			cw.line("DTSTART", t.Time.UTC().Format(calendarDateTime))
+			duration := defaultTalkDuration
+			if t.Duration > 0 {
+				duration = time.Duration(t.Duration) * time.Minute
+			}
+			cw.line("DTEND", t.Time.Add(duration).UTC().Format(calendarDateTime))
+		}
+		cw.line("SUMMARY", escapeText(t.Title))
+		if location := talkLocation(e, t); location != "" {
+			cw.line("LOCATION", escapeText(location))
+		}
+		if description := talkDescription(t); description != "" {
+			cw.line("DESCRIPTION", escapeText(description))
+		}
+		if t.Track != "" {
+			cw.line("CATEGORIES", escapeText(t.Track))
+		}
+		cw.line("END", "VEVENT")
+	}
+	cw.line("END", "VCALENDAR")
+
+	return cw.err
+}
+
+func calendarName(e data.Event) string {
+	if e.Name != "" {
+		return e.Name
+	}
+
+	return e.ID
+}
+
+// talkLocation returns the room of the talk followed by the location of its event.
+func talkLocation(e data.Event, t data.Talk) string {
+	var parts []string
+	for _, part := range []string{t.Room, e.Location} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// talkDescription lists the speakers and the track of the talk, one per line.
+func talkDescription(t data.Talk) string {
+	var lines []string
+	if len(t.Speakers) > 0 {
+		lines = append(lines, "Speakers: "+strings.Join(t.Speakers, ", "))
+	}
+	if t.Track != "" {
+		lines = append(lines, "Track: "+t.Track)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// textEscaper escapes the characters with a special meaning in iCalendar TEXT values.
+var textEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+// escapeText escapes a value of the iCalendar TEXT type.
+func escapeText(s string) string {
+	return textEscaper.Replace(s)
+}
+
+// calendarWriter writes iCalendar content lines, folding them at calendarLineLength octets.
+// It keeps the first error, after which writes do nothing.
+type calendarWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (cw *calendarWriter) line(name, value string) {
+	if cw.err != nil {
+		return
+	}
+	_, cw.err = io.WriteString(cw.w, foldLine(name+":"+value)+"\r\n")
+}
+
+// foldLine splits a content line longer than calendarLineLength octets into lines joined by a line break
+// followed by a space, without splitting UTF-8 characters.
+func foldLine(line string) string {
+	var b strings.Builder
+	limit := calendarLineLength
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		// continuation lines start with a space, which counts towards their length
+		limit = calendarLineLength - 1
+	}
+	b.WriteString(line)
+
+	return b.String()
+}
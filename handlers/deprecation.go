@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Deprecated returns a middleware which marks responses as coming from a deprecated API version.
+// It sets the Deprecation header of RFC 9745, the Sunset header of RFC 8594
+// and a Link to the successor version, so that clients can find out when and where to migrate.
+func Deprecated(deprecatedAt, sunset time.Time, successor string) mux.MiddlewareFunc {
+	deprecation := fmt.Sprintf("@%d", deprecatedAt.Unix())
+	sunsetDate := sunset.UTC().Format(http.TimeFormat)
+	link := fmt.Sprintf("<%s>; rel=\"successor-version\"", successor)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Deprecation", deprecation)
+			w.Header().Set("Sunset", sunsetDate)
+			w.Header().Set("Link", link)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
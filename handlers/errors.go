@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/addetz/testing-strategies-demo/data"
+)
+
+const problemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details response.
+// Code and InvalidParams are extension members.
+type Problem struct {
+	Type          string         `json:"type"`
+	Title         string         `json:"title"`
+	Status        int            `json:"status"`
+	Detail        string         `json:"detail,omitempty"`
+	Instance      string         `json:"instance,omitempty"`
+	Code          string         `json:"code"`
+	InvalidParams []InvalidParam `json:"invalid_params,omitempty"`
+}
+
+// InvalidParam describes a request parameter or body field which was rejected.
+type InvalidParam struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// Error codes returned in Problem, so that clients do not need to parse error messages.
+// The problem type of each code is ProblemTypeBase followed by the code.
+const (
+	CodeInvalidRequest    = "invalid_request"
+	CodeEventNotFound     = "event_not_found"
+	CodeTalkNotFound      = "talk_not_found"
+	CodeSpeakerNotFound   = "speaker_not_found"
+	CodeEventExists       = "event_exists"
+	CodeTalkExists        = "talk_exists"
+	CodeInvalidEvent      = "invalid_event"
+	CodeInvalidEventDates = "invalid_event_dates"
+	CodeInvalidTalk       = "invalid_talk"
+	CodeInvalidTalkDate   = "invalid_talk_date"
+	CodeInvalidSpeaker    = "invalid_speaker"
+	CodeInvalidImport     = "invalid_import"
+	CodeInvalidArchive    = "invalid_archive"
+	CodeDayOutOfRange     = "day_out_of_range"
+	CodeNotFound          = "not_found"
+	CodeMethodNotAllowed  = "method_not_allowed"
+	CodeNotAcceptable     = "not_acceptable"
+	CodeBodyTooLarge      = "body_too_large"
+	CodeUnauthorized      = "unauthorized"
+	CodeInternalError     = "internal_error"
+)
+
+const ProblemTypeBase = "/problems/"
+
+var problemTitles = map[string]string{
+	CodeInvalidRequest:    "Invalid request",
+	CodeEventNotFound:     "Event not found",
+	CodeTalkNotFound:      "Talk not found",
+	CodeSpeakerNotFound:   "Speaker not found",
+	CodeEventExists:       "Event already exists",
+	CodeTalkExists:        "Talk already exists",
+	CodeInvalidEvent:      "Invalid event",
+	CodeInvalidEventDates: "Invalid event dates",
+	CodeInvalidTalk:       "Invalid talk",
+	CodeInvalidTalkDate:   "Invalid talk date",
+	CodeInvalidSpeaker:    "Invalid speaker",
+	CodeInvalidImport:     "Invalid import",
+	CodeInvalidArchive:    "Invalid archive",
+	CodeDayOutOfRange:     "Day out of range",
+	CodeNotFound:          "Not found",
+	CodeMethodNotAllowed:  "Method not allowed",
+	CodeNotAcceptable:     "Not acceptable",
+	CodeBodyTooLarge:      "Request body too large",
+	CodeUnauthorized:      "Unauthorized",
+	CodeInternalError:     "Internal server error",
+}
+
+// errorMappings maps the errors of the data package to HTTP statuses and error codes.
+var errorMappings = []struct {
+	err    error
+	status int
+	code   string
+}{
+	{data.ErrEventNotFound, http.StatusNotFound, CodeEventNotFound},
+	{data.ErrTalkNotFound, http.StatusNotFound, CodeTalkNotFound},
+	{data.ErrSpeakerNotFound, http.StatusNotFound, CodeSpeakerNotFound},
+	{data.ErrEventExists, http.StatusConflict, CodeEventExists},
+	{data.ErrTalkExists, http.StatusConflict, CodeTalkExists},
+	{data.ErrEmptyEventID, http.StatusBadRequest, CodeInvalidEvent},
+	{data.ErrInvalidTimeZone, http.StatusBadRequest, CodeInvalidEvent},
+	{data.ErrInvalidEventDates, http.StatusBadRequest, CodeInvalidEventDates},
+	{data.ErrEmptyTalkTitle, http.StatusBadRequest, CodeInvalidTalk},
+	{data.ErrInvalidTalkDate, http.StatusBadRequest, CodeInvalidTalkDate},
+	{data.ErrInvalidTalkDuration, http.StatusBadRequest, CodeInvalidTalk},
+	{data.ErrInvalidSpeaker, http.StatusBadRequest, CodeInvalidSpeaker},
+	{data.ErrInvalidImport, http.StatusBadRequest, CodeInvalidImport},
+	{data.ErrInvalidArchive, http.StatusBadRequest, CodeInvalidArchive},
+	{data.ErrDayOutOfRange, http.StatusBadRequest, CodeDayOutOfRange},
+	{data.ErrInvalidSort, http.StatusBadRequest, CodeInvalidRequest},
+	{data.ErrInvalidCursor, http.StatusBadRequest, CodeInvalidRequest},
+	{data.ErrInvalidTalkQuery, http.StatusBadRequest, CodeInvalidRequest},
+}
+
+// requestError is an error caused by a malformed request,
+// such as a body or parameter which cannot be parsed.
+type requestError struct {
+	detail string
+	params []InvalidParam
+	err    error
+}
+
+func (e *requestError) Error() string {
+	return e.detail
+}
+
+func (e *requestError) Unwrap() error {
+	return e.err
+}
+
+// invalidBody returns a requestError for a request body which cannot be decoded.
+func invalidBody(err error) error {
+	return &requestError{
+		detail: fmt.Sprintf("request body is not valid: %v", err),
+		err:    err,
+	}
+}
+
+// invalidParam returns a requestError for a query or path parameter which was rejected.
+func invalidParam(name, reason string) error {
+	return &requestError{
+		detail: fmt.Sprintf("invalid parameter %s", name),
+		params: []InvalidParam{{Name: name, Reason: reason}},
+	}
+}
+
+// invalidParamError attributes an error of the data package to a request parameter.
+type invalidParamError struct {
+	name string
+	err  error
+}
+
+func (e *invalidParamError) Error() string {
+	return e.err.Error()
+}
+
+func (e *invalidParamError) Unwrap() error {
+	return e.err
+}
+
+// paramError attributes err to the request parameter with the given name,
+// so that it is listed in the invalid_params of the Problem.
+func paramError(name string, err error) error {
+	return &invalidParamError{name: name, err: err}
+}
+
+// newProblem returns the Problem describing err for the request r.
+// Errors which are not known to be caused by the request are internal errors,
+// whose details are logged rather than returned.
+func newProblem(r *http.Request, err error) *Problem {
+	// checked first, since request and data errors wrap the errors of reading a request body
+	var sizeErr *http.MaxBytesError
+	if errors.As(err, &sizeErr) {
+		return problem(r, http.StatusRequestEntityTooLarge, CodeBodyTooLarge,
+			fmt.Sprintf("request body is larger than %d bytes", sizeErr.Limit))
+	}
+	var reqErr *requestError
+	if errors.As(err, &reqErr) {
+		p := problem(r, http.StatusBadRequest, CodeInvalidRequest, reqErr.detail)
+		p.InvalidParams = reqErr.params
+		return p
+	}
+	for _, m := range errorMappings {
+		if errors.Is(err, m.err) {
+			p := problem(r, m.status, m.code, err.Error())
+			var paramErr *invalidParamError
+			if errors.As(err, &paramErr) {
+				p.InvalidParams = []InvalidParam{{Name: paramErr.name, Reason: paramErr.err.Error()}}
+			}
+			return p
+		}
+	}
+	log.Printf("%s %s: %v\n", r.Method, r.URL.Path, err)
+
+	return problem(r, http.StatusInternalServerError, CodeInternalError, "")
+}
+
+// problem returns a Problem for the request r with the given status, code and detail.
+func problem(r *http.Request, status int, code, detail string) *Problem {
+	return &Problem{
+		Type:     ProblemTypeBase + strings.ReplaceAll(code, "_", "-"),
+		Title:    problemTitles[code],
+		Status:   status,
+		Detail:   detail,
+		Instance: r.URL.RequestURI(),
+		Code:     code,
+	}
+}
+
+// writeError writes err as a Problem with the status and code matching it.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	p := newProblem(r, err)
+	writeResponse[Problem](w, r, p.Status, p)
+}
+
+// NotFoundHandler writes a Problem for requests which match no route.
+func NotFoundHandler(w http.ResponseWriter, r *http.Request) {
+	p := problem(r, http.StatusNotFound, CodeNotFound, fmt.Sprintf("no route for %s", r.URL.Path))
+	writeResponse[Problem](w, r, p.Status, p)
+}
+
+// MethodNotAllowedHandler writes a Problem for requests which match a route but not its methods.
+func MethodNotAllowedHandler(w http.ResponseWriter, r *http.Request) {
+	p := problem(r, http.StatusMethodNotAllowed, CodeMethodNotAllowed, fmt.Sprintf("method %s is not allowed for %s", r.Method, r.URL.Path))
+	writeResponse[Problem](w, r, p.Status, p)
+}
@@ -0,0 +1,440 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/addetz/testing-strategies-demo/data"
+	"gopkg.in/yaml.v3"
+)
+
+// format is a representation responses can be written in.
+type format struct {
+	name        string
+	contentType string
+	// mediaTypes are the media types which select the format in an Accept header, the preferred one first.
+	mediaTypes []string
+	encode     func(w io.Writer, resp any) error
+	// supports reports whether the response can be written in the format, or nil if every response can.
+	supports func(resp any) bool
+}
+
+// formats are the representations of responses, in order of preference when a client accepts several equally.
+var formats = []format{
+	{
+		name:        "json",
+		contentType: "application/json; charset=UTF-8",
+		mediaTypes:  []string{"application/json"},
+		encode:      encodeJSON,
+	},
+	{
+		name:        "csv",
+		contentType: "text/csv; charset=utf-8",
+		mediaTypes:  []string{"text/csv"},
+		encode:      encodeCSV,
+		supports: func(resp any) bool {
+			_, ok := resp.(tabular)
+			return ok
+		},
+	},
+	{
+		name:        "yaml",
+		contentType: "application/yaml; charset=utf-8",
+		mediaTypes:  []string{"application/yaml", "application/x-yaml", "text/yaml"},
+		encode:      encodeYAML,
+	},
+	{
+		name:        "xml",
+		contentType: "application/xml; charset=utf-8",
+		mediaTypes:  []string{"application/xml", "text/xml"},
+		encode:      encodeXML,
+	},
+	{
+		name:        "tar.gz",
+		contentType: "application/gzip",
+		mediaTypes:  []string{"application/gzip", "application/x-gzip"},
+		encode: func(w io.Writer, resp any) error {
+			return resp.(*data.Archive).WriteTarGz(w)
+		},
+		supports: func(resp any) bool {
+			_, ok := resp.(*data.Archive)
+			return ok
+		},
+	},
+}
+
+// negotiate returns the format to write resp in for the request r.
+// The format query parameter takes precedence over the Accept header,
+// and JSON is used if the request has neither.
+// It returns an error if no format the client accepts can represent resp.
+func negotiate(r *http.Request, resp any) (format, error) {
+	if name := r.URL.Query().Get("format"); name != "" {
+		for _, f := range formats {
+			if f.name == strings.ToLower(name) && f.supported(resp) {
+				return f, nil
+			}
+		}
+		return format{}, fmt.Errorf("format %q is not available for this resource, use one of %s", name, strings.Join(formatNames(resp), ", "))
+	}
+	accept := r.Header.Values("Accept")
+	if len(accept) == 0 {
+		return formats[0], nil
+	}
+	ranges := parseAccept(strings.Join(accept, ","))
+	best, bestQuality := -1, 0.0
+	for i, f := range formats {
+		if !f.supported(resp) {
+			continue
+		}
+		// formats earlier in the list win ties, so JSON is chosen for */*
+		if q := f.quality(ranges); q > bestQuality {
+			best, bestQuality = i, q
+		}
+	}
+	if best < 0 {
+		return format{}, fmt.Errorf("none of the accepted media types are available for this resource, use one of %s", strings.Join(mediaTypes(resp), ", "))
+	}
+
+	return formats[best], nil
+}
+
+func (f format) supported(resp any) bool {
+	return f.supports == nil || f.supports(resp)
+}
+
+// quality returns the quality the client gives to the format, or 0 if it does not accept it.
+// The most specific media range matching one of the format's media types applies.
+func (f format) quality(ranges []mediaRange) float64 {
+	quality, specificity := 0.0, -1
+	for _, mediaType := range f.mediaTypes {
+		for _, mr := range ranges {
+			if s := mr.match(mediaType); s > specificity {
+				quality, specificity = mr.quality, s
+			}
+		}
+	}
+
+	return quality
+}
+
+// formatNames returns the names of the formats resp can be written in.
+func formatNames(resp any) []string {
+	var names []string
+	for _, f := range formats {
+		if f.supported(resp) {
+			names = append(names, f.name)
+		}
+	}
+
+	return names
+}
+
+// mediaTypes returns the preferred media types of the formats resp can be written in.
+func mediaTypes(resp any) []string {
+	var types []string
+	for _, f := range formats {
+		if f.supported(resp) {
+			types = append(types, f.mediaTypes[0])
+		}
+	}
+
+	return types
+}
+
+// mediaRange is a media range of an Accept header, such as text/* or application/json;q=0.5.
+type mediaRange struct {
+	mainType, subType string
+	quality           float64
+}
+
+// parseAccept parses the media ranges of an Accept header, skipping malformed ones.
+// Media ranges without a valid q parameter have a quality of 1.
+func parseAccept(header string) []mediaRange {
+	var ranges []mediaRange
+	for _, part := range strings.Split(header, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		mainType, subType, ok := strings.Cut(mediaType, "/")
+		if !ok {
+			continue
+		}
+		mr := mediaRange{mainType: mainType, subType: subType, quality: 1}
+		if q, err := strconv.ParseFloat(params["q"], 64); err == nil && q >= 0 && q <= 1 {
+			mr.quality = q
+		}
+		ranges = append(ranges, mr)
+	}
+
+	return ranges
+}
+
+// match returns how specifically the media range matches mediaType,
+// from 0 for */* to 2 for an exact match, or -1 if it does not match.
+func (mr mediaRange) match(mediaType string) int {
+	mainType, subType, _ := strings.Cut(mediaType, "/")
+	switch {
+	case mr.mainType == mainType && mr.subType == subType:
+		return 2
+	case mr.mainType == mainType && mr.subType == "*":
+		return 1
+	case mr.mainType == "*" && mr.subType == "*":
+		return 0
+	default:
+		return -1
+	}
+}
+
+func encodeJSON(w io.Writer, resp any) error {
+	return json.NewEncoder(w).Encode(resp)
+}
+
+// encodeYAML writes resp as YAML with the same field names and values as its JSON.
+func encodeYAML(w io.Writer, resp any) error {
+	doc, err := jsonNode(resp)
+	if err != nil {
+		return err
+	}
+	blockStyle(doc)
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+
+	return enc.Close()
+}
+
+// jsonNode returns the JSON encoding of resp decoded as a YAML document, which JSON is a subset of.
+// Unlike decoding into maps, the nodes keep the order of the fields.
+func jsonNode(resp any) (*yaml.Node, error) {
+	b, err := json.Marshal(resp)
+	if err != nil {
+		return nil, err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+
+	return &doc, nil
+}
+
+// blockStyle clears the JSON flow style and quoting of the node and its children,
+// leaving the encoder to quote only the strings which need it.
+func blockStyle(n *yaml.Node) {
+	n.Style = 0
+	for _, child := range n.Content {
+		blockStyle(child)
+	}
+}
+
+// xmlRoot is the name of the root element of XML responses.
+const xmlRoot = "response"
+
+// encodeXML writes resp as XML with the same field names and values as its JSON.
+// Objects become elements with a child per field, and each item of an array
+// becomes a child named after the array, such as event for events.
+func encodeXML(w io.Writer, resp any) error {
+	doc, err := jsonNode(resp)
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := encodeXMLNode(enc, xmlRoot, doc.Content[0]); err != nil {
+		return err
+	}
+	if err := enc.Flush(); err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\n")
+
+	return err
+}
+
+// encodeXMLNode writes the JSON value decoded into n as an element with the given name.
+func encodeXMLNode(enc *xml.Encoder, name string, n *yaml.Node) error {
+	start := xmlStart(name)
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+	switch n.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if err := encodeXMLNode(enc, n.Content[i].Value, n.Content[i+1]); err != nil {
+				return err
+			}
+		}
+	case yaml.SequenceNode:
+		item := singular(name)
+		for _, element := range n.Content {
+			if err := encodeXMLNode(enc, item, element); err != nil {
+				return err
+			}
+		}
+	case yaml.ScalarNode:
+		// null fields are written as empty elements
+		if n.Tag != "!!null" {
+			if err := enc.EncodeToken(xml.CharData(n.Value)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return enc.EncodeToken(start.End())
+}
+
+// xmlStart returns the start of an element for a field with the given name,
+// which is given as an attribute of an entry element if it is not a valid XML name.
+func xmlStart(name string) xml.StartElement {
+	if validXMLName(name) {
+		return xml.StartElement{Name: xml.Name{Local: name}}
+	}
+
+	return xml.StartElement{
+		Name: xml.Name{Local: "entry"},
+		Attr: []xml.Attr{{Name: xml.Name{Local: "key"}, Value: name}},
+	}
+}
+
+func validXMLName(name string) bool {
+	if name == "" || strings.HasPrefix(strings.ToLower(name), "xml") {
+		return false
+	}
+	for i, r := range name {
+		if unicode.IsLetter(r) || r == '_' || (i > 0 && (unicode.IsDigit(r) || r == '-' || r == '.')) {
+			continue
+		}
+		return false
+	}
+
+	return true
+}
+
+// singular returns the name of an item of the array with the given name, such as talk for talks.
+func singular(name string) string {
+	if len(name) > 1 && strings.HasSuffix(name, "s") {
+		return name[:len(name)-1]
+	}
+
+	return "item"
+}
+
+// tabular is implemented by responses which are lists of records, so that they can be written as CSV.
+type tabular interface {
+	// records returns the slice of records.
+	records() any
+}
+
+func (e EventsV1) records() any { return e.Events }
+func (e EventsV2) records() any { return e.Events }
+func (t TalksV1) records() any  { return t.Talks }
+func (t TalksV2) records() any  { return t.Talks }
+
+// encodeCSV writes the records of resp as CSV, with a header row of their JSON field names.
+// Lists such as the speakers of a talk are joined by semicolons, and nested records are left out.
+// Cells which a spreadsheet would run as formulas are escaped.
+func encodeCSV(w io.Writer, resp any) error {
+	t, ok := resp.(tabular)
+	if !ok {
+		return fmt.Errorf("%T cannot be written as CSV", resp)
+	}
+	records := reflect.ValueOf(t.records())
+	columns := csvColumns(records.Type().Elem())
+	cw := csv.NewWriter(w)
+	header := make([]string, len(columns))
+	for i, c := range columns {
+		header[i] = c.name
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for i := 0; i < records.Len(); i++ {
+		record := records.Index(i)
+		row := make([]string, len(columns))
+		for j, c := range columns {
+			row[j] = escapeFormula(c.value(record.Field(c.index)))
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+
+	return cw.Error()
+}
+
+// csvColumn is a field of a record written as a CSV column.
+type csvColumn struct {
+	name      string
+	index     int
+	omitEmpty bool
+}
+
+// csvColumns returns the columns of the record type: its fields encoded in JSON which hold a value or a list of values.
+func csvColumns(record reflect.Type) []csvColumn {
+	var columns []csvColumn
+	for i := 0; i < record.NumField(); i++ {
+		field := record.Field(i)
+		name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if !field.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		kind := field.Type.Kind()
+		if kind == reflect.Slice {
+			kind = field.Type.Elem().Kind()
+		}
+		switch kind {
+		case reflect.String, reflect.Bool, reflect.Int, reflect.Int64, reflect.Float64:
+			columns = append(columns, csvColumn{name: name, index: i, omitEmpty: strings.Contains(opts, "omitempty")})
+		}
+	}
+
+	return columns
+}
+
+// value formats the value of the column, leaving it empty where the JSON would omit it.
+func (c csvColumn) value(v reflect.Value) string {
+	if c.omitEmpty && v.IsZero() {
+		return ""
+	}
+	if v.Kind() == reflect.Slice {
+		values := make([]string, v.Len())
+		for i := range values {
+			values[i] = fmt.Sprint(v.Index(i).Interface())
+		}
+		return strings.Join(values, "; ")
+	}
+
+	return fmt.Sprint(v.Interface())
+}
+
+// formulaPrefixes are the first characters of the cells which spreadsheets evaluate as formulas.
+const formulaPrefixes = "=+-@\t\r"
+
+// escapeFormula prefixes a cell which starts like a formula with a single quote, so that spreadsheets show it as text.
+// Titles and speaker names come from call for papers submissions, so a cell such as =HYPERLINK(...) must not run when opened.
+func escapeFormula(cell string) string {
+	if cell != "" && strings.ContainsRune(formulaPrefixes, rune(cell[0])) {
+		return "'" + cell
+	}
+
+	return cell
+}
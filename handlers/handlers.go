@@ -1,82 +1,438 @@
 package handlers
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log"
 	"net/http"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/addetz/testing-strategies-demo/data"
 	"github.com/gorilla/mux"
 )
 
 type ResponseType interface {
-	data.Events | data.Talks | ErrorResponse
+	data.SearchResults | data.Speakers | data.Speaker | data.Rooms | data.Tracks | data.Conflicts |
+		data.TalkImportReport | data.Archive | data.ArchiveImportReport |
+		EventsV1 | EventV1 | TalksV1 | TalkV1 | ScheduleV1 |
+		EventsV2 | EventV2 | TalksV2 | TalkV2 | ScheduleV2 | Problem
 }
 
-type ErrorResponse struct {
-	Error string `json:"error"`
+// EventService is the set of operations on events and talks that the handlers depend on.
+// It is implemented by *data.EventService.
+type EventService interface {
+	QueryEvents(q data.EventQuery) (data.Events, error)
+	GetEvent(id string) (*data.Event, error)
+	QueryTalks(id string, q data.TalkQuery) (*data.Talks, error)
+	CreateEvent(e data.Event) (*data.Event, error)
+	UpdateEvent(id string, e data.Event) (*data.Event, error)
+	PatchEvent(id string, patch func(*data.Event) error) (*data.Event, error)
+	DeleteEvent(id string) error
+	GetTalk(eventID, talkID string) (*data.Talk, error)
+	CreateTalk(eventID string, t data.Talk) (*data.Talk, error)
+	UpdateTalk(eventID, talkID string, t data.Talk) (*data.Talk, error)
+	DeleteTalk(eventID, talkID string) error
+	ImportTalks(r io.Reader, opts data.TalkImportOptions) (*data.TalkImportReport, error)
+	Export() (*data.Archive, error)
+	ImportArchive(a data.Archive, opts data.ArchiveImportOptions) (*data.ArchiveImportReport, error)
+	Search(q string) (data.SearchResults, error)
+	GetSpeakers() (data.Speakers, error)
+	GetSpeaker(id string) (*data.Speaker, error)
+	GetSpeakerTalks(id string) (*data.Talks, error)
+	GetEventRooms(id string) (*data.Rooms, error)
+	GetEventTracks(id string) (*data.Tracks, error)
+	GetEventSchedule(id string, q data.ScheduleQuery) (*data.Schedule, error)
+	GetEventConflicts(id string) (*data.Conflicts, error)
+	LastModified() time.Time
 }
 
 type Handler struct {
-	eventService *data.EventService
+	mu           sync.RWMutex
+	eventService EventService
 }
 
-func NewHandler(es *data.EventService) *Handler {
+func NewHandler(es EventService) *Handler {
 	return &Handler{
 		eventService: es,
 	}
 }
 
+// SetEventService atomically replaces the service used by the handler.
+// Requests already in flight finish with the service they started with.
+func (h *Handler) SetEventService(es EventService) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.eventService = es
+}
+
+// service returns the current event service.
+func (h *Handler) service() EventService {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.eventService
+}
+
+// GetEventsHandler returns the events selected by the limit, cursor, sort,
+// location, from and to query parameters.
 func (h *Handler) GetEventsHandler(w http.ResponseWriter, r *http.Request) {
-	events := h.eventService.GetEvents()
-	writeResponse[data.Events](w, http.StatusOK, &events)
+	events, err := h.queryEvents(r, legacyDateFormat)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	resp := toEventsV1(events)
+	writeResponse[EventsV1](w, r, http.StatusOK, &resp)
+}
+
+// queryEvents returns the events selected by the query parameters of r,
+// parsing the from and to dates with the given layout.
+func (h *Handler) queryEvents(r *http.Request, dateLayout string) (data.Events, error) {
+	params := r.URL.Query()
+	q := data.EventQuery{
+		Cursor:   params.Get("cursor"),
+		Sort:     params.Get("sort"),
+		Location: params.Get("location"),
+	}
+	if limit := params.Get("limit"); limit != "" {
+		parsed, err := strconv.Atoi(limit)
+		if err != nil || parsed < 1 {
+			return data.Events{}, invalidParam("limit", "must be a positive integer")
+		}
+		q.Limit = parsed
+	}
+	dates := []struct {
+		name string
+		date *time.Time
+	}{
+		{"from", &q.From},
+		{"to", &q.To},
+	}
+	for _, d := range dates {
+		value := params.Get(d.name)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.Parse(dateLayout, value)
+		if err != nil {
+			return data.Events{}, invalidParam(d.name, "must be a date in the format "+dateLayoutNames[dateLayout])
+		}
+		*d.date = parsed
+	}
+	events, err := h.service().QueryEvents(q)
+	switch {
+	case errors.Is(err, data.ErrInvalidSort):
+		return data.Events{}, paramError("sort", err)
+	case errors.Is(err, data.ErrInvalidCursor):
+		return data.Events{}, paramError("cursor", err)
+	}
+
+	return events, err
 }
 
+// GetEventTalksHandler returns the talks of an event selected by the day, date,
+// from_time, to_time, speaker and q query parameters.
 func (h *Handler) GetEventTalksHandler(w http.ResponseWriter, r *http.Request) {
+	talks, err := eventTalks(h.service(), r, legacyDateFormat)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	resp := toTalksV1(talks.Talks)
+	writeResponse[TalksV1](w, r, http.StatusOK, &resp)
+}
+
+// eventTalks returns the talks of the event in the request path which match its query parameters,
+// parsing the date with the given layout.
+func eventTalks(es EventService, r *http.Request, dateLayout string) (*data.Talks, error) {
+	eventID := mux.Vars(r)["id"]
+	params := r.URL.Query()
+	q := data.TalkQuery{
+		Speaker: params.Get("speaker"),
+		Title:   params.Get("q"),
+	}
+	if day := params.Get("day"); day != "" {
+		parsed, err := strconv.Atoi(day)
+		if err != nil {
+			return nil, invalidParam("day", "must be an integer")
+		}
+		// a zero Day selects all days, so it is rejected here rather than by the service
+		if parsed < 1 {
+			return nil, paramError("day", fmt.Errorf("%w: day must be > 1, but was %d", data.ErrDayOutOfRange, parsed))
+		}
+		q.Day = parsed
+	}
+	if date := params.Get("date"); date != "" {
+		parsed, err := time.Parse(dateLayout, date)
+		if err != nil {
+			return nil, invalidParam("date", "must be a date in the format "+dateLayoutNames[dateLayout])
+		}
+		q.Date = parsed
+	}
+	q.FromTime, q.ToTime = params.Get("from_time"), params.Get("to_time")
+	for _, name := range []string{"from_time", "to_time"} {
+		if value := params.Get(name); value != "" {
+			if _, err := time.Parse(timeLayout, value); err != nil {
+				return nil, invalidParam(name, "must be a time in the format HH:MM")
+			}
+		}
+	}
+	talks, err := es.QueryTalks(eventID, q)
+	if errors.Is(err, data.ErrDayOutOfRange) {
+		return nil, paramError("day", err)
+	}
+
+	return talks, err
+}
+
+func (h *Handler) CreateEventHandler(w http.ResponseWriter, r *http.Request) {
+	var event data.Event
+	if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+		writeError(w, r, invalidBody(err))
+		return
+	}
+	created, err := h.service().CreateEvent(event)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	resp := toEventV1(*created)
+	writeResponse[EventV1](w, r, http.StatusCreated, &resp)
+}
+
+func (h *Handler) UpdateEventHandler(w http.ResponseWriter, r *http.Request) {
+	eventID := mux.Vars(r)["id"]
+	var event data.Event
+	if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+		writeError(w, r, invalidBody(err))
+		return
+	}
+	updated, err := h.service().UpdateEvent(eventID, event)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	resp := toEventV1(*updated)
+	writeResponse[EventV1](w, r, http.StatusOK, &resp)
+}
+
+// PatchEventHandler applies the fields present in the request body
+// on top of the existing event, leaving all other fields unchanged.
+// The body is read before the patch is applied, so that the event is only locked while it is decoded.
+func (h *Handler) PatchEventHandler(w http.ResponseWriter, r *http.Request) {
+	eventID := mux.Vars(r)["id"]
+	patch, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, r, invalidBody(err))
+		return
+	}
+	updated, err := h.service().PatchEvent(eventID, func(event *data.Event) error {
+		if err := json.NewDecoder(bytes.NewReader(patch)).Decode(event); err != nil {
+			return invalidBody(err)
+		}
+		return nil
+	})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	resp := toEventV1(*updated)
+	writeResponse[EventV1](w, r, http.StatusOK, &resp)
+}
+
+func (h *Handler) DeleteEventHandler(w http.ResponseWriter, r *http.Request) {
+	eventID := mux.Vars(r)["id"]
+	if err := h.service().DeleteEvent(eventID); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) CreateTalkHandler(w http.ResponseWriter, r *http.Request) {
 	eventID := mux.Vars(r)["id"]
-	day := r.URL.Query().Get("day")
-	var talks *data.Talks
-	if len(day) != 0 {
-		h.fetchFilteredEvents(w, eventID, day)
+	var talk data.Talk
+	if err := json.NewDecoder(r.Body).Decode(&talk); err != nil {
+		writeError(w, r, invalidBody(err))
 		return
 	}
-	talks, err := h.eventService.GetEventTalks(eventID)
+	created, err := h.service().CreateTalk(eventID, talk)
 	if err != nil {
-		writeResponse[ErrorResponse](w, http.StatusBadRequest, &ErrorResponse{
-			Error: fmt.Errorf("GetEventTalksHandler:%v", err).Error(),
-		})
+		writeError(w, r, err)
 		return
 	}
-	writeResponse[data.Talks](w, http.StatusOK, talks)
+	resp := toTalkV1(*created)
+	writeResponse[TalkV1](w, r, http.StatusCreated, &resp)
 }
 
-func (h *Handler) fetchFilteredEvents(w http.ResponseWriter, eventID, day string) {
-	parsedDay, err := strconv.Atoi(day)
+func (h *Handler) GetTalkHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	talk, err := h.service().GetTalk(vars["id"], vars["talkID"])
 	if err != nil {
-		writeResponse[ErrorResponse](w, http.StatusBadRequest, &ErrorResponse{
-			Error: fmt.Errorf("GetEventTalksHandler:%v", err).Error(),
-		})
+		writeError(w, r, err)
+		return
+	}
+	resp := toTalkV1(*talk)
+	writeResponse[TalkV1](w, r, http.StatusOK, &resp)
+}
+
+func (h *Handler) UpdateTalkHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	var talk data.Talk
+	if err := json.NewDecoder(r.Body).Decode(&talk); err != nil {
+		writeError(w, r, invalidBody(err))
 		return
 	}
-	talks, err := h.eventService.GetEventFilteredTalks(eventID, parsedDay)
+	updated, err := h.service().UpdateTalk(vars["id"], vars["talkID"], talk)
 	if err != nil {
-		writeResponse[ErrorResponse](w, http.StatusBadRequest, &ErrorResponse{
-			Error: fmt.Errorf("GetEventTalksHandler:%v", err).Error(),
-		})
+		writeError(w, r, err)
 		return
 	}
-	writeResponse[data.Talks](w, http.StatusOK, talks)
+	resp := toTalkV1(*updated)
+	writeResponse[TalkV1](w, r, http.StatusOK, &resp)
 }
 
-// writeResponse is a helper method that allows to write the HTTP status & response
-func writeResponse[T ResponseType](w http.ResponseWriter, status int, resp *T) {
-	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+func (h *Handler) DeleteTalkHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if err := h.service().DeleteTalk(vars["id"], vars["talkID"]); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SearchHandler returns the events, talks and speakers matching the q query parameter.
+func (h *Handler) SearchHandler(w http.ResponseWriter, r *http.Request) {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		writeError(w, r, invalidParam("q", "must not be empty"))
+		return
+	}
+	results, err := h.service().Search(q)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeResponse[data.SearchResults](w, r, http.StatusOK, &results)
+}
+
+func (h *Handler) GetSpeakersHandler(w http.ResponseWriter, r *http.Request) {
+	speakers, err := h.service().GetSpeakers()
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeResponse[data.Speakers](w, r, http.StatusOK, &speakers)
+}
+
+func (h *Handler) GetSpeakerHandler(w http.ResponseWriter, r *http.Request) {
+	speaker, err := h.service().GetSpeaker(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeResponse[data.Speaker](w, r, http.StatusOK, speaker)
+}
+
+// GetSpeakerTalksHandler returns the talks of a speaker across all events.
+func (h *Handler) GetSpeakerTalksHandler(w http.ResponseWriter, r *http.Request) {
+	talks, err := h.service().GetSpeakerTalks(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	resp := toTalksV1(talks.Talks)
+	writeResponse[TalksV1](w, r, http.StatusOK, &resp)
+}
+
+func (h *Handler) GetEventRoomsHandler(w http.ResponseWriter, r *http.Request) {
+	rooms, err := h.service().GetEventRooms(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeResponse[data.Rooms](w, r, http.StatusOK, rooms)
+}
+
+func (h *Handler) GetEventTracksHandler(w http.ResponseWriter, r *http.Request) {
+	tracks, err := h.service().GetEventTracks(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeResponse[data.Tracks](w, r, http.StatusOK, tracks)
+}
+
+// GetEventScheduleHandler returns the talks of an event grouped by start time,
+// selected by the room and track query parameters.
+func (h *Handler) GetEventScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	schedule, err := h.eventSchedule(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	resp := toScheduleV1(*schedule)
+	writeResponse[ScheduleV1](w, r, http.StatusOK, &resp)
+}
+
+// eventSchedule returns the schedule of the event in the request path selected by its query parameters.
+func (h *Handler) eventSchedule(r *http.Request) (*data.Schedule, error) {
+	params := r.URL.Query()
+	return h.service().GetEventSchedule(mux.Vars(r)["id"], data.ScheduleQuery{
+		Room:  params.Get("room"),
+		Track: params.Get("track"),
+	})
+}
+
+// GetEventConflictsHandler returns the schedule conflicts of an event:
+// talks overlapping in the same room, double-booked speakers and talks outside the event's dates.
+func (h *Handler) GetEventConflictsHandler(w http.ResponseWriter, r *http.Request) {
+	conflicts, err := h.service().GetEventConflicts(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeResponse[data.Conflicts](w, r, http.StatusOK, conflicts)
+}
+
+// writeResponse writes resp with the given status, in the format negotiated for the request r.
+// Problems are always written as JSON, and a Problem with status 406 is written if no format the client accepts is available,
+// or one with status 500 if resp cannot be encoded.
+func writeResponse[T ResponseType](w http.ResponseWriter, r *http.Request, status int, resp *T) {
+	f := formats[0]
+	_, isProblem := any(resp).(*Problem)
+	if isProblem {
+		f.contentType = problemContentType
+	} else {
+		w.Header().Add("Vary", "Accept")
+		negotiated, err := negotiate(r, resp)
+		if err != nil {
+			p := problem(r, http.StatusNotAcceptable, CodeNotAcceptable, err.Error())
+			writeResponse[Problem](w, r, p.Status, p)
+			return
+		}
+		f = negotiated
+	}
+	var b bytes.Buffer
+	if err := f.encode(&b, resp); err != nil {
+		// nothing has been written yet, so the partly encoded body is replaced by a problem
+		err = fmt.Errorf("encode %s response: %w", f.name, err)
+		if isProblem {
+			log.Printf("%s %s: %v\n", r.Method, r.URL.Path, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", f.contentType)
 	if status != http.StatusOK {
 		w.WriteHeader(status)
 	}
-	if err := json.NewEncoder(w).Encode(resp); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		fmt.Fprintf(w, "error encoding resp %v:%s", resp, err)
-	}
+	w.Write(b.Bytes())
 }
@@ -1,13 +1,20 @@
 package handlers_test
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/addetz/testing-strategies-demo/data"
 	"github.com/addetz/testing-strategies-demo/handlers"
@@ -59,6 +66,114 @@ func TestGetEventsIntegration(t *testing.T) {
 	}
 }
 
+func TestQueryEventsIntegration(t *testing.T) {
+	if os.Getenv("INTEGRATION") == "" {
+		t.Skip("Skipping TestQueryEventsIntegration in short mode.")
+	}
+	events := []data.Event{
+		{ID: "event-1", Name: "Event 1", DateStart: date("01/02/2023"), DateEnd: date("02/02/2023"), Location: "Amsterdam"},
+		{ID: "event-2", Name: "Event 2", DateStart: date("01/03/2023"), DateEnd: date("02/03/2023"), Location: "Barcelona"},
+		{ID: "event-3", Name: "Event 3", DateStart: date("01/04/2023"), DateEnd: date("02/04/2023"), Location: "Amsterdam"},
+	}
+	es, err := data.NewEventService(events, []data.Talk{})
+	require.Nil(t, err)
+
+	// Arrange
+	ha := handlers.NewHandler(es)
+
+	testCases := map[string]struct {
+		handler            http.HandlerFunc
+		query              string
+		expectedIDs        []string
+		expectedNext       bool
+		expectedParam      string
+		expectedStatusCode int
+	}{
+		"limit": {
+			handler:            ha.GetEventsHandler,
+			query:              "?limit=2",
+			expectedIDs:        []string{"event-1", "event-2"},
+			expectedNext:       true,
+			expectedStatusCode: http.StatusOK,
+		},
+		"sort and location": {
+			handler:            ha.GetEventsHandler,
+			query:              "?sort=-date_start&location=amsterdam",
+			expectedIDs:        []string{"event-3", "event-1"},
+			expectedStatusCode: http.StatusOK,
+		},
+		"from and to": {
+			handler:            ha.GetEventsHandler,
+			query:              "?from=02/03/2023&to=01/04/2023",
+			expectedIDs:        []string{"event-2", "event-3"},
+			expectedStatusCode: http.StatusOK,
+		},
+		"v2 from": {
+			handler:            ha.V2().GetEventsHandler,
+			query:              "?from=2023-03-03",
+			expectedIDs:        []string{"event-3"},
+			expectedStatusCode: http.StatusOK,
+		},
+		"invalid limit": {
+			handler:            ha.GetEventsHandler,
+			query:              "?limit=0",
+			expectedParam:      "limit",
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		"invalid sort": {
+			handler:            ha.GetEventsHandler,
+			query:              "?sort=location",
+			expectedParam:      "sort",
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		"invalid cursor": {
+			handler:            ha.GetEventsHandler,
+			query:              "?cursor=abc",
+			expectedParam:      "cursor",
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		"v1 date in v2": {
+			handler:            ha.V2().GetEventsHandler,
+			query:              "?to=01/04/2023",
+			expectedParam:      "to",
+			expectedStatusCode: http.StatusBadRequest,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			tc.handler(rr, httptest.NewRequest("GET", "/events"+tc.query, nil))
+			require.Equal(t, tc.expectedStatusCode, rr.Code)
+
+			if len(tc.expectedParam) != 0 {
+				var respErr handlers.Problem
+				err := json.Unmarshal(rr.Body.Bytes(), &respErr)
+				require.Nil(t, err)
+				assert.Equal(t, handlers.CodeInvalidRequest, respErr.Code)
+				require.Len(t, respErr.InvalidParams, 1)
+				assert.Equal(t, tc.expectedParam, respErr.InvalidParams[0].Name)
+				return
+			}
+			// the v1 and v2 events differ in the case of their ID
+			var resp struct {
+				Events []struct {
+					V1ID string `json:"ID"`
+					V2ID string `json:"id"`
+				} `json:"events"`
+				NextCursor string `json:"next_cursor"`
+			}
+			err := json.Unmarshal(rr.Body.Bytes(), &resp)
+			require.Nil(t, err)
+			ids := make([]string, 0, len(resp.Events))
+			for _, e := range resp.Events {
+				ids = append(ids, e.V1ID+e.V2ID)
+			}
+			assert.Equal(t, tc.expectedIDs, ids)
+			assert.Equal(t, tc.expectedNext, resp.NextCursor != "")
+		})
+	}
+}
+
 func TestGetEventIntegration(t *testing.T) {
 	if os.Getenv("INTEGRATION") == "" {
 		t.Skip("Skipping TestGetEventIntegration in short mode.")
@@ -75,10 +190,12 @@ func TestGetEventIntegration(t *testing.T) {
 
 	talks := []data.Talk{
 		{
+			ID:      "talk-1-1",
 			EventID: eventID,
 			Title:   "event 1 talk 1",
 		},
 		{
+			ID:      "talk-1-2",
 			EventID: eventID,
 			Title:   "event 1 talk 2",
 		},
@@ -91,6 +208,7 @@ func TestGetEventIntegration(t *testing.T) {
 		eventID            string
 		expectedTalks      []data.Talk
 		expectedErr        string
+		expectedCode       string
 		expectedStatusCode int
 	}{
 		"multiple talks": {
@@ -106,7 +224,8 @@ func TestGetEventIntegration(t *testing.T) {
 		"invalid event": {
 			eventID:            "invalid-event",
 			expectedErr:        "no event for id invalid-event",
-			expectedStatusCode: http.StatusBadRequest,
+			expectedCode:       handlers.CodeEventNotFound,
+			expectedStatusCode: http.StatusNotFound,
 		},
 	}
 
@@ -125,11 +244,14 @@ func TestGetEventIntegration(t *testing.T) {
 			require.Equal(t, tc.expectedStatusCode, rr.Code)
 
 			if len(tc.expectedErr) != 0 {
-				var respErr handlers.ErrorResponse
+				var respErr handlers.Problem
 				bytes := rr.Body.Bytes()
 				err = json.Unmarshal(bytes, &respErr)
 				require.Nil(t, err)
-				assert.Contains(t, respErr.Error, tc.expectedErr)
+				assert.Equal(t, "application/problem+json", rr.Header().Get("Content-Type"))
+				assert.Contains(t, respErr.Detail, tc.expectedErr)
+				assert.Equal(t, tc.expectedCode, respErr.Code)
+				assert.Equal(t, tc.expectedStatusCode, respErr.Status)
 				return
 			}
 
@@ -152,8 +274,8 @@ func TestGetEventFilteredTalksIntegration(t *testing.T) {
 	events := []data.Event{
 		{
 			ID:        eventID,
-			DateStart: "01/02/2010",
-			DateEnd:   "02/02/2010",
+			DateStart: date("01/02/2010"),
+			DateEnd:   date("02/02/2010"),
 		},
 		{
 			ID: "event-2",
@@ -162,19 +284,22 @@ func TestGetEventFilteredTalksIntegration(t *testing.T) {
 
 	talks := []data.Talk{
 		{
+			ID:      "talk-1-1",
 			EventID: eventID,
 			Title:   "event 1 talk 1",
-			Date:    "01/02/2010",
+			Date:    date("01/02/2010"),
 		},
 		{
+			ID:      "talk-1-2",
 			EventID: eventID,
 			Title:   "event 1 talk 2",
-			Date:    "01/02/2010",
+			Date:    date("01/02/2010"),
 		},
 		{
+			ID:      "talk-1-3",
 			EventID: eventID,
 			Title:   "event 1 talk 3",
-			Date:    "02/02/2010",
+			Date:    date("02/02/2010"),
 		},
 	}
 	es, err := data.NewEventService(events, talks)
@@ -184,42 +309,51 @@ func TestGetEventFilteredTalksIntegration(t *testing.T) {
 	testCases := map[string]struct {
 		eventID            string
 		day                string
-		expectedTalks      []data.Talk
+		expectedTalks      []handlers.TalkV1
 		expectedErr        string
+		expectedCode       string
 		expectedStatusCode int
 	}{
 		"multiple talks": {
-			eventID:            eventID,
-			day:                "1",
-			expectedTalks:      talks[0:2],
+			eventID: eventID,
+			day:     "1",
+			expectedTalks: []handlers.TalkV1{
+				{ID: "talk-1-1", EventID: eventID, Title: "event 1 talk 1", Date: "01/02/2010"},
+				{ID: "talk-1-2", EventID: eventID, Title: "event 1 talk 2", Date: "01/02/2010"},
+			},
 			expectedStatusCode: http.StatusOK,
 		},
 		"single talk": {
-			eventID:            eventID,
-			day:                "2",
-			expectedTalks:      []data.Talk{talks[2]},
+			eventID: eventID,
+			day:     "2",
+			expectedTalks: []handlers.TalkV1{
+				{ID: "talk-1-3", EventID: eventID, Title: "event 1 talk 3", Date: "02/02/2010"},
+			},
 			expectedStatusCode: http.StatusOK,
 		},
 		"empty talks": {
 			eventID:            "event-2",
-			expectedTalks:      []data.Talk{},
+			expectedTalks:      nil,
 			expectedStatusCode: http.StatusOK,
 		},
 		"invalid event": {
 			eventID:            "invalid-event",
 			expectedErr:        "no event for id invalid-event",
-			expectedStatusCode: http.StatusBadRequest,
+			expectedCode:       handlers.CodeEventNotFound,
+			expectedStatusCode: http.StatusNotFound,
 		},
 		"invalid day param": {
 			eventID:            eventID,
 			day:                "adelina",
-			expectedErr:        "strconv.Atoi",
+			expectedErr:        "invalid parameter day",
+			expectedCode:       handlers.CodeInvalidRequest,
 			expectedStatusCode: http.StatusBadRequest,
 		},
 		"negative day param": {
 			eventID:            eventID,
 			day:                "-1",
 			expectedErr:        "day must be > 1, but was -1",
+			expectedCode:       handlers.CodeDayOutOfRange,
 			expectedStatusCode: http.StatusBadRequest,
 		},
 	}
@@ -239,15 +373,18 @@ func TestGetEventFilteredTalksIntegration(t *testing.T) {
 			require.Equal(t, tc.expectedStatusCode, rr.Code)
 
 			if len(tc.expectedErr) != 0 {
-				var respErr handlers.ErrorResponse
+				var respErr handlers.Problem
 				bytes := rr.Body.Bytes()
 				err = json.Unmarshal(bytes, &respErr)
 				require.Nil(t, err)
-				assert.Contains(t, respErr.Error, tc.expectedErr)
+				assert.Equal(t, "application/problem+json", rr.Header().Get("Content-Type"))
+				assert.Contains(t, respErr.Detail, tc.expectedErr)
+				assert.Equal(t, tc.expectedCode, respErr.Code)
+				assert.Equal(t, tc.expectedStatusCode, respErr.Status)
 				return
 			}
 
-			var resp data.Talks
+			var resp handlers.TalksV1
 			err = json.Unmarshal(rr.Body.Bytes(), &resp)
 			require.Nil(t, err)
 			assert.Len(t, resp.Talks, len(tc.expectedTalks))
@@ -257,3 +394,2144 @@ func TestGetEventFilteredTalksIntegration(t *testing.T) {
 		})
 	}
 }
+
+func TestQueryTalksIntegration(t *testing.T) {
+	if os.Getenv("INTEGRATION") == "" {
+		t.Skip("Skipping TestQueryTalksIntegration in short mode.")
+	}
+	eventID := "event-1"
+	events := []data.Event{
+		{ID: eventID, DateStart: date("01/02/2023"), DateEnd: date("02/02/2023")},
+	}
+	talks := []data.Talk{
+		{ID: "talk-1", EventID: eventID, Title: "Testing Go services", Speakers: []string{"Adelina Simion"}, Date: date("01/02/2023"), Time: dateTime("01/02/2023 09:30")},
+		{ID: "talk-2", EventID: eventID, Title: "Testing in production", Speakers: []string{"Grace Hopper"}, Date: date("02/02/2023"), Time: dateTime("02/02/2023 13:30")},
+		{ID: "talk-3", EventID: eventID, Title: "Closing keynote", Speakers: []string{"Grace Hopper"}, Date: date("02/02/2023"), Time: dateTime("02/02/2023 17:00")},
+	}
+	es, err := data.NewEventService(events, talks)
+	require.Nil(t, err)
+
+	// Arrange
+	ha := handlers.NewHandler(es)
+	router := mux.NewRouter()
+	router.HandleFunc("/v1/events/{id}/talks", ha.GetEventTalksHandler)
+	router.HandleFunc("/v2/events/{id}/talks", ha.V2().GetEventTalksHandler)
+
+	testCases := map[string]struct {
+		path               string
+		expectedIDs        []string
+		expectedParam      string
+		expectedStatusCode int
+	}{
+		"speaker and time window": {
+			path:               "/v1/events/event-1/talks?day=2&from_time=13:00&to_time=14:00&speaker=grace",
+			expectedIDs:        []string{"talk-2"},
+			expectedStatusCode: http.StatusOK,
+		},
+		"title": {
+			path:               "/v1/events/event-1/talks?q=testing",
+			expectedIDs:        []string{"talk-1", "talk-2"},
+			expectedStatusCode: http.StatusOK,
+		},
+		"v1 date": {
+			path:               "/v1/events/event-1/talks?date=01/02/2023",
+			expectedIDs:        []string{"talk-1"},
+			expectedStatusCode: http.StatusOK,
+		},
+		"v2 date": {
+			path:               "/v2/events/event-1/talks?date=2023-02-02&q=keynote",
+			expectedIDs:        []string{"talk-3"},
+			expectedStatusCode: http.StatusOK,
+		},
+		"invalid date": {
+			path:               "/v2/events/event-1/talks?date=02/02/2023",
+			expectedParam:      "date",
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		"invalid time": {
+			path:               "/v1/events/event-1/talks?from_time=1pm",
+			expectedParam:      "from_time",
+			expectedStatusCode: http.StatusBadRequest,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest("GET", tc.path, nil))
+			require.Equal(t, tc.expectedStatusCode, rr.Code)
+
+			if len(tc.expectedParam) != 0 {
+				var respErr handlers.Problem
+				err := json.Unmarshal(rr.Body.Bytes(), &respErr)
+				require.Nil(t, err)
+				require.Len(t, respErr.InvalidParams, 1)
+				assert.Equal(t, tc.expectedParam, respErr.InvalidParams[0].Name)
+				return
+			}
+			var resp data.Talks
+			err := json.Unmarshal(rr.Body.Bytes(), &resp)
+			require.Nil(t, err)
+			ids := make([]string, 0, len(resp.Talks))
+			for _, talk := range resp.Talks {
+				ids = append(ids, talk.ID)
+			}
+			assert.Equal(t, tc.expectedIDs, ids)
+		})
+	}
+}
+
+func TestEventCRUDIntegration(t *testing.T) {
+	if os.Getenv("INTEGRATION") == "" {
+		t.Skip("Skipping TestEventCRUDIntegration in short mode.")
+	}
+	eventID := "event-1"
+	events := []data.Event{
+		{
+			ID:        eventID,
+			Name:      "Event 1 2023",
+			DateStart: date("01/02/2010"),
+			DateEnd:   date("02/02/2010"),
+			Location:  "Amsterdam",
+		},
+	}
+	es, err := data.NewEventService(events, []data.Talk{})
+	require.Nil(t, err)
+
+	// Arrange
+	ha := handlers.NewHandler(es)
+	router := mux.NewRouter()
+	router.Methods("POST").Path("/events").HandlerFunc(ha.CreateEventHandler)
+	router.Methods("PUT").Path("/events/{id}").HandlerFunc(ha.UpdateEventHandler)
+	router.Methods("PATCH").Path("/events/{id}").HandlerFunc(ha.PatchEventHandler)
+	router.Methods("DELETE").Path("/events/{id}").HandlerFunc(ha.DeleteEventHandler)
+
+	testCases := map[string]struct {
+		method             string
+		path               string
+		body               string
+		expectedEvent      *handlers.EventV1
+		expectedErr        string
+		expectedCode       string
+		expectedStatusCode int
+	}{
+		"create event": {
+			method: "POST",
+			path:   "/events",
+			body:   `{"id":"event-2","name":"Event 2 2023","date_start":"01/03/2010","date_end":"02/03/2010"}`,
+			expectedEvent: &handlers.EventV1{
+				ID:        "event-2",
+				Name:      "Event 2 2023",
+				DateStart: "01/03/2010",
+				DateEnd:   "02/03/2010",
+			},
+			expectedStatusCode: http.StatusCreated,
+		},
+		"create invalid dates": {
+			method:             "POST",
+			path:               "/events",
+			body:               `{"id":"event-3","date_start":"2010/03/01","date_end":"02/03/2010"}`,
+			expectedErr:        "invalid date_start",
+			expectedCode:       handlers.CodeInvalidEventDates,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		"create unknown time zone": {
+			method:             "POST",
+			path:               "/events",
+			body:               `{"id":"event-3","date_start":"01/03/2010","date_end":"02/03/2010","time_zone":"Mars/Olympus_Mons"}`,
+			expectedErr:        `unknown time_zone "Mars/Olympus_Mons"`,
+			expectedCode:       handlers.CodeInvalidEvent,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		"create duplicate event": {
+			method:             "POST",
+			path:               "/events",
+			body:               `{"id":"event-1","date_start":"01/03/2010","date_end":"02/03/2010"}`,
+			expectedErr:        "event with id event-1 already exists",
+			expectedCode:       handlers.CodeEventExists,
+			expectedStatusCode: http.StatusConflict,
+		},
+		"create malformed body": {
+			method:             "POST",
+			path:               "/events",
+			body:               `{"id":`,
+			expectedErr:        "request body is not valid",
+			expectedCode:       handlers.CodeInvalidRequest,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		"update event": {
+			method: "PUT",
+			path:   "/events/event-1",
+			body:   `{"name":"Event 1 2024","date_start":"01/02/2011","date_end":"02/02/2011"}`,
+			expectedEvent: &handlers.EventV1{
+				ID:        eventID,
+				Name:      "Event 1 2024",
+				DateStart: "01/02/2011",
+				DateEnd:   "02/02/2011",
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		"patch event": {
+			method: "PATCH",
+			path:   "/events/event-1",
+			body:   `{"location":"Barcelona"}`,
+			expectedEvent: &handlers.EventV1{
+				ID:        eventID,
+				Name:      "Event 1 2024",
+				DateStart: "01/02/2011",
+				DateEnd:   "02/02/2011",
+				Location:  "Barcelona",
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		"patch invalid event": {
+			method:             "PATCH",
+			path:               "/events/invalid-event",
+			body:               `{"location":"Barcelona"}`,
+			expectedErr:        "no event for id invalid-event",
+			expectedCode:       handlers.CodeEventNotFound,
+			expectedStatusCode: http.StatusNotFound,
+		},
+		"delete event": {
+			method:             "DELETE",
+			path:               "/events/event-1",
+			expectedStatusCode: http.StatusNoContent,
+		},
+		"delete invalid event": {
+			method:             "DELETE",
+			path:               "/events/invalid-event",
+			expectedErr:        "no event for id invalid-event",
+			expectedCode:       handlers.CodeEventNotFound,
+			expectedStatusCode: http.StatusNotFound,
+		},
+	}
+
+	// run in order, as later cases depend on earlier ones
+	order := []string{
+		"create event", "create invalid dates", "create unknown time zone", "create duplicate event", "create malformed body",
+		"update event", "patch event", "patch invalid event",
+		"delete event", "delete invalid event",
+	}
+	for _, name := range order {
+		tc := testCases[name]
+		t.Run(name, func(t *testing.T) {
+			req, err := http.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			require.Nil(t, err)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			require.Equal(t, tc.expectedStatusCode, rr.Code)
+
+			if len(tc.expectedErr) != 0 {
+				var respErr handlers.Problem
+				err = json.Unmarshal(rr.Body.Bytes(), &respErr)
+				require.Nil(t, err)
+				assert.Equal(t, "application/problem+json", rr.Header().Get("Content-Type"))
+				assert.Contains(t, respErr.Detail, tc.expectedErr)
+				assert.Equal(t, tc.expectedCode, respErr.Code)
+				assert.Equal(t, tc.expectedStatusCode, respErr.Status)
+				return
+			}
+			if tc.expectedEvent == nil {
+				assert.Empty(t, rr.Body.Bytes())
+				return
+			}
+
+			var resp handlers.EventV1
+			err = json.Unmarshal(rr.Body.Bytes(), &resp)
+			require.Nil(t, err)
+			assert.Equal(t, *tc.expectedEvent, resp)
+		})
+	}
+}
+
+func TestTalkCRUDIntegration(t *testing.T) {
+	if os.Getenv("INTEGRATION") == "" {
+		t.Skip("Skipping TestTalkCRUDIntegration in short mode.")
+	}
+	eventID := "event-1"
+	events := []data.Event{
+		{
+			ID:        eventID,
+			DateStart: date("01/02/2010"),
+			DateEnd:   date("02/02/2010"),
+		},
+	}
+	talks := []data.Talk{
+		{
+			ID:      "talk-1-1",
+			EventID: eventID,
+			Title:   "event 1 talk 1",
+			Date:    date("01/02/2010"),
+		},
+	}
+	es, err := data.NewEventService(events, talks)
+	require.Nil(t, err)
+
+	// Arrange
+	ha := handlers.NewHandler(es)
+	router := mux.NewRouter()
+	router.Methods("POST").Path("/events/{id}/talks").HandlerFunc(ha.CreateTalkHandler)
+	router.Methods("GET").Path("/events/{id}/talks/{talkID}").HandlerFunc(ha.GetTalkHandler)
+	router.Methods("PUT").Path("/events/{id}/talks/{talkID}").HandlerFunc(ha.UpdateTalkHandler)
+	router.Methods("DELETE").Path("/events/{id}/talks/{talkID}").HandlerFunc(ha.DeleteTalkHandler)
+
+	testCases := []struct {
+		name               string
+		method             string
+		path               string
+		body               string
+		expectedTalk       *handlers.TalkV1
+		expectedErr        string
+		expectedCode       string
+		expectedStatusCode int
+	}{
+		{
+			name:   "get talk",
+			method: "GET",
+			path:   "/events/event-1/talks/talk-1-1",
+			expectedTalk: &handlers.TalkV1{
+				ID:      "talk-1-1",
+				EventID: eventID,
+				Title:   "event 1 talk 1",
+				Date:    "01/02/2010",
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:   "create talk",
+			method: "POST",
+			path:   "/events/event-1/talks",
+			body:   `{"id":"talk-1-2","title":"event 1 talk 2","date":"02/02/2010","time":"10:00"}`,
+			expectedTalk: &handlers.TalkV1{
+				ID:      "talk-1-2",
+				EventID: eventID,
+				Title:   "event 1 talk 2",
+				Date:    "02/02/2010",
+				Time:    "10:00",
+			},
+			expectedStatusCode: http.StatusCreated,
+		},
+		{
+			name:               "create talk outside event dates",
+			method:             "POST",
+			path:               "/events/event-1/talks",
+			body:               `{"title":"event 1 talk 3","date":"03/02/2010"}`,
+			expectedErr:        "is outside of event event-1 dates",
+			expectedCode:       handlers.CodeInvalidTalkDate,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:   "update talk",
+			method: "PUT",
+			path:   "/events/event-1/talks/talk-1-2",
+			body:   `{"title":"event 1 talk 2 updated","date":"01/02/2010"}`,
+			expectedTalk: &handlers.TalkV1{
+				ID:      "talk-1-2",
+				EventID: eventID,
+				Title:   "event 1 talk 2 updated",
+				Date:    "01/02/2010",
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "delete talk",
+			method:             "DELETE",
+			path:               "/events/event-1/talks/talk-1-2",
+			expectedStatusCode: http.StatusNoContent,
+		},
+		{
+			name:               "get deleted talk",
+			method:             "GET",
+			path:               "/events/event-1/talks/talk-1-2",
+			expectedErr:        "no talk for id talk-1-2 in event event-1",
+			expectedCode:       handlers.CodeTalkNotFound,
+			expectedStatusCode: http.StatusNotFound,
+		},
+		{
+			name:               "invalid event",
+			method:             "GET",
+			path:               "/events/invalid-event/talks/talk-1-1",
+			expectedErr:        "no event for id invalid-event",
+			expectedCode:       handlers.CodeEventNotFound,
+			expectedStatusCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			require.Nil(t, err)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			require.Equal(t, tc.expectedStatusCode, rr.Code)
+
+			if len(tc.expectedErr) != 0 {
+				var respErr handlers.Problem
+				err = json.Unmarshal(rr.Body.Bytes(), &respErr)
+				require.Nil(t, err)
+				assert.Equal(t, "application/problem+json", rr.Header().Get("Content-Type"))
+				assert.Contains(t, respErr.Detail, tc.expectedErr)
+				assert.Equal(t, tc.expectedCode, respErr.Code)
+				assert.Equal(t, tc.expectedStatusCode, respErr.Status)
+				return
+			}
+			if tc.expectedTalk == nil {
+				assert.Empty(t, rr.Body.Bytes())
+				return
+			}
+
+			var resp handlers.TalkV1
+			err = json.Unmarshal(rr.Body.Bytes(), &resp)
+			require.Nil(t, err)
+			assert.Equal(t, *tc.expectedTalk, resp)
+		})
+	}
+}
+
+// fakeEventService implements handlers.EventService with canned results.
+// Calling a method which is not overridden panics.
+type fakeEventService struct {
+	handlers.EventService
+	events  data.Events
+	talks   *data.Talks
+	archive *data.Archive
+	err     error
+}
+
+func (f *fakeEventService) QueryEvents(q data.EventQuery) (data.Events, error) {
+	return f.events, f.err
+}
+
+func (f *fakeEventService) QueryTalks(id string, q data.TalkQuery) (*data.Talks, error) {
+	return f.talks, f.err
+}
+
+func TestGetEventsHandler(t *testing.T) {
+	testCases := map[string]struct {
+		service            *fakeEventService
+		expectedErr        string
+		expectedCode       string
+		expectedStatusCode int
+	}{
+		"events": {
+			service: &fakeEventService{
+				events: data.Events{
+					Events: []data.Event{{ID: "event-1"}},
+				},
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		"repository error": {
+			service: &fakeEventService{
+				err: errors.New("disk on fire"),
+			},
+			expectedErr:        "Internal server error",
+			expectedCode:       handlers.CodeInternalError,
+			expectedStatusCode: http.StatusInternalServerError,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			ha := handlers.NewHandler(tc.service)
+			rr := httptest.NewRecorder()
+			ha.GetEventsHandler(rr, httptest.NewRequest("GET", "/events", nil))
+			require.Equal(t, tc.expectedStatusCode, rr.Code)
+
+			if len(tc.expectedErr) != 0 {
+				var respErr handlers.Problem
+				err := json.Unmarshal(rr.Body.Bytes(), &respErr)
+				require.Nil(t, err)
+				assert.Equal(t, "application/problem+json", rr.Header().Get("Content-Type"))
+				assert.Equal(t, tc.expectedErr, respErr.Title)
+				assert.NotContains(t, rr.Body.String(), "disk on fire")
+				assert.Equal(t, tc.expectedCode, respErr.Code)
+				assert.Equal(t, tc.expectedStatusCode, respErr.Status)
+				return
+			}
+			var resp data.Events
+			err := json.Unmarshal(rr.Body.Bytes(), &resp)
+			require.Nil(t, err)
+			assert.Equal(t, tc.service.events, resp)
+		})
+	}
+}
+
+func TestGetEventTalksHandler(t *testing.T) {
+	talks := &data.Talks{
+		Talks: []data.Talk{{ID: "talk-1-1", EventID: "event-1"}},
+	}
+	ha := handlers.NewHandler(&fakeEventService{talks: talks})
+	router := mux.NewRouter()
+	router.HandleFunc("/events/{id}", ha.GetEventTalksHandler)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/events/event-1", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var resp data.Talks
+	err := json.Unmarshal(rr.Body.Bytes(), &resp)
+	require.Nil(t, err)
+	assert.Equal(t, *talks, resp)
+}
+
+// v1Events and v1Talks are the v1 responses as they were before v2.
+type v1Events struct {
+	Events []struct {
+		ID        string `json:"ID"`
+		Name      string `json:"name"`
+		DateStart string `json:"date_start"`
+		DateEnd   string `json:"date_end"`
+		Location  string `json:"location"`
+	} `json:"events"`
+}
+
+type v1Talks struct {
+	Talks []struct {
+		Title    string   `json:"title"`
+		Speakers []string `json:"speakers"`
+		Date     string   `json:"date"`
+		Time     string   `json:"time"`
+		EventID  string   `json:"event_id"`
+	} `json:"talks"`
+}
+
+// TestV1Golden checks that v1 responses keep the fields and values served before v2,
+// which are kept in testdata/v1: the fields added since are only added to them.
+func TestV1Golden(t *testing.T) {
+	events := data.Events{
+		Events: []data.Event{{
+			ID:        "event-1",
+			Name:      "Event 1",
+			DateStart: date("01/02/2023"),
+			DateEnd:   date("02/02/2023"),
+			TimeZone:  "Europe/Amsterdam",
+			Location:  "Amsterdam",
+		}},
+		NextCursor: "next",
+	}
+	talks := &data.Talks{
+		Talks: []data.Talk{
+			{ID: "talk-1", EventID: "event-1", Title: "Testing Go services", Speakers: []string{"Adelina Simion", "Grace Hopper"},
+				SpeakerIDs: []string{"adelina-simion", "grace-hopper"}, Date: date("01/02/2023"), Time: dateTime("01/02/2023 09:30"),
+				Duration: 45, Room: "Main", Track: "Go"},
+			{ID: "talk-2", EventID: "event-1", Title: "Closing keynote", Speakers: []string{"Grace Hopper"},
+				SpeakerIDs: []string{"grace-hopper"}, Date: date("02/02/2023"), Time: dateTime("02/02/2023 17:00")},
+		},
+	}
+	testCases := map[string]struct {
+		service  *fakeEventService
+		path     string
+		golden   string
+		baseline any
+		added    []string
+	}{
+		"events": {
+			service:  &fakeEventService{events: events},
+			path:     "/events",
+			golden:   "events.json",
+			baseline: &v1Events{},
+			added:    []string{`"time_zone":"Europe/Amsterdam"`, `"next_cursor":"next"`},
+		},
+		"no events": {
+			service:  &fakeEventService{},
+			path:     "/events",
+			golden:   "events_empty.json",
+			baseline: &v1Events{},
+		},
+		"talks": {
+			service:  &fakeEventService{talks: talks},
+			path:     "/events/event-1",
+			golden:   "talks.json",
+			baseline: &v1Talks{},
+			added: []string{`"id":"talk-1"`, `"speaker_ids":["adelina-simion","grace-hopper"]`,
+				`"duration":45`, `"room":"Main"`, `"track":"Go"`, `"id":"talk-2"`},
+		},
+		"no talks": {
+			service:  &fakeEventService{talks: &data.Talks{Talks: []data.Talk{}}},
+			path:     "/events/event-1",
+			golden:   "talks_empty.json",
+			baseline: &v1Talks{},
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			expected, err := os.ReadFile(filepath.Join("testdata", "v1", tc.golden))
+			require.Nil(t, err)
+			ha := handlers.NewHandler(tc.service)
+			router := mux.NewRouter()
+			router.HandleFunc("/events", ha.GetEventsHandler)
+			router.HandleFunc("/events/{id}", ha.GetEventTalksHandler)
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest("GET", tc.path, nil))
+
+			require.Equal(t, http.StatusOK, rr.Code)
+			require.Nil(t, json.Unmarshal(rr.Body.Bytes(), tc.baseline))
+			baseline, err := json.Marshal(tc.baseline)
+			require.Nil(t, err)
+			assert.Equal(t, strings.TrimSpace(string(expected)), string(baseline))
+			for _, field := range tc.added {
+				assert.Contains(t, rr.Body.String(), field)
+			}
+		})
+	}
+}
+
+func TestSetEventService(t *testing.T) {
+	before := &fakeEventService{
+		events: data.Events{Events: []data.Event{{ID: "event-1"}}},
+	}
+	after := &fakeEventService{
+		events: data.Events{Events: []data.Event{{ID: "event-2"}}},
+	}
+	ha := handlers.NewHandler(before)
+	get := func() data.Events {
+		rr := httptest.NewRecorder()
+		ha.GetEventsHandler(rr, httptest.NewRequest("GET", "/events", nil))
+		require.Equal(t, http.StatusOK, rr.Code)
+		var resp data.Events
+		require.Nil(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+		return resp
+	}
+
+	assert.Equal(t, before.events, get())
+	ha.SetEventService(after)
+	assert.Equal(t, after.events, get())
+}
+
+// calendarService serves a single event, calling reload when the event is first read,
+// as a reload of the data would between the reads of a request.
+type calendarService struct {
+	handlers.EventService
+	event    data.Event
+	modified time.Time
+	reload   func()
+}
+
+func (c *calendarService) GetEvent(id string) (*data.Event, error) {
+	if c.reload != nil {
+		c.reload()
+	}
+	return &c.event, nil
+}
+
+func (c *calendarService) QueryTalks(id string, q data.TalkQuery) (*data.Talks, error) {
+	return &data.Talks{Talks: c.event.Talks}, nil
+}
+
+func (c *calendarService) GetTalk(eventID, talkID string) (*data.Talk, error) {
+	for _, t := range c.event.Talks {
+		if t.ID == talkID {
+			return &t, nil
+		}
+	}
+	return nil, data.ErrTalkNotFound
+}
+
+func (c *calendarService) LastModified() time.Time {
+	return c.modified
+}
+
+func TestCalendarDuringReload(t *testing.T) {
+	newService := func(name string, modified time.Time) *calendarService {
+		return &calendarService{
+			event: data.Event{
+				ID:        "event-1",
+				Name:      name,
+				DateStart: date("01/02/2010"),
+				DateEnd:   date("01/02/2010"),
+				Talks:     []data.Talk{{ID: "talk-1", EventID: "event-1", Title: name + " talk", Date: date("01/02/2010")}},
+			},
+			modified: modified,
+		}
+	}
+	for _, path := range []string{"/events/event-1/calendar.ics", "/events/event-1/talks.ics?talk=talk-1"} {
+		t.Run(path, func(t *testing.T) {
+			before := newService("Before", dateTime("01/01/2010 10:00"))
+			after := newService("After", dateTime("01/01/2010 11:00"))
+			ha := handlers.NewHandler(before)
+			before.reload = func() {
+				ha.SetEventService(after)
+			}
+			router := mux.NewRouter()
+			router.HandleFunc("/events/{id}/calendar.ics", ha.GetEventCalendarHandler)
+			router.HandleFunc("/events/{id}/talks.ics", ha.GetEventTalksCalendarHandler)
+			rr := httptest.NewRecorder()
+
+			router.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+
+			require.Equal(t, http.StatusOK, rr.Code)
+			body := rr.Body.String()
+			assert.Contains(t, body, "SUMMARY:Before talk\r\n")
+			assert.Contains(t, body, "DTSTAMP:20100101T100000Z\r\n")
+			assert.NotContains(t, body, "After")
+		})
+	}
+}
+
+func TestProblemResponses(t *testing.T) {
+	es, err := data.NewEventService([]data.Event{
+		{
+			ID:        "event-1",
+			DateStart: date("01/02/2010"),
+			DateEnd:   date("02/02/2010"),
+		},
+	}, []data.Talk{})
+	require.Nil(t, err)
+	ha := handlers.NewHandler(es)
+	router := mux.NewRouter()
+	router.NotFoundHandler = http.HandlerFunc(handlers.NotFoundHandler)
+	router.MethodNotAllowedHandler = http.HandlerFunc(handlers.MethodNotAllowedHandler)
+	router.Methods("GET").Path("/events/{id}").HandlerFunc(ha.GetEventTalksHandler)
+
+	testCases := map[string]struct {
+		method          string
+		path            string
+		expectedProblem handlers.Problem
+	}{
+		"invalid day": {
+			method: "GET",
+			path:   "/events/event-1?day=adelina",
+			expectedProblem: handlers.Problem{
+				Type:          "/problems/invalid-request",
+				Title:         "Invalid request",
+				Status:        http.StatusBadRequest,
+				Detail:        "invalid parameter day",
+				Instance:      "/events/event-1?day=adelina",
+				Code:          handlers.CodeInvalidRequest,
+				InvalidParams: []handlers.InvalidParam{{Name: "day", Reason: "must be an integer"}},
+			},
+		},
+		"day out of range": {
+			method: "GET",
+			path:   "/events/event-1?day=3",
+			expectedProblem: handlers.Problem{
+				Type:          "/problems/day-out-of-range",
+				Title:         "Day out of range",
+				Status:        http.StatusBadRequest,
+				Detail:        "filtered date 03/02/2010 is after event end date 02/02/2010",
+				Instance:      "/events/event-1?day=3",
+				Code:          handlers.CodeDayOutOfRange,
+				InvalidParams: []handlers.InvalidParam{{Name: "day", Reason: "filtered date 03/02/2010 is after event end date 02/02/2010"}},
+			},
+		},
+		"unknown route": {
+			method: "GET",
+			path:   "/speakers",
+			expectedProblem: handlers.Problem{
+				Type:     "/problems/not-found",
+				Title:    "Not found",
+				Status:   http.StatusNotFound,
+				Detail:   "no route for /speakers",
+				Instance: "/speakers",
+				Code:     handlers.CodeNotFound,
+			},
+		},
+		"method not allowed": {
+			method: "POST",
+			path:   "/events/event-1",
+			expectedProblem: handlers.Problem{
+				Type:     "/problems/method-not-allowed",
+				Title:    "Method not allowed",
+				Status:   http.StatusMethodNotAllowed,
+				Detail:   "method POST is not allowed for /events/event-1",
+				Instance: "/events/event-1",
+				Code:     handlers.CodeMethodNotAllowed,
+			},
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest(tc.method, tc.path, nil))
+			require.Equal(t, tc.expectedProblem.Status, rr.Code)
+			assert.Equal(t, "application/problem+json", rr.Header().Get("Content-Type"))
+
+			var resp handlers.Problem
+			err := json.Unmarshal(rr.Body.Bytes(), &resp)
+			require.Nil(t, err)
+			assert.Equal(t, tc.expectedProblem, resp)
+		})
+	}
+}
+
+func TestGetEventDetailIntegration(t *testing.T) {
+	if os.Getenv("INTEGRATION") == "" {
+		t.Skip("Skipping TestGetEventDetailIntegration in short mode.")
+	}
+	eventID := "event-1"
+	events := []data.Event{
+		{
+			ID:        eventID,
+			Name:      "Event 1 2023",
+			DateStart: date("01/02/2010"),
+			DateEnd:   date("02/02/2010"),
+			Location:  "Amsterdam",
+		},
+		{
+			ID: "event-2",
+		},
+	}
+	talks := []data.Talk{
+		{
+			ID:      "talk-1-1",
+			EventID: eventID,
+			Title:   "event 1 talk 1",
+			Date:    date("01/02/2010"),
+		},
+	}
+	es, err := data.NewEventService(events, talks)
+	require.Nil(t, err)
+
+	// Arrange
+	ha := handlers.NewHandler(es)
+	router := mux.NewRouter()
+	router.HandleFunc("/events/{id}", ha.V2().GetEventHandler)
+
+	testCases := map[string]struct {
+		path               string
+		expectedBody       string
+		expectedCode       string
+		expectedStatusCode int
+	}{
+		"event": {
+			path:               "/events/event-1",
+			expectedBody:       `{"id":"event-1","name":"Event 1 2023","date_start":"2010-02-01T00:00:00Z","date_end":"2010-02-02T00:00:00Z","location":"Amsterdam"}`,
+			expectedStatusCode: http.StatusOK,
+		},
+		"event with talks": {
+			path:               "/events/event-1?include=talks",
+			expectedBody:       `{"id":"event-1","name":"Event 1 2023","date_start":"2010-02-01T00:00:00Z","date_end":"2010-02-02T00:00:00Z","location":"Amsterdam","talks":[{"id":"talk-1-1","title":"event 1 talk 1","speakers":null,"date":"2010-02-01T00:00:00Z","time":"","event_id":"event-1"}]}`,
+			expectedStatusCode: http.StatusOK,
+		},
+		"event without talks": {
+			path:               "/events/event-2?include=talks",
+			expectedBody:       `{"id":"event-2","name":"","date_start":"","date_end":"","location":"","talks":[]}`,
+			expectedStatusCode: http.StatusOK,
+		},
+		"invalid include": {
+			path:               "/events/event-1?include=speakers",
+			expectedCode:       handlers.CodeInvalidRequest,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		"invalid event": {
+			path:               "/events/invalid-event",
+			expectedCode:       handlers.CodeEventNotFound,
+			expectedStatusCode: http.StatusNotFound,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", tc.path, nil)
+			require.Nil(t, err)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			require.Equal(t, tc.expectedStatusCode, rr.Code)
+
+			if len(tc.expectedCode) != 0 {
+				var respErr handlers.Problem
+				err = json.Unmarshal(rr.Body.Bytes(), &respErr)
+				require.Nil(t, err)
+				assert.Equal(t, tc.expectedCode, respErr.Code)
+				return
+			}
+			assert.JSONEq(t, tc.expectedBody, rr.Body.String())
+		})
+	}
+}
+
+func TestV2EventCRUDIntegration(t *testing.T) {
+	if os.Getenv("INTEGRATION") == "" {
+		t.Skip("Skipping TestV2EventCRUDIntegration in short mode.")
+	}
+	es, err := data.NewEventService([]data.Event{}, []data.Talk{})
+	require.Nil(t, err)
+
+	// Arrange
+	ha := handlers.NewHandler(es).V2()
+	router := mux.NewRouter()
+	router.HandleFunc("/events", ha.GetEventsHandler).Methods("GET")
+	router.HandleFunc("/events", ha.CreateEventHandler).Methods("POST")
+	router.HandleFunc("/events/{id}", ha.PatchEventHandler).Methods("PATCH")
+	router.HandleFunc("/events/{id}/talks", ha.CreateTalkHandler).Methods("POST")
+	router.HandleFunc("/events/{id}/talks", ha.GetEventTalksHandler).Methods("GET")
+
+	testCases := []struct {
+		name               string
+		method             string
+		path               string
+		body               string
+		expectedBody       string
+		expectedParam      string
+		expectedStatusCode int
+	}{
+		{
+			name:               "create event",
+			method:             "POST",
+			path:               "/events",
+			body:               `{"id":"event-1","name":"Event 1","date_start":"2010-02-01","date_end":"2010-02-02","location":"Amsterdam"}`,
+			expectedBody:       `{"id":"event-1","name":"Event 1","date_start":"2010-02-01T00:00:00Z","date_end":"2010-02-02T00:00:00Z","location":"Amsterdam"}`,
+			expectedStatusCode: http.StatusCreated,
+		},
+		{
+			name:               "create event with legacy date",
+			method:             "POST",
+			path:               "/events",
+			body:               `{"id":"event-2","name":"Event 2","date_start":"01/02/2010","date_end":"2010-02-02"}`,
+			expectedParam:      "date_start",
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "patch event",
+			method:             "PATCH",
+			path:               "/events/event-1",
+			body:               `{"date_end":"2010-02-03"}`,
+			expectedBody:       `{"id":"event-1","name":"Event 1","date_start":"2010-02-01T00:00:00Z","date_end":"2010-02-03T00:00:00Z","location":"Amsterdam"}`,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "create talk",
+			method:             "POST",
+			path:               "/events/event-1/talks",
+			body:               `{"id":"talk-1","title":"Talk 1","speakers":["Ada"],"date":"2010-02-03","time":"10:00"}`,
+			expectedBody:       `{"id":"talk-1","title":"Talk 1","speakers":["Ada"],"speaker_ids":["ada"],"date":"2010-02-03T00:00:00Z","time":"2010-02-03T10:00:00Z","event_id":"event-1"}`,
+			expectedStatusCode: http.StatusCreated,
+		},
+		{
+			name:               "create talk with invalid date",
+			method:             "POST",
+			path:               "/events/event-1/talks",
+			body:               `{"title":"Talk 2","date":"2010-13-01"}`,
+			expectedParam:      "date",
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "get talks",
+			method:             "GET",
+			path:               "/events/event-1/talks",
+			expectedBody:       `{"talks":[{"id":"talk-1","title":"Talk 1","speakers":["Ada"],"speaker_ids":["ada"],"date":"2010-02-03T00:00:00Z","time":"2010-02-03T10:00:00Z","event_id":"event-1"}]}`,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "get events",
+			method:             "GET",
+			path:               "/events",
+			expectedBody:       `{"events":[{"id":"event-1","name":"Event 1","date_start":"2010-02-01T00:00:00Z","date_end":"2010-02-03T00:00:00Z","location":"Amsterdam"}]}`,
+			expectedStatusCode: http.StatusOK,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			require.Nil(t, err)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			require.Equal(t, tc.expectedStatusCode, rr.Code)
+
+			if len(tc.expectedParam) != 0 {
+				var respErr handlers.Problem
+				err = json.Unmarshal(rr.Body.Bytes(), &respErr)
+				require.Nil(t, err)
+				assert.Equal(t, handlers.CodeInvalidRequest, respErr.Code)
+				require.Len(t, respErr.InvalidParams, 1)
+				assert.Equal(t, tc.expectedParam, respErr.InvalidParams[0].Name)
+				return
+			}
+			assert.JSONEq(t, tc.expectedBody, rr.Body.String())
+		})
+	}
+}
+
+// slowReadService is an event service which returns the first event it reads late, giving other writes time to land
+// between a handler reading an event and saving it.
+type slowReadService struct {
+	*data.EventService
+	read atomic.Bool
+}
+
+func (s *slowReadService) GetEvent(id string) (*data.Event, error) {
+	e, err := s.EventService.GetEvent(id)
+	if !s.read.Swap(true) {
+		time.Sleep(50 * time.Millisecond)
+	}
+	return e, err
+}
+
+func TestConcurrentPatchIntegration(t *testing.T) {
+	if os.Getenv("INTEGRATION") == "" {
+		t.Skip("Skipping TestConcurrentPatchIntegration in short mode.")
+	}
+	events := []data.Event{
+		{ID: "event-1", DateStart: date("01/02/2010"), DateEnd: date("02/02/2010")},
+	}
+	es, err := data.NewEventService(events, []data.Talk{})
+	require.Nil(t, err)
+	ha := handlers.NewHandler(&slowReadService{EventService: es})
+	router := mux.NewRouter()
+	router.HandleFunc("/v1/events/{id}", ha.PatchEventHandler).Methods("PATCH")
+	router.HandleFunc("/v2/events/{id}", ha.V2().PatchEventHandler).Methods("PATCH")
+	patch := func(path, body string) {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("PATCH", path, strings.NewReader(body)))
+		assert.Equal(t, http.StatusOK, rr.Code)
+	}
+
+	// the name is patched while the location patch is under way
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		patch("/v2/events/event-1", `{"location":"Amsterdam"}`)
+	}()
+	go func() {
+		defer wg.Done()
+		time.Sleep(10 * time.Millisecond)
+		patch("/v1/events/event-1", `{"name":"Renamed"}`)
+	}()
+	wg.Wait()
+
+	event, err := es.GetEvent("event-1")
+	require.Nil(t, err)
+	assert.Equal(t, "Renamed", event.Name)
+	assert.Equal(t, "Amsterdam", event.Location)
+}
+
+func TestTimeZonesIntegration(t *testing.T) {
+	if os.Getenv("INTEGRATION") == "" {
+		t.Skip("Skipping TestTimeZonesIntegration in short mode.")
+	}
+	events := []data.Event{
+		{ID: "event-1", DateStart: date("01/02/2010"), DateEnd: date("02/02/2010"), TimeZone: "Europe/Amsterdam"},
+	}
+	es, err := data.NewEventService(events, []data.Talk{})
+	require.Nil(t, err)
+
+	// Arrange
+	ha := handlers.NewHandler(es)
+	router := mux.NewRouter()
+	router.HandleFunc("/v1/events/{id}/talks", ha.CreateTalkHandler).Methods("POST")
+	router.HandleFunc("/v1/events/{id}/talks", ha.GetEventTalksHandler).Methods("GET")
+	router.HandleFunc("/v2/events/{id}/talks", ha.V2().CreateTalkHandler).Methods("POST")
+	router.HandleFunc("/v2/events/{id}/talks", ha.V2().GetEventTalksHandler).Methods("GET")
+	router.HandleFunc("/v1/events/{id}", ha.PatchEventHandler).Methods("PATCH")
+	router.HandleFunc("/v2/events/{id}", ha.V2().PatchEventHandler).Methods("PATCH")
+
+	testCases := []struct {
+		name               string
+		method             string
+		path               string
+		body               string
+		expectedBody       string
+		expectedStatusCode int
+	}{
+		{
+			name:               "create talk at local time",
+			method:             "POST",
+			path:               "/v1/events/event-1/talks",
+			body:               `{"id":"talk-1","title":"Talk 1","date":"01/02/2010","time":"09:30"}`,
+			expectedBody:       `{"id":"talk-1","title":"Talk 1","speakers":null,"date":"01/02/2010","time":"09:30","event_id":"event-1"}`,
+			expectedStatusCode: http.StatusCreated,
+		},
+		{
+			name:               "create talk with offset",
+			method:             "POST",
+			path:               "/v2/events/event-1/talks",
+			body:               `{"id":"talk-2","title":"Talk 2","time":"2010-02-02T08:00:00Z"}`,
+			expectedBody:       `{"id":"talk-2","title":"Talk 2","speakers":null,"date":"2010-02-02T00:00:00+01:00","time":"2010-02-02T09:00:00+01:00","event_id":"event-1"}`,
+			expectedStatusCode: http.StatusCreated,
+		},
+		{
+			name:   "v1 talks in local time",
+			method: "GET",
+			path:   "/v1/events/event-1/talks",
+			expectedBody: `{"talks":[{"id":"talk-1","title":"Talk 1","speakers":null,"date":"01/02/2010","time":"09:30","event_id":"event-1"},` +
+				`{"id":"talk-2","title":"Talk 2","speakers":null,"date":"02/02/2010","time":"09:00","event_id":"event-1"}]}`,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:   "v2 talks with offsets",
+			method: "GET",
+			path:   "/v2/events/event-1/talks",
+			expectedBody: `{"talks":[{"id":"talk-1","title":"Talk 1","speakers":null,"date":"2010-02-01T00:00:00+01:00","time":"2010-02-01T09:30:00+01:00","event_id":"event-1"},` +
+				`{"id":"talk-2","title":"Talk 2","speakers":null,"date":"2010-02-02T00:00:00+01:00","time":"2010-02-02T09:00:00+01:00","event_id":"event-1"}]}`,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "v1 patch time zone",
+			method:             "PATCH",
+			path:               "/v1/events/event-1",
+			body:               `{"time_zone":"America/New_York"}`,
+			expectedBody:       `{"ID":"event-1","name":"","date_start":"01/02/2010","date_end":"02/02/2010","time_zone":"America/New_York","location":""}`,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "v2 patch time zone",
+			method:             "PATCH",
+			path:               "/v2/events/event-1",
+			body:               `{"time_zone":"America/Los_Angeles"}`,
+			expectedBody:       `{"id":"event-1","name":"","date_start":"2010-02-01T00:00:00-08:00","date_end":"2010-02-02T00:00:00-08:00","time_zone":"America/Los_Angeles","location":""}`,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:   "talks keep local times",
+			method: "GET",
+			path:   "/v2/events/event-1/talks",
+			expectedBody: `{"talks":[{"id":"talk-1","title":"Talk 1","speakers":null,"date":"2010-02-01T00:00:00-08:00","time":"2010-02-01T09:30:00-08:00","event_id":"event-1"},` +
+				`{"id":"talk-2","title":"Talk 2","speakers":null,"date":"2010-02-02T00:00:00-08:00","time":"2010-02-02T09:00:00-08:00","event_id":"event-1"}]}`,
+			expectedStatusCode: http.StatusOK,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			require.Nil(t, err)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			require.Equal(t, tc.expectedStatusCode, rr.Code)
+			assert.JSONEq(t, tc.expectedBody, rr.Body.String())
+		})
+	}
+}
+
+func TestCalendarIntegration(t *testing.T) {
+	if os.Getenv("INTEGRATION") == "" {
+		t.Skip("Skipping TestCalendarIntegration in short mode.")
+	}
+	events := []data.Event{
+		{ID: "event-1", Name: "Event 1, 2010", DateStart: date("01/02/2010"), DateEnd: date("02/02/2010"), TimeZone: "Europe/Amsterdam", Location: "Amsterdam"},
+	}
+	talks := []data.Talk{
+		{ID: "talk-1", EventID: "event-1", Title: "Talk 1", Speakers: []string{"Ada; Lovelace", "Grace Hopper"},
+			Date: date("01/02/2010"), Time: dateTime("01/02/2010 08:30"), Duration: 45, Room: "Main", Track: "Go"},
+		{ID: "talk-2", EventID: "event-1", Title: "A talk with a title much longer than a single line of an iCalendar document",
+			Date: date("02/02/2010")},
+		{ID: "talk-3", EventID: "event-1", Title: "Talk 3", Date: date("02/02/2010"), Time: dateTime("02/02/2010 13:00")},
+	}
+	es, err := data.NewEventService(events, talks)
+	require.Nil(t, err)
+
+	// Arrange
+	ha := handlers.NewHandler(es)
+	router := mux.NewRouter()
+	router.HandleFunc("/events/{id}/calendar.ics", ha.GetEventCalendarHandler)
+	router.HandleFunc("/events/{id}/talks.ics", ha.GetEventTalksCalendarHandler)
+	router.HandleFunc("/v2/events/{id}/talks.ics", ha.V2().GetEventTalksCalendarHandler)
+
+	testCases := map[string]struct {
+		path               string
+		expectedUIDs       []string
+		expectedLines      []string
+		expectedCode       string
+		expectedStatusCode int
+	}{
+		"event calendar": {
+			path:         "/events/event-1/calendar.ics",
+			expectedUIDs: []string{"talk-1.event-1@conftalks", "talk-2.event-1@conftalks", "talk-3.event-1@conftalks"},
+			expectedLines: []string{
+				"X-WR-CALNAME:Event 1\\, 2010",
+				"X-WR-TIMEZONE:Europe/Amsterdam",
+				"DTSTART:20100201T083000Z",
+				"DTEND:20100201T091500Z",
+				"SUMMARY:Talk 1",
+				"LOCATION:Main\\, Amsterdam",
+				"DESCRIPTION:Speakers: Ada\\; Lovelace\\, Grace Hopper\\nTrack: Go",
+				"CATEGORIES:Go",
+				"DTSTART;VALUE=DATE:20100202",
+				"DTEND;VALUE=DATE:20100203",
+				"SUMMARY:A talk with a title much longer than a single line of an iCalendar document",
+				// a talk without a duration lasts the default 30 minutes
+				"DTSTART:20100202T130000Z",
+				"DTEND:20100202T133000Z",
+				"SUMMARY:Talk 3",
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		"talks of a day": {
+			path:               "/events/event-1/talks.ics?day=2",
+			expectedUIDs:       []string{"talk-2.event-1@conftalks", "talk-3.event-1@conftalks"},
+			expectedStatusCode: http.StatusOK,
+		},
+		"v2 talks of a date": {
+			path:               "/v2/events/event-1/talks.ics?date=2010-02-01",
+			expectedUIDs:       []string{"talk-1.event-1@conftalks"},
+			expectedStatusCode: http.StatusOK,
+		},
+		"personal agenda": {
+			path:               "/events/event-1/talks.ics?talk=talk-1",
+			expectedUIDs:       []string{"talk-1.event-1@conftalks"},
+			expectedStatusCode: http.StatusOK,
+		},
+		"unknown talk in agenda": {
+			path:               "/events/event-1/talks.ics?talk=talk-1&talk=talk-9",
+			expectedCode:       handlers.CodeTalkNotFound,
+			expectedStatusCode: http.StatusNotFound,
+		},
+		"unknown event": {
+			path:               "/events/event-9/calendar.ics",
+			expectedCode:       handlers.CodeEventNotFound,
+			expectedStatusCode: http.StatusNotFound,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", tc.path, nil)
+			require.Nil(t, err)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			require.Equal(t, tc.expectedStatusCode, rr.Code)
+
+			if tc.expectedCode != "" {
+				var respErr handlers.Problem
+				err = json.Unmarshal(rr.Body.Bytes(), &respErr)
+				require.Nil(t, err)
+				assert.Equal(t, tc.expectedCode, respErr.Code)
+				return
+			}
+			assert.Equal(t, "text/calendar; charset=utf-8", rr.Header().Get("Content-Type"))
+			body := rr.Body.String()
+			require.True(t, strings.HasSuffix(body, "\r\n"))
+			for _, line := range strings.Split(strings.TrimSuffix(body, "\r\n"), "\r\n") {
+				assert.LessOrEqual(t, len(line), 75, "line %q is not folded", line)
+			}
+			// long lines are folded by a line break followed by a space
+			lines := strings.Split(strings.ReplaceAll(body, "\r\n ", ""), "\r\n")
+			assert.Equal(t, "BEGIN:VCALENDAR", lines[0])
+			var uids []string
+			for _, line := range lines {
+				if uid, ok := strings.CutPrefix(line, "UID:"); ok {
+					uids = append(uids, uid)
+				}
+			}
+			assert.Equal(t, tc.expectedUIDs, uids)
+			for _, line := range tc.expectedLines {
+				assert.Contains(t, lines, line)
+			}
+		})
+	}
+}
+
+func TestImportTalksIntegration(t *testing.T) {
+	if os.Getenv("INTEGRATION") == "" {
+		t.Skip("Skipping TestImportTalksIntegration in short mode.")
+	}
+	events := []data.Event{
+		{ID: "event-1", DateStart: date("01/02/2010"), DateEnd: date("02/02/2010")},
+	}
+	testCases := map[string]struct {
+		path               string
+		body               string
+		expectedReport     data.TalkImportReport
+		expectedTalks      []string
+		expectedCode       string
+		expectedParam      string
+		expectedStatusCode int
+	}{
+		"import": {
+			path: "/events/event-1/talks:import",
+			body: "title,speakers,date,time\nTalk 1,Ada Lovelace; Grace Hopper,2010-02-01,09:30\nTalk 2,,03/02/2010,\n",
+			expectedReport: data.TalkImportReport{
+				Rows:     2,
+				Imported: []data.ImportedTalk{{Line: 2, EventID: "event-1", ID: "28de27f3ac5a", Title: "Talk 1"}},
+				Issues: []data.ImportIssue{
+					{Line: 3, Column: "date", Message: "talk date 03/02/2010 is outside of event event-1 dates 01/02/2010-02/02/2010"},
+				},
+			},
+			expectedTalks:      []string{"Talk 1"},
+			expectedStatusCode: http.StatusOK,
+		},
+		"dry run with column mapping": {
+			path: "/events/event-1/talks:import?dry_run=true&columns=title%3DSession,date%3DDay&delimiter=%3B",
+			body: "Session;Day\nTalk 1;1 Feb 2010\n",
+			expectedReport: data.TalkImportReport{
+				DryRun:   true,
+				Rows:     1,
+				Imported: []data.ImportedTalk{{Line: 2, EventID: "event-1", ID: "13012e4d3db2", Title: "Talk 1"}},
+				Issues:   []data.ImportIssue{},
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		"missing column": {
+			path:               "/events/event-1/talks:import",
+			body:               "name,date\nTalk 1,01/02/2010\n",
+			expectedCode:       handlers.CodeInvalidImport,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		"invalid column mapping": {
+			path:               "/events/event-1/talks:import?columns=abstract%3DAbstract",
+			expectedCode:       handlers.CodeInvalidImport,
+			expectedParam:      "columns",
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		"invalid dry run": {
+			path:               "/events/event-1/talks:import?dry_run=maybe",
+			expectedCode:       handlers.CodeInvalidRequest,
+			expectedParam:      "dry_run",
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		"unknown event": {
+			path:               "/events/event-9/talks:import",
+			body:               "title,date\nTalk 1,01/02/2010\n",
+			expectedCode:       handlers.CodeEventNotFound,
+			expectedStatusCode: http.StatusNotFound,
+		},
+		"body too large": {
+			path:               "/events/event-1/talks:import",
+			body:               "title,date\n" + strings.Repeat("Talk 1,01/02/2010\n", handlers.MaxTalkImportSize/len("Talk 1,01/02/2010\n")+1),
+			expectedCode:       handlers.CodeBodyTooLarge,
+			expectedStatusCode: http.StatusRequestEntityTooLarge,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			// Arrange
+			es, err := data.NewEventService(events, []data.Talk{})
+			require.Nil(t, err)
+			ha := handlers.NewHandler(es)
+			router := mux.NewRouter()
+			router.HandleFunc("/events/{id}/talks:import", ha.ImportTalksHandler).Methods("POST")
+			req, err := http.NewRequest("POST", tc.path, strings.NewReader(tc.body))
+			require.Nil(t, err)
+			req.Header.Set("Content-Type", "text/csv")
+			rr := httptest.NewRecorder()
+
+			// Act
+			router.ServeHTTP(rr, req)
+
+			// Assert
+			require.Equal(t, tc.expectedStatusCode, rr.Code)
+			if tc.expectedCode != "" {
+				var respErr handlers.Problem
+				err = json.Unmarshal(rr.Body.Bytes(), &respErr)
+				require.Nil(t, err)
+				assert.Equal(t, tc.expectedCode, respErr.Code)
+				if tc.expectedParam != "" {
+					require.Len(t, respErr.InvalidParams, 1)
+					assert.Equal(t, tc.expectedParam, respErr.InvalidParams[0].Name)
+				}
+			} else {
+				var report data.TalkImportReport
+				err = json.Unmarshal(rr.Body.Bytes(), &report)
+				require.Nil(t, err)
+				assert.Equal(t, tc.expectedReport, report)
+			}
+			// a failed import imports nothing
+			talks, err := es.GetEventTalks("event-1")
+			require.Nil(t, err)
+			var titles []string
+			for _, talk := range talks.Talks {
+				titles = append(titles, talk.Title)
+			}
+			assert.Equal(t, tc.expectedTalks, titles)
+		})
+	}
+}
+
+func TestAdminIntegration(t *testing.T) {
+	if os.Getenv("INTEGRATION") == "" {
+		t.Skip("Skipping TestAdminIntegration in short mode.")
+	}
+	events := []data.Event{
+		{ID: "event-1", Name: "Event 1", DateStart: date("01/02/2010"), DateEnd: date("02/02/2010")},
+	}
+	talks := []data.Talk{
+		{ID: "talk-1", EventID: "event-1", Title: "Talk 1", Speakers: []string{"Ada Lovelace"}, Date: date("01/02/2010")},
+	}
+	source, err := data.NewEventService(events, talks)
+	require.Nil(t, err)
+	router := mux.NewRouter()
+	ha := handlers.NewHandler(source)
+	router.HandleFunc("/admin/export", ha.ExportHandler).Methods("GET")
+
+	// Arrange
+	export := func(t *testing.T, query string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("GET", "/admin/export"+query, nil)
+		require.Nil(t, err)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code)
+		return rr
+	}
+	jsonExport := export(t, "")
+	assert.Equal(t, "application/json; charset=UTF-8", jsonExport.Header().Get("Content-Type"))
+	var archive data.Archive
+	require.Nil(t, json.Unmarshal(jsonExport.Body.Bytes(), &archive))
+	assert.Equal(t, data.ArchiveVersion, archive.Version)
+	require.Len(t, archive.Talks, 1)
+	tarExport := export(t, "?format=tar.gz")
+	assert.Equal(t, "application/gzip", tarExport.Header().Get("Content-Type"))
+
+	testCases := map[string]struct {
+		path               string
+		body               []byte
+		expectedReport     data.ArchiveImportReport
+		expectedEventIDs   []string
+		expectedCode       string
+		expectedStatusCode int
+	}{
+		"json": {
+			path: "/admin/import?mode=replace",
+			body: jsonExport.Body.Bytes(),
+			expectedReport: data.ArchiveImportReport{
+				Mode:     data.ArchiveReplace,
+				Events:   data.Changes{Created: []string{"event-1"}, Updated: []string{}, Deleted: []string{"event-2"}},
+				Talks:    data.Changes{Created: []string{"event-1/talk-1"}, Updated: []string{}, Deleted: []string{}},
+				Speakers: data.Changes{Created: []string{"ada-lovelace"}, Updated: []string{}, Deleted: []string{}},
+			},
+			expectedEventIDs:   []string{"event-1"},
+			expectedStatusCode: http.StatusOK,
+		},
+		"tar.gz dry run": {
+			path: "/admin/import?dry_run=true",
+			body: tarExport.Body.Bytes(),
+			expectedReport: data.ArchiveImportReport{
+				Mode:     data.ArchiveMerge,
+				DryRun:   true,
+				Events:   data.Changes{Created: []string{"event-1"}, Updated: []string{}, Deleted: []string{}},
+				Talks:    data.Changes{Created: []string{"event-1/talk-1"}, Updated: []string{}, Deleted: []string{}},
+				Speakers: data.Changes{Created: []string{"ada-lovelace"}, Updated: []string{}, Deleted: []string{}},
+			},
+			expectedEventIDs:   []string{"event-2"},
+			expectedStatusCode: http.StatusOK,
+		},
+		"invalid mode": {
+			path:               "/admin/import?mode=append",
+			body:               jsonExport.Body.Bytes(),
+			expectedCode:       handlers.CodeInvalidRequest,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		"invalid archive": {
+			path:               "/admin/import",
+			body:               []byte(`{"events": []}`),
+			expectedCode:       handlers.CodeInvalidArchive,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		"malformed json": {
+			path:               "/admin/import",
+			body:               []byte(`{"events": [`),
+			expectedCode:       handlers.CodeInvalidArchive,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		"too large": {
+			path:               "/admin/import",
+			body:               bytes.Repeat([]byte(" "), data.MaxArchiveFileSize+1),
+			expectedCode:       handlers.CodeBodyTooLarge,
+			expectedStatusCode: http.StatusRequestEntityTooLarge,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			target, err := data.NewEventService([]data.Event{
+				{ID: "event-2", DateStart: date("01/03/2010"), DateEnd: date("01/03/2010")},
+			}, []data.Talk{})
+			require.Nil(t, err)
+			router := mux.NewRouter()
+			router.HandleFunc("/admin/import", handlers.NewHandler(target).ImportHandler).Methods("POST")
+			req, err := http.NewRequest("POST", tc.path, bytes.NewReader(tc.body))
+			require.Nil(t, err)
+			rr := httptest.NewRecorder()
+
+			// Act
+			router.ServeHTTP(rr, req)
+
+			// Assert
+			require.Equal(t, tc.expectedStatusCode, rr.Code)
+			if tc.expectedCode != "" {
+				var respErr handlers.Problem
+				err = json.Unmarshal(rr.Body.Bytes(), &respErr)
+				require.Nil(t, err)
+				assert.Equal(t, tc.expectedCode, respErr.Code)
+				return
+			}
+			var report data.ArchiveImportReport
+			require.Nil(t, json.Unmarshal(rr.Body.Bytes(), &report))
+			assert.Equal(t, tc.expectedReport, report)
+			imported, err := target.GetEvents()
+			require.Nil(t, err)
+			var ids []string
+			for _, e := range imported.Events {
+				ids = append(ids, e.ID)
+			}
+			assert.Equal(t, tc.expectedEventIDs, ids)
+		})
+	}
+}
+
+func (f *fakeEventService) Export() (*data.Archive, error) {
+	return f.archive, f.err
+}
+
+func TestEncodingErrorIsProblem(t *testing.T) {
+	// JSON cannot encode times after the year 9999
+	archive := &data.Archive{Version: 1, ExportedAt: time.Date(10000, time.January, 1, 0, 0, 0, 0, time.UTC)}
+	ha := handlers.NewHandler(&fakeEventService{archive: archive})
+	router := mux.NewRouter()
+	router.HandleFunc("/admin/export", ha.ExportHandler)
+	rr := httptest.NewRecorder()
+
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/admin/export", nil))
+
+	require.Equal(t, http.StatusInternalServerError, rr.Code)
+	assert.Equal(t, "application/problem+json", rr.Header().Get("Content-Type"))
+	var p handlers.Problem
+	require.Nil(t, json.Unmarshal(rr.Body.Bytes(), &p))
+	assert.Equal(t, handlers.CodeInternalError, p.Code)
+	assert.Empty(t, p.Detail)
+	assert.NotContains(t, rr.Body.String(), "10000")
+}
+
+func TestCSVEscapesFormulas(t *testing.T) {
+	talks := &data.Talks{
+		Talks: []data.Talk{
+			{ID: "talk-1", EventID: "event-1", Title: `=HYPERLINK("http://example.com","Slides")`, Speakers: []string{"@Ada", "Grace Hopper"}},
+			{ID: "talk-2", EventID: "event-1", Title: "+1 for testing", Speakers: []string{"-Grace"}},
+			{ID: "talk-3", EventID: "event-1", Title: "\tTabbed", Speakers: []string{"Ada = Lovelace"}},
+		},
+	}
+	ha := handlers.NewHandler(&fakeEventService{talks: talks})
+	router := mux.NewRouter()
+	router.HandleFunc("/events/{id}/talks", ha.GetEventTalksHandler)
+	req := httptest.NewRequest("GET", "/events/event-1/talks", nil)
+	req.Header.Set("Accept", "text/csv")
+	rr := httptest.NewRecorder()
+
+	router.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "id,title,speakers,speaker_ids,date,time,duration,room,track,event_id\n"+
+		`talk-1,"'=HYPERLINK(""http://example.com"",""Slides"")",'@Ada; Grace Hopper,,,,,,,event-1`+"\n"+
+		"talk-2,'+1 for testing,'-Grace,,,,,,,event-1\n"+
+		"talk-3,'\tTabbed,Ada = Lovelace,,,,,,,event-1\n", rr.Body.String())
+}
+
+func TestContentNegotiationIntegration(t *testing.T) {
+	if os.Getenv("INTEGRATION") == "" {
+		t.Skip("Skipping TestContentNegotiationIntegration in short mode.")
+	}
+	events := []data.Event{
+		{ID: "event-1", Name: "Event 1", DateStart: date("01/02/2010"), DateEnd: date("02/02/2010"), Location: "Amsterdam"},
+	}
+	talks := []data.Talk{
+		{ID: "talk-1", EventID: "event-1", Title: "Testing, in production", Speakers: []string{"Ada", "Grace Hopper"},
+			Date: date("01/02/2010"), Time: dateTime("01/02/2010 09:30"), Duration: 30},
+	}
+	es, err := data.NewEventService(events, talks)
+	require.Nil(t, err)
+
+	// Arrange
+	ha := handlers.NewHandler(es)
+	router := mux.NewRouter()
+	router.HandleFunc("/v1/events/{id}/talks", ha.GetEventTalksHandler)
+	router.HandleFunc("/v2/events", ha.V2().GetEventsHandler)
+	router.HandleFunc("/v2/events/{id}/schedule", ha.V2().GetEventScheduleHandler)
+
+	testCases := map[string]struct {
+		path                string
+		accept              string
+		expectedContentType string
+		expectedBody        string
+		expectedStatusCode  int
+	}{
+		"json by default": {
+			path:                "/v2/events",
+			expectedContentType: "application/json; charset=UTF-8",
+			expectedBody: `{"events":[{"id":"event-1","name":"Event 1","date_start":"2010-02-01T00:00:00Z",` +
+				`"date_end":"2010-02-02T00:00:00Z","location":"Amsterdam"}]}` + "\n",
+			expectedStatusCode: http.StatusOK,
+		},
+		"any media type": {
+			path:                "/v2/events",
+			accept:              "*/*",
+			expectedContentType: "application/json; charset=UTF-8",
+			expectedStatusCode:  http.StatusOK,
+		},
+		"csv": {
+			path:                "/v1/events/event-1/talks",
+			accept:              "text/csv",
+			expectedContentType: "text/csv; charset=utf-8",
+			expectedBody: "id,title,speakers,speaker_ids,date,time,duration,room,track,event_id\n" +
+				"talk-1,\"Testing, in production\",Ada; Grace Hopper,ada; grace-hopper,01/02/2010,09:30,30,,,event-1\n",
+			expectedStatusCode: http.StatusOK,
+		},
+		"yaml": {
+			path:                "/v2/events?format=yaml",
+			expectedContentType: "application/yaml; charset=utf-8",
+			expectedBody: "events:\n" +
+				"  - id: event-1\n" +
+				"    name: Event 1\n" +
+				"    date_start: \"2010-02-01T00:00:00Z\"\n" +
+				"    date_end: \"2010-02-02T00:00:00Z\"\n" +
+				"    location: Amsterdam\n",
+			expectedStatusCode: http.StatusOK,
+		},
+		"xml": {
+			path:                "/v1/events/event-1/talks",
+			accept:              "application/xml",
+			expectedContentType: "application/xml; charset=utf-8",
+			expectedBody: `<?xml version="1.0" encoding="UTF-8"?>` + "\n" +
+				"<response>\n" +
+				"  <talks>\n" +
+				"    <talk>\n" +
+				"      <id>talk-1</id>\n" +
+				"      <title>Testing, in production</title>\n" +
+				"      <speakers>\n" +
+				"        <speaker>Ada</speaker>\n" +
+				"        <speaker>Grace Hopper</speaker>\n" +
+				"      </speakers>\n" +
+				"      <speaker_ids>\n" +
+				"        <speaker_id>ada</speaker_id>\n" +
+				"        <speaker_id>grace-hopper</speaker_id>\n" +
+				"      </speaker_ids>\n" +
+				"      <date>01/02/2010</date>\n" +
+				"      <time>09:30</time>\n" +
+				"      <duration>30</duration>\n" +
+				"      <event_id>event-1</event_id>\n" +
+				"    </talk>\n" +
+				"  </talks>\n" +
+				"</response>\n",
+			expectedStatusCode: http.StatusOK,
+		},
+		"preferred media type": {
+			path:                "/v2/events",
+			accept:              "application/json;q=0.5, text/csv;q=0.8",
+			expectedContentType: "text/csv; charset=utf-8",
+			expectedStatusCode:  http.StatusOK,
+		},
+		"format takes precedence": {
+			path:                "/v2/events?format=json",
+			accept:              "application/xml",
+			expectedContentType: "application/json; charset=UTF-8",
+			expectedStatusCode:  http.StatusOK,
+		},
+		"falls back to an available media type": {
+			path:                "/v2/events/event-1/schedule",
+			accept:              "text/csv, application/yaml;q=0.5",
+			expectedContentType: "application/yaml; charset=utf-8",
+			expectedStatusCode:  http.StatusOK,
+		},
+		"unsupported media type": {
+			path:                "/v2/events",
+			accept:              "application/pdf",
+			expectedContentType: "application/problem+json",
+			expectedStatusCode:  http.StatusNotAcceptable,
+		},
+		"unsupported format": {
+			path:                "/v2/events?format=pdf",
+			expectedContentType: "application/problem+json",
+			expectedStatusCode:  http.StatusNotAcceptable,
+		},
+		"csv of a schedule": {
+			path:                "/v2/events/event-1/schedule?format=csv",
+			expectedContentType: "application/problem+json",
+			expectedStatusCode:  http.StatusNotAcceptable,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", tc.path, nil)
+			require.Nil(t, err)
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			require.Equal(t, tc.expectedStatusCode, rr.Code)
+			assert.Equal(t, tc.expectedContentType, rr.Header().Get("Content-Type"))
+			assert.Equal(t, "Accept", rr.Header().Get("Vary"))
+
+			if tc.expectedStatusCode == http.StatusNotAcceptable {
+				var respErr handlers.Problem
+				err = json.Unmarshal(rr.Body.Bytes(), &respErr)
+				require.Nil(t, err)
+				assert.Equal(t, handlers.CodeNotAcceptable, respErr.Code)
+				return
+			}
+			if tc.expectedBody != "" {
+				assert.Equal(t, tc.expectedBody, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestDeprecated(t *testing.T) {
+	deprecatedAt := time.Date(2026, time.October, 18, 0, 0, 0, 0, time.UTC)
+	sunset := time.Date(2027, time.April, 18, 0, 0, 0, 0, time.UTC)
+	router := mux.NewRouter()
+	router.Use(handlers.Deprecated(deprecatedAt, sunset, "/v2"))
+	router.HandleFunc("/events", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	req, err := http.NewRequest("GET", "/events", nil)
+	require.Nil(t, err)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "@1792281600", rr.Header().Get("Deprecation"))
+	assert.Equal(t, "Sun, 18 Apr 2027 00:00:00 GMT", rr.Header().Get("Sunset"))
+	assert.Equal(t, `</v2>; rel="successor-version"`, rr.Header().Get("Link"))
+}
+
+func TestRequireToken(t *testing.T) {
+	testCases := map[string]struct {
+		token              string
+		authorization      string
+		expectedStatusCode int
+	}{
+		"valid token": {
+			token:              "secret",
+			authorization:      "Bearer secret",
+			expectedStatusCode: http.StatusOK,
+		},
+		"wrong token": {
+			token:              "secret",
+			authorization:      "Bearer guess",
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+		"missing token": {
+			token:              "secret",
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+		"no token configured": {
+			authorization:      "Bearer ",
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			router := mux.NewRouter()
+			router.Use(handlers.RequireToken(tc.token))
+			router.HandleFunc("/admin/export", func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+			req, err := http.NewRequest("GET", "/admin/export", nil)
+			require.Nil(t, err)
+			if tc.authorization != "" {
+				req.Header.Set("Authorization", tc.authorization)
+			}
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.expectedStatusCode, rr.Code)
+			if rr.Code == http.StatusUnauthorized {
+				assert.Equal(t, `Bearer realm="admin"`, rr.Header().Get("WWW-Authenticate"))
+				var resp handlers.Problem
+				require.Nil(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+				assert.Equal(t, handlers.CodeUnauthorized, resp.Code)
+			}
+		})
+	}
+}
+
+func TestSearchIntegration(t *testing.T) {
+	if os.Getenv("INTEGRATION") == "" {
+		t.Skip("Skipping TestSearchIntegration in short mode.")
+	}
+	events := []data.Event{
+		{ID: "event-1", Name: "GopherCon EU", Location: "Berlin"},
+	}
+	talks := []data.Talk{
+		{ID: "talk-1", EventID: "event-1", Title: "Testing in Go", Speakers: []string{"Adelina Simion"}},
+	}
+	es, err := data.NewEventService(events, talks)
+	require.Nil(t, err)
+
+	// Arrange
+	ha := handlers.NewHandler(es)
+
+	testCases := map[string]struct {
+		query              string
+		expectedBody       string
+		expectedStatusCode int
+	}{
+		"matches": {
+			query:              "?q=go",
+			expectedBody:       `{"query":"go","events":[{"id":"event-1","title":"GopherCon EU","score":3,"highlights":{"name":"<mark>GopherCon</mark> EU"}}],"talks":[{"id":"talk-1","event_id":"event-1","title":"Testing in Go","score":6,"highlights":{"title":"Testing in <mark>Go</mark>"}}],"speakers":[]}`,
+			expectedStatusCode: http.StatusOK,
+		},
+		"no matches": {
+			query:              "?q=kotlin",
+			expectedBody:       `{"query":"kotlin","events":[],"talks":[],"speakers":[]}`,
+			expectedStatusCode: http.StatusOK,
+		},
+		"missing query": {
+			query:              "?q=%20",
+			expectedStatusCode: http.StatusBadRequest,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			ha.SearchHandler(rr, httptest.NewRequest("GET", "/search"+tc.query, nil))
+			require.Equal(t, tc.expectedStatusCode, rr.Code)
+
+			if tc.expectedStatusCode != http.StatusOK {
+				var respErr handlers.Problem
+				err := json.Unmarshal(rr.Body.Bytes(), &respErr)
+				require.Nil(t, err)
+				require.Len(t, respErr.InvalidParams, 1)
+				assert.Equal(t, "q", respErr.InvalidParams[0].Name)
+				return
+			}
+			assert.JSONEq(t, tc.expectedBody, rr.Body.String())
+		})
+	}
+}
+
+func TestSpeakersIntegration(t *testing.T) {
+	if os.Getenv("INTEGRATION") == "" {
+		t.Skip("Skipping TestSpeakersIntegration in short mode.")
+	}
+	events := []data.Event{
+		{ID: "event-1", DateStart: date("01/02/2010"), DateEnd: date("01/02/2010")},
+	}
+	talks := []data.Talk{
+		{ID: "talk-1", EventID: "event-1", Title: "Talk 1", Speakers: []string{"Grace Hopper"}, Date: date("01/02/2010")},
+	}
+	speakers := []data.Speaker{
+		{ID: "grace-hopper", Name: "Grace Hopper", Company: "US Navy"},
+	}
+	es, err := data.NewEventService(events, talks, data.WithSpeakers(speakers))
+	require.Nil(t, err)
+
+	// Arrange
+	ha := handlers.NewHandler(es)
+	router := mux.NewRouter()
+	router.HandleFunc("/speakers", ha.GetSpeakersHandler)
+	router.HandleFunc("/speakers/{id}", ha.GetSpeakerHandler)
+	router.HandleFunc("/speakers/{id}/talks", ha.GetSpeakerTalksHandler)
+	router.HandleFunc("/v2/speakers/{id}/talks", ha.V2().GetSpeakerTalksHandler)
+
+	testCases := map[string]struct {
+		path               string
+		expectedBody       string
+		expectedCode       string
+		expectedStatusCode int
+	}{
+		"speakers": {
+			path:               "/speakers",
+			expectedBody:       `{"speakers":[{"id":"grace-hopper","name":"Grace Hopper","company":"US Navy"}]}`,
+			expectedStatusCode: http.StatusOK,
+		},
+		"speaker": {
+			path:               "/speakers/grace-hopper",
+			expectedBody:       `{"id":"grace-hopper","name":"Grace Hopper","company":"US Navy"}`,
+			expectedStatusCode: http.StatusOK,
+		},
+		"speaker talks": {
+			path:               "/speakers/grace-hopper/talks",
+			expectedBody:       `{"talks":[{"id":"talk-1","title":"Talk 1","speakers":["Grace Hopper"],"speaker_ids":["grace-hopper"],"date":"01/02/2010","time":"","event_id":"event-1"}]}`,
+			expectedStatusCode: http.StatusOK,
+		},
+		"v2 speaker talks": {
+			path:               "/v2/speakers/grace-hopper/talks",
+			expectedBody:       `{"talks":[{"id":"talk-1","title":"Talk 1","speakers":["Grace Hopper"],"speaker_ids":["grace-hopper"],"date":"2010-02-01T00:00:00Z","time":"","event_id":"event-1"}]}`,
+			expectedStatusCode: http.StatusOK,
+		},
+		"invalid speaker": {
+			path:               "/speakers/ada-lovelace",
+			expectedCode:       handlers.CodeSpeakerNotFound,
+			expectedStatusCode: http.StatusNotFound,
+		},
+		"invalid speaker talks": {
+			path:               "/speakers/ada-lovelace/talks",
+			expectedCode:       handlers.CodeSpeakerNotFound,
+			expectedStatusCode: http.StatusNotFound,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest("GET", tc.path, nil))
+			require.Equal(t, tc.expectedStatusCode, rr.Code)
+
+			if len(tc.expectedCode) != 0 {
+				var respErr handlers.Problem
+				err := json.Unmarshal(rr.Body.Bytes(), &respErr)
+				require.Nil(t, err)
+				assert.Equal(t, tc.expectedCode, respErr.Code)
+				return
+			}
+			assert.JSONEq(t, tc.expectedBody, rr.Body.String())
+		})
+	}
+}
+
+func TestScheduleIntegration(t *testing.T) {
+	if os.Getenv("INTEGRATION") == "" {
+		t.Skip("Skipping TestScheduleIntegration in short mode.")
+	}
+	events := []data.Event{
+		{ID: "event-1", DateStart: date("01/02/2010"), DateEnd: date("01/02/2010")},
+	}
+	talks := []data.Talk{
+		{ID: "talk-1", EventID: "event-1", Title: "Talk 1", Date: date("01/02/2010"), Time: dateTime("01/02/2010 10:00"), Duration: 30, Room: "Main", Track: "Go"},
+		{ID: "talk-2", EventID: "event-1", Title: "Talk 2", Date: date("01/02/2010"), Time: dateTime("01/02/2010 09:00"), Room: "Side"},
+	}
+	es, err := data.NewEventService(events, talks)
+	require.Nil(t, err)
+
+	// Arrange
+	ha := handlers.NewHandler(es)
+	router := mux.NewRouter()
+	router.HandleFunc("/events/{id}/rooms", ha.GetEventRoomsHandler)
+	router.HandleFunc("/events/{id}/tracks", ha.GetEventTracksHandler)
+	router.HandleFunc("/events/{id}/schedule", ha.GetEventScheduleHandler)
+	router.HandleFunc("/v2/events/{id}/schedule", ha.V2().GetEventScheduleHandler)
+
+	testCases := map[string]struct {
+		path               string
+		expectedBody       string
+		expectedCode       string
+		expectedStatusCode int
+	}{
+		"rooms": {
+			path:               "/events/event-1/rooms",
+			expectedBody:       `{"rooms":[{"name":"Main","talk_count":1},{"name":"Side","talk_count":1}]}`,
+			expectedStatusCode: http.StatusOK,
+		},
+		"tracks": {
+			path:               "/events/event-1/tracks",
+			expectedBody:       `{"tracks":[{"name":"Go","talk_count":1}]}`,
+			expectedStatusCode: http.StatusOK,
+		},
+		"schedule": {
+			path: "/events/event-1/schedule",
+			expectedBody: `{"event_id":"event-1","slots":[` +
+				`{"date":"01/02/2010","time":"09:00","talks":[{"id":"talk-2","title":"Talk 2","speakers":null,"date":"01/02/2010","time":"09:00","room":"Side","event_id":"event-1"}]},` +
+				`{"date":"01/02/2010","time":"10:00","talks":[{"id":"talk-1","title":"Talk 1","speakers":null,"date":"01/02/2010","time":"10:00","duration":30,"room":"Main","track":"Go","event_id":"event-1"}]}]}`,
+			expectedStatusCode: http.StatusOK,
+		},
+		"v2 schedule of a room": {
+			path: "/v2/events/event-1/schedule?room=main",
+			expectedBody: `{"event_id":"event-1","slots":[` +
+				`{"date":"2010-02-01T00:00:00Z","time":"2010-02-01T10:00:00Z","talks":[{"id":"talk-1","title":"Talk 1","speakers":null,"date":"2010-02-01T00:00:00Z","time":"2010-02-01T10:00:00Z","duration":30,"room":"Main","track":"Go","event_id":"event-1"}]}]}`,
+			expectedStatusCode: http.StatusOK,
+		},
+		"invalid event": {
+			path:               "/events/event-2/schedule",
+			expectedCode:       handlers.CodeEventNotFound,
+			expectedStatusCode: http.StatusNotFound,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest("GET", tc.path, nil))
+			require.Equal(t, tc.expectedStatusCode, rr.Code)
+
+			if len(tc.expectedCode) != 0 {
+				var respErr handlers.Problem
+				err := json.Unmarshal(rr.Body.Bytes(), &respErr)
+				require.Nil(t, err)
+				assert.Equal(t, tc.expectedCode, respErr.Code)
+				return
+			}
+			assert.JSONEq(t, tc.expectedBody, rr.Body.String())
+		})
+	}
+}
+
+func TestGetEventConflictsIntegration(t *testing.T) {
+	if os.Getenv("INTEGRATION") == "" {
+		t.Skip("Skipping TestGetEventConflictsIntegration in short mode.")
+	}
+	events := []data.Event{
+		{ID: "event-1", DateStart: date("01/02/2010"), DateEnd: date("01/02/2010")},
+	}
+	talks := []data.Talk{
+		{ID: "talk-1", EventID: "event-1", Title: "Talk 1", Speakers: []string{"Ada"}, Date: date("01/02/2010"), Time: dateTime("01/02/2010 09:00"), Duration: 45, Room: "Main"},
+		{ID: "talk-2", EventID: "event-1", Title: "Talk 2", Speakers: []string{"Ada"}, Date: date("01/02/2010"), Time: dateTime("01/02/2010 09:30"), Room: "Main"},
+	}
+	es, err := data.NewEventService(events, talks)
+	require.Nil(t, err)
+
+	// Arrange
+	ha := handlers.NewHandler(es)
+	router := mux.NewRouter()
+	router.HandleFunc("/events/{id}/conflicts", ha.GetEventConflictsHandler)
+
+	t.Run("conflicts", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", "/events/event-1/conflicts", nil))
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.JSONEq(t, `{"event_id":"event-1","conflicts":[`+
+			`{"kind":"room_overlap","message":"talks talk-1 and talk-2 overlap in room Main","talk_ids":["talk-1","talk-2"],"room":"Main"},`+
+			`{"kind":"speaker_double_booked","message":"speaker ada is booked for talks talk-1 and talk-2 at the same time","talk_ids":["talk-1","talk-2"],"speaker_id":"ada"}]}`,
+			rr.Body.String())
+	})
+	t.Run("invalid event", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", "/events/event-2/conflicts", nil))
+		require.Equal(t, http.StatusNotFound, rr.Code)
+		var respErr handlers.Problem
+		require.Nil(t, json.Unmarshal(rr.Body.Bytes(), &respErr))
+		assert.Equal(t, handlers.CodeEventNotFound, respErr.Code)
+	})
+}
+
+// date returns the date in the DD/MM/YYYY format as midnight UTC, panicking if it is invalid.
+func date(s string) time.Time {
+	d, err := time.Parse("02/01/2006", s)
+	if err != nil {
+		panic(err)
+	}
+
+	return d
+}
+
+// dateTime returns the date and time in the DD/MM/YYYY HH:MM format in UTC, panicking if it is invalid.
+func dateTime(s string) time.Time {
+	d, err := time.Parse("02/01/2006 15:04", s)
+	if err != nil {
+		panic(err)
+	}
+
+	return d
+}
+
+func TestConditionalIntegration(t *testing.T) {
+	if os.Getenv("INTEGRATION") == "" {
+		t.Skip("Skipping TestConditionalIntegration in short mode.")
+	}
+	events := []data.Event{
+		{ID: "event-1", DateStart: date("01/02/2010"), DateEnd: date("02/02/2010")},
+	}
+	talks := []data.Talk{
+		{ID: "talk-1", EventID: "event-1", Title: "Talk 1", Date: date("01/02/2010")},
+	}
+	es, err := data.NewEventService(events, talks)
+	require.Nil(t, err)
+
+	// Arrange
+	ha := handlers.NewHandler(es)
+	router := mux.NewRouter()
+	v2 := router.PathPrefix("/v2").Subrouter()
+	v2.Use(ha.Conditional(handlers.CachePolicy{
+		Default: "no-cache",
+		Routes:  map[string]string{"/events/{id}/schedule": "public, max-age=60"},
+	}))
+	v2.Methods("GET").Path("/events").HandlerFunc(ha.V2().GetEventsHandler)
+	v2.Methods("GET").Path("/events/{id}/talks").HandlerFunc(ha.V2().GetEventTalksHandler)
+	v2.Methods("POST").Path("/events/{id}/talks").HandlerFunc(ha.V2().CreateTalkHandler)
+	v2.Methods("GET").Path("/events/{id}/schedule").HandlerFunc(ha.V2().GetEventScheduleHandler)
+
+	get := func(path string, header http.Header) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("GET", path, nil)
+		require.Nil(t, err)
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+	first := get("/v2/events/event-1/talks", nil)
+	require.Equal(t, http.StatusOK, first.Code)
+	etag, lastModified := first.Header().Get("ETag"), first.Header().Get("Last-Modified")
+	require.NotEmpty(t, etag)
+	require.NotEmpty(t, lastModified)
+	assert.Equal(t, "no-cache", first.Header().Get("Cache-Control"))
+
+	testCases := map[string]struct {
+		path                 string
+		header               http.Header
+		expectedStatusCode   int
+		expectedCacheControl string
+		expectedETag         bool
+	}{
+		"matching etag": {
+			path:                 "/v2/events/event-1/talks",
+			header:               http.Header{"If-None-Match": {`"other", ` + etag}},
+			expectedStatusCode:   http.StatusNotModified,
+			expectedCacheControl: "no-cache",
+			expectedETag:         true,
+		},
+		"weak matching etag": {
+			path:                 "/v2/events/event-1/talks",
+			header:               http.Header{"If-None-Match": {"W/" + etag}},
+			expectedStatusCode:   http.StatusNotModified,
+			expectedCacheControl: "no-cache",
+			expectedETag:         true,
+		},
+		"other etag": {
+			path:                 "/v2/events/event-1/talks",
+			header:               http.Header{"If-None-Match": {`"other"`}},
+			expectedStatusCode:   http.StatusOK,
+			expectedCacheControl: "no-cache",
+			expectedETag:         true,
+		},
+		"etag takes precedence over date": {
+			path:                 "/v2/events/event-1/talks",
+			header:               http.Header{"If-None-Match": {`"other"`}, "If-Modified-Since": {lastModified}},
+			expectedStatusCode:   http.StatusOK,
+			expectedCacheControl: "no-cache",
+			expectedETag:         true,
+		},
+		"not modified since": {
+			path:                 "/v2/events",
+			header:               http.Header{"If-Modified-Since": {lastModified}},
+			expectedStatusCode:   http.StatusNotModified,
+			expectedCacheControl: "no-cache",
+			expectedETag:         true,
+		},
+		"modified since": {
+			path:                 "/v2/events",
+			header:               http.Header{"If-Modified-Since": {"Mon, 01 Feb 2010 00:00:00 GMT"}},
+			expectedStatusCode:   http.StatusOK,
+			expectedCacheControl: "no-cache",
+			expectedETag:         true,
+		},
+		"etag of another format": {
+			path:                 "/v2/events/event-1/talks?format=csv",
+			header:               http.Header{"If-None-Match": {etag}},
+			expectedStatusCode:   http.StatusOK,
+			expectedCacheControl: "no-cache",
+			expectedETag:         true,
+		},
+		"route policy": {
+			path:                 "/v2/events/event-1/schedule",
+			expectedStatusCode:   http.StatusOK,
+			expectedCacheControl: "public, max-age=60",
+			expectedETag:         true,
+		},
+		"error": {
+			path:               "/v2/events/event-9/talks",
+			header:             http.Header{"If-None-Match": {"*"}},
+			expectedStatusCode: http.StatusNotFound,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			// Act
+			rr := get(tc.path, tc.header)
+
+			// Assert
+			assert.Equal(t, tc.expectedStatusCode, rr.Code)
+			assert.Equal(t, tc.expectedCacheControl, rr.Header().Get("Cache-Control"))
+			assert.Equal(t, tc.expectedETag, rr.Header().Get("ETag") != "")
+			if rr.Code == http.StatusNotModified {
+				assert.Empty(t, rr.Body.String())
+				assert.Empty(t, rr.Header().Get("Content-Type"))
+				assert.Equal(t, lastModified, rr.Header().Get("Last-Modified"))
+			}
+		})
+	}
+
+	t.Run("changed data", func(t *testing.T) {
+		body := strings.NewReader(`{"title":"Talk 2","date":"2010-02-02"}`)
+		req, err := http.NewRequest("POST", "/v2/events/event-1/talks", body)
+		require.Nil(t, err)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		require.Equal(t, http.StatusCreated, rr.Code)
+		assert.Empty(t, rr.Header().Get("ETag"))
+
+		rr = get("/v2/events/event-1/talks", http.Header{"If-None-Match": {etag}})
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.NotEqual(t, etag, rr.Header().Get("ETag"))
+		assert.Contains(t, rr.Body.String(), "Talk 2")
+	})
+}
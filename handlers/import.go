@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/addetz/testing-strategies-demo/data"
+	"github.com/gorilla/mux"
+)
+
+// MaxTalkImportSize limits the size of the CSV body imported by ImportTalksHandler,
+// so that a large upload cannot exhaust memory.
+const MaxTalkImportSize = 8 << 20
+
+// ImportTalksHandler imports the talks in a CSV request body into an event and returns the import report.
+// Rows with problems are listed in the report and skipped, while the other rows are imported.
+// A body larger than MaxTalkImportSize is rejected without importing any of its rows.
+// The query parameters configure the import:
+// columns maps talk fields to CSV columns as field=column pairs separated by commas,
+// speaker_delimiter separates the speakers of a talk, delimiter is the CSV field delimiter,
+// and dry_run checks the talks without importing them.
+func (h *Handler) ImportTalksHandler(w http.ResponseWriter, r *http.Request) {
+	opts, err := talkImportOptions(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	opts.EventID = mux.Vars(r)["id"]
+	report, err := h.service().ImportTalks(http.MaxBytesReader(w, r.Body, MaxTalkImportSize), opts)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeResponse[data.TalkImportReport](w, r, http.StatusOK, report)
+}
+
+// talkImportOptions returns the options of a CSV import given by the query parameters of the request.
+func talkImportOptions(r *http.Request) (data.TalkImportOptions, error) {
+	params := r.URL.Query()
+	var opts data.TalkImportOptions
+	if spec := params.Get("columns"); spec != "" {
+		columns, err := data.ParseTalkColumns(spec)
+		if err != nil {
+			return opts, paramError("columns", err)
+		}
+		opts.Columns = columns
+	}
+	opts.SpeakerDelimiter = params.Get("speaker_delimiter")
+	if delimiter := params.Get("delimiter"); delimiter != "" {
+		comma, size := utf8.DecodeRuneInString(delimiter)
+		if size != len(delimiter) || comma == '"' || comma == '\r' || comma == '\n' {
+			return opts, invalidParam("delimiter", "must be a single character other than a quote or line break")
+		}
+		opts.Comma = comma
+	}
+	if dryRun := params.Get("dry_run"); dryRun != "" {
+		parsed, err := strconv.ParseBool(dryRun)
+		if err != nil {
+			return opts, invalidParam("dry_run", "must be true or false")
+		}
+		opts.DryRun = parsed
+	}
+
+	return opts, nil
+}
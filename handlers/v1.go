@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/addetz/testing-strategies-demo/data"
+)
+
+// EventV1 is the v1 representation of an event, with dates in the legacy DD/MM/YYYY format.
+// v1 keeps the fields and format it had before v2, so that its clients keep working:
+// fields are only ever added to it, never renamed or removed.
+type EventV1 struct {
+	ID        string `json:"ID"`
+	Name      string `json:"name"`
+	DateStart string `json:"date_start"`
+	DateEnd   string `json:"date_end"`
+	TimeZone  string `json:"time_zone,omitempty"`
+	Location  string `json:"location"`
+}
+
+// EventsV1 is a list of v1 events, which is null rather than empty if there are none.
+type EventsV1 struct {
+	Events     []EventV1 `json:"events"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+}
+
+// TalkV1 is the v1 representation of a talk, with a date in the legacy DD/MM/YYYY format
+// and a time of day in the HH:MM format, both in the time zone of its event.
+// Like EventV1, it keeps the fields it had before v2 and only adds to them.
+type TalkV1 struct {
+	ID         string   `json:"id"`
+	Title      string   `json:"title"`
+	Speakers   []string `json:"speakers"`
+	SpeakerIDs []string `json:"speaker_ids,omitempty"`
+	Date       string   `json:"date"`
+	Time       string   `json:"time"`
+	Duration   int      `json:"duration,omitempty"`
+	Room       string   `json:"room,omitempty"`
+	Track      string   `json:"track,omitempty"`
+	EventID    string   `json:"event_id"`
+}
+
+// TalksV1 is a list of v1 talks, which is null rather than empty if there are none.
+type TalksV1 struct {
+	Talks []TalkV1 `json:"talks"`
+}
+
+type ScheduleSlotV1 struct {
+	Date  string   `json:"date"`
+	Time  string   `json:"time"`
+	Talks []TalkV1 `json:"talks"`
+}
+
+type ScheduleV1 struct {
+	EventID string           `json:"event_id"`
+	Slots   []ScheduleSlotV1 `json:"slots"`
+}
+
+func toEventV1(e data.Event) EventV1 {
+	return EventV1{
+		ID:        e.ID,
+		Name:      e.Name,
+		DateStart: formatTime(e.DateStart, legacyDateFormat),
+		DateEnd:   formatTime(e.DateEnd, legacyDateFormat),
+		TimeZone:  e.TimeZone,
+		Location:  e.Location,
+	}
+}
+
+func toEventsV1(events data.Events) EventsV1 {
+	resp := EventsV1{NextCursor: events.NextCursor}
+	for _, e := range events.Events {
+		resp.Events = append(resp.Events, toEventV1(e))
+	}
+
+	return resp
+}
+
+func toTalkV1(t data.Talk) TalkV1 {
+	return TalkV1{
+		ID:         t.ID,
+		Title:      t.Title,
+		Speakers:   t.Speakers,
+		SpeakerIDs: t.SpeakerIDs,
+		Date:       formatTime(t.Date, legacyDateFormat),
+		Time:       formatTime(t.Time, timeLayout),
+		Duration:   t.Duration,
+		Room:       t.Room,
+		Track:      t.Track,
+		EventID:    t.EventID,
+	}
+}
+
+func toTalksV1(talks []data.Talk) TalksV1 {
+	var resp TalksV1
+	for _, t := range talks {
+		resp.Talks = append(resp.Talks, toTalkV1(t))
+	}
+
+	return resp
+}
+
+func toScheduleV1(schedule data.Schedule) ScheduleV1 {
+	resp := ScheduleV1{
+		EventID: schedule.EventID,
+		Slots:   make([]ScheduleSlotV1, 0, len(schedule.Slots)),
+	}
+	for _, slot := range schedule.Slots {
+		resp.Slots = append(resp.Slots, ScheduleSlotV1{
+			Date:  formatTime(slot.Date, legacyDateFormat),
+			Time:  formatTime(slot.Time, timeLayout),
+			Talks: toTalksV1(slot.Talks).Talks,
+		})
+	}
+
+	return resp
+}
+
+// formatTime formats t with the given layout, or returns an empty string if t is the zero time.
+func formatTime(t time.Time, layout string) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.Format(layout)
+}
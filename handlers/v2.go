@@ -0,0 +1,406 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/addetz/testing-strategies-demo/data"
+	"github.com/gorilla/mux"
+)
+
+// isoDateFormat is the date format of the v2 API.
+const isoDateFormat = "2006-01-02"
+
+// legacyDateFormat is the date format of the v1 API.
+const legacyDateFormat = "02/01/2006"
+
+// timeLayout is the format of talk times in both API versions.
+const timeLayout = "15:04"
+
+// dateLayoutNames describes each date format in rejected parameters.
+var dateLayoutNames = map[string]string{
+	isoDateFormat:    "YYYY-MM-DD",
+	legacyDateFormat: "DD/MM/YYYY",
+}
+
+// EventV2 is the v2 representation of an event, with a lowercase id and RFC 3339 dates.
+// Dates are accepted as RFC 3339 dates such as 2023-06-28, or dates and times with a UTC offset.
+type EventV2 struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	DateStart string `json:"date_start"`
+	DateEnd   string `json:"date_end"`
+	TimeZone  string `json:"time_zone,omitempty"`
+	Location  string `json:"location"`
+	// Talks is nil unless requested, so that an event without talks
+	// can be told apart from one whose talks were not included.
+	Talks *[]TalkV2 `json:"talks,omitempty"`
+}
+
+type EventsV2 struct {
+	Events     []EventV2 `json:"events"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+}
+
+// TalkV2 is the v2 representation of a talk, with an RFC 3339 date and time.
+// The time is also accepted as a time of day in the HH:MM format, in the time zone of the event.
+type TalkV2 struct {
+	ID         string   `json:"id"`
+	Title      string   `json:"title"`
+	Speakers   []string `json:"speakers"`
+	SpeakerIDs []string `json:"speaker_ids,omitempty"`
+	Date       string   `json:"date"`
+	Time       string   `json:"time"`
+	Duration   int      `json:"duration,omitempty"`
+	Room       string   `json:"room,omitempty"`
+	Track      string   `json:"track,omitempty"`
+	EventID    string   `json:"event_id"`
+}
+
+type TalksV2 struct {
+	Talks []TalkV2 `json:"talks"`
+}
+
+// ScheduleSlotV2 is the v2 representation of a schedule slot, with an RFC 3339 date and time.
+type ScheduleSlotV2 struct {
+	Date  string   `json:"date"`
+	Time  string   `json:"time"`
+	Talks []TalkV2 `json:"talks"`
+}
+
+type ScheduleV2 struct {
+	EventID string           `json:"event_id"`
+	Slots   []ScheduleSlotV2 `json:"slots"`
+}
+
+// V2Handler serves the v2 API. The handlers whose requests
+// and responses are the same in both versions are promoted from Handler.
+type V2Handler struct {
+	*Handler
+}
+
+// V2 returns the handler of the v2 API, sharing the event service of h.
+func (h *Handler) V2() *V2Handler {
+	return &V2Handler{Handler: h}
+}
+
+// GetEventsHandler returns the events selected by the limit, cursor, sort,
+// location, from and to query parameters.
+func (h *V2Handler) GetEventsHandler(w http.ResponseWriter, r *http.Request) {
+	events, err := h.queryEvents(r, isoDateFormat)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	resp := EventsV2{
+		Events:     make([]EventV2, 0, len(events.Events)),
+		NextCursor: events.NextCursor,
+	}
+	for _, e := range events.Events {
+		resp.Events = append(resp.Events, toEventV2(e))
+	}
+	writeResponse[EventsV2](w, r, http.StatusOK, &resp)
+}
+
+// GetEventHandler returns the event with the given id.
+// Its talks are included if the include query parameter is talks.
+func (h *V2Handler) GetEventHandler(w http.ResponseWriter, r *http.Request) {
+	eventID := mux.Vars(r)["id"]
+	include := r.URL.Query().Get("include")
+	if include != "" && include != "talks" {
+		writeError(w, r, invalidParam("include", "must be talks"))
+		return
+	}
+	event, err := h.service().GetEvent(eventID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	resp := toEventV2(*event)
+	if include == "talks" {
+		talks := toTalksV2(event.Talks).Talks
+		resp.Talks = &talks
+	}
+	writeResponse[EventV2](w, r, http.StatusOK, &resp)
+}
+
+// GetEventTalksHandler returns the talks of an event selected by the day, date,
+// from_time, to_time, speaker and q query parameters.
+func (h *V2Handler) GetEventTalksHandler(w http.ResponseWriter, r *http.Request) {
+	talks, err := eventTalks(h.service(), r, isoDateFormat)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	resp := toTalksV2(talks.Talks)
+	writeResponse[TalksV2](w, r, http.StatusOK, &resp)
+}
+
+// GetEventTalksCalendarHandler returns the talks of an event as an iCalendar document,
+// selected by the same query parameters as GetEventTalksHandler.
+func (h *V2Handler) GetEventTalksCalendarHandler(w http.ResponseWriter, r *http.Request) {
+	h.writeTalksCalendar(w, r, isoDateFormat)
+}
+
+func (h *V2Handler) CreateEventHandler(w http.ResponseWriter, r *http.Request) {
+	var body EventV2
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, r, invalidBody(err))
+		return
+	}
+	event, err := body.toData()
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	created, err := h.service().CreateEvent(event)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	resp := toEventV2(*created)
+	writeResponse[EventV2](w, r, http.StatusCreated, &resp)
+}
+
+func (h *V2Handler) UpdateEventHandler(w http.ResponseWriter, r *http.Request) {
+	eventID := mux.Vars(r)["id"]
+	var body EventV2
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, r, invalidBody(err))
+		return
+	}
+	event, err := body.toData()
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	updated, err := h.service().UpdateEvent(eventID, event)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	resp := toEventV2(*updated)
+	writeResponse[EventV2](w, r, http.StatusOK, &resp)
+}
+
+// PatchEventHandler applies the fields present in the request body
+// on top of the existing event, leaving all other fields unchanged.
+func (h *V2Handler) PatchEventHandler(w http.ResponseWriter, r *http.Request) {
+	eventID := mux.Vars(r)["id"]
+	patch, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, r, invalidBody(err))
+		return
+	}
+	updated, err := h.service().PatchEvent(eventID, func(event *data.Event) error {
+		body := toEventV2(*event)
+		if err := json.NewDecoder(bytes.NewReader(patch)).Decode(&body); err != nil {
+			return invalidBody(err)
+		}
+		patched, err := body.toData()
+		if err != nil {
+			return err
+		}
+		*event = patched
+		return nil
+	})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	resp := toEventV2(*updated)
+	writeResponse[EventV2](w, r, http.StatusOK, &resp)
+}
+
+func (h *V2Handler) CreateTalkHandler(w http.ResponseWriter, r *http.Request) {
+	eventID := mux.Vars(r)["id"]
+	var body TalkV2
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, r, invalidBody(err))
+		return
+	}
+	talk, err := body.toData()
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	created, err := h.service().CreateTalk(eventID, talk)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	resp := toTalkV2(*created)
+	writeResponse[TalkV2](w, r, http.StatusCreated, &resp)
+}
+
+func (h *V2Handler) GetTalkHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	talk, err := h.service().GetTalk(vars["id"], vars["talkID"])
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	resp := toTalkV2(*talk)
+	writeResponse[TalkV2](w, r, http.StatusOK, &resp)
+}
+
+func (h *V2Handler) UpdateTalkHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	var body TalkV2
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, r, invalidBody(err))
+		return
+	}
+	talk, err := body.toData()
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	updated, err := h.service().UpdateTalk(vars["id"], vars["talkID"], talk)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	resp := toTalkV2(*updated)
+	writeResponse[TalkV2](w, r, http.StatusOK, &resp)
+}
+
+// GetSpeakerTalksHandler returns the talks of a speaker across all events.
+func (h *V2Handler) GetSpeakerTalksHandler(w http.ResponseWriter, r *http.Request) {
+	talks, err := h.service().GetSpeakerTalks(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	resp := toTalksV2(talks.Talks)
+	writeResponse[TalksV2](w, r, http.StatusOK, &resp)
+}
+
+// GetEventScheduleHandler returns the talks of an event grouped by start time,
+// selected by the room and track query parameters.
+func (h *V2Handler) GetEventScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	schedule, err := h.eventSchedule(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	resp := ScheduleV2{
+		EventID: schedule.EventID,
+		Slots:   make([]ScheduleSlotV2, 0, len(schedule.Slots)),
+	}
+	for _, slot := range schedule.Slots {
+		resp.Slots = append(resp.Slots, ScheduleSlotV2{
+			Date:  formatTime(slot.Date, time.RFC3339),
+			Time:  formatTime(slot.Time, time.RFC3339),
+			Talks: toTalksV2(slot.Talks).Talks,
+		})
+	}
+	writeResponse[ScheduleV2](w, r, http.StatusOK, &resp)
+}
+
+func toEventV2(e data.Event) EventV2 {
+	return EventV2{
+		ID:        e.ID,
+		Name:      e.Name,
+		DateStart: formatTime(e.DateStart, time.RFC3339),
+		DateEnd:   formatTime(e.DateEnd, time.RFC3339),
+		TimeZone:  e.TimeZone,
+		Location:  e.Location,
+	}
+}
+
+// toData converts the event to the data package representation,
+// or returns an error naming the date which cannot be parsed.
+func (e EventV2) toData() (data.Event, error) {
+	start, err := parseDate("date_start", e.DateStart)
+	if err != nil {
+		return data.Event{}, err
+	}
+	end, err := parseDate("date_end", e.DateEnd)
+	if err != nil {
+		return data.Event{}, err
+	}
+
+	return data.Event{
+		ID:        e.ID,
+		Name:      e.Name,
+		DateStart: start,
+		DateEnd:   end,
+		TimeZone:  e.TimeZone,
+		Location:  e.Location,
+	}, nil
+}
+
+func toTalkV2(t data.Talk) TalkV2 {
+	return TalkV2{
+		ID:         t.ID,
+		Title:      t.Title,
+		Speakers:   t.Speakers,
+		SpeakerIDs: t.SpeakerIDs,
+		Date:       formatTime(t.Date, time.RFC3339),
+		Time:       formatTime(t.Time, time.RFC3339),
+		Duration:   t.Duration,
+		Room:       t.Room,
+		Track:      t.Track,
+		EventID:    t.EventID,
+	}
+}
+
+func toTalksV2(talks []data.Talk) TalksV2 {
+	resp := TalksV2{Talks: make([]TalkV2, 0, len(talks))}
+	for _, t := range talks {
+		resp.Talks = append(resp.Talks, toTalkV2(t))
+	}
+
+	return resp
+}
+
+// toData converts the talk to the data package representation,
+// or returns an error naming the date or time which cannot be parsed.
+func (t TalkV2) toData() (data.Talk, error) {
+	date, err := parseDate("date", t.Date)
+	if err != nil {
+		return data.Talk{}, err
+	}
+	var start time.Time
+	// a time of day without a date is left for the data package to reject the missing date
+	if _, err := time.Parse(timeLayout, t.Time); t.Time != "" && (err != nil || !date.IsZero()) {
+		start, err = data.ParseTalkTime(date, t.Time)
+		if err != nil {
+			return data.Talk{}, invalidParam("time", "must be a time in the format HH:MM or RFC 3339")
+		}
+	}
+
+	return data.Talk{
+		ID:         t.ID,
+		Title:      t.Title,
+		Speakers:   t.Speakers,
+		SpeakerIDs: t.SpeakerIDs,
+		Date:       date,
+		Time:       start,
+		Duration:   t.Duration,
+		Room:       t.Room,
+		Track:      t.Track,
+		EventID:    t.EventID,
+	}, nil
+}
+
+// parseDate parses the date in the named field, which is an RFC 3339 date or date and time.
+// Empty dates are left as the zero time for the data package to reject.
+func parseDate(field, date string) (time.Time, error) {
+	if date == "" {
+		return time.Time{}, nil
+	}
+	if _, err := time.Parse(legacyDateFormat, date); err == nil {
+		return time.Time{}, invalidParam(field, "must be a date in the format "+dateLayoutNames[isoDateFormat]+" or RFC 3339")
+	}
+	parsed, err := data.ParseDate(date)
+	if err != nil {
+		return time.Time{}, invalidParam(field, "must be a date in the format "+dateLayoutNames[isoDateFormat]+" or RFC 3339")
+	}
+
+	return parsed, nil
+}